@@ -0,0 +1,222 @@
+// This file holds the rules that raise alerts based on routines' values.
+
+package statusbar
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/snhilde/statusbar/v5/value"
+)
+
+// AlertRule raises an alert when a routine's values stay in a bad range, such as when the battery is
+// low and discharging or when the CPU is too hot. The expressions are written like the expressions
+// of a VisibilityRule, such as "perc < 10 && !charging". After every update, When is checked, and
+// once it has been true for the rule's minimum duration, the alert is raised and sent to the
+// statusbar's alert notifiers (see AddAlertNotifier). The alert stays raised until ClearWhen is true,
+// which keeps a value that hovers around a limit from raising the alert over and over.
+type AlertRule struct {
+	// Name of the rule, which identifies its alert.
+	Name string
+
+	// Expression that raises the alert when it is true.
+	When string
+
+	// Expression that clears the alert when it is true. If this is empty, the alert is cleared as
+	// soon as When is false.
+	ClearWhen string
+
+	// How long When must stay true before the alert is raised. If this is 0, the alert is raised
+	// after the first update that makes When true.
+	For time.Duration
+
+	// Text of the alert. If this is empty, the alert shows the When expression.
+	Message string
+
+	// Whether or not the alert is critical. Desktop notifications for critical alerts stay on the
+	// screen until they are dismissed.
+	Critical bool
+}
+
+// Validate checks that the rule has a name and that its expressions can be parsed.
+func (a AlertRule) Validate() error {
+	_, err := compileAlert(a)
+	return err
+}
+
+// WithAlerts sets the rules that raise alerts based on the routine's values. Rules that fail to
+// validate (see AlertRule.Validate) are logged and left out.
+func WithAlerts(rules ...AlertRule) RoutineOption {
+	return func(r *routine) {
+		compiled := make([]*alertRule, 0, len(rules))
+		for _, rule := range rules {
+			c, err := compileAlert(rule)
+			if err != nil {
+				r.log(LevelError, "Invalid alert rule", "error", err)
+				continue
+			}
+			compiled = append(compiled, c)
+		}
+		r.setAlerts(compiled)
+	}
+}
+
+// Alert is an alert that was raised by an AlertRule.
+type Alert struct {
+	// Module name of the routine that raised the alert.
+	Routine string
+
+	// Name of the rule that raised the alert.
+	Name string
+
+	// Short description of the alert, made of the routine's display name and the rule's name.
+	Summary string
+
+	// Text of the alert.
+	Message string
+
+	// Whether or not the alert is critical.
+	Critical bool
+
+	// Time that the rule's When expression became true.
+	Since time.Time
+
+	// Time that the alert was cleared. This is zero while the alert is raised.
+	Cleared time.Time
+}
+
+// AlertNotifier sends alerts somewhere, such as to the desktop's notifications. Alert notifiers are
+// added with AddAlertNotifier.
+type AlertNotifier interface {
+	// Notify sends the alert. It is called once when the alert is raised and once when it is cleared
+	// (see Alert.Cleared). The returned error will be logged by the engine.
+	Notify(alert Alert) error
+}
+
+// AddAlertNotifier adds a notifier that every raised and cleared alert is sent to. Notifiers are
+// called in their own goroutines, so a slow notifier doesn't hold up the routine that raised the
+// alert. This must be called before Run.
+func (sb *Statusbar) AddAlertNotifier(n AlertNotifier) {
+	if sb != nil && n != nil {
+		sb.mutex.Lock()
+		defer sb.mutex.Unlock()
+		sb.notifiers = append(sb.notifiers, n)
+	}
+}
+
+// sendAlert sends the alert to every alert notifier.
+func (sb *Statusbar) sendAlert(alert Alert) {
+	sb.mutex.RLock()
+	notifiers := sb.notifiers
+	sb.mutex.RUnlock()
+
+	for _, n := range notifiers {
+		go func(n AlertNotifier) {
+			if err := n.Notify(alert); err != nil {
+				logAt(LevelError, "Failed to send alert", "routine", alert.Routine, "alert", alert.Name, "error", err)
+			}
+		}(n)
+	}
+}
+
+// alertRule is an AlertRule with its expressions parsed, along with the state of its alert.
+type alertRule struct {
+	rule  AlertRule
+	when  expression
+	clear expression
+
+	// Time that the When expression became true. This is zero while it is false.
+	since time.Time
+
+	// Whether or not the alert is raised.
+	raised bool
+}
+
+// compileAlert checks and parses the rule.
+func compileAlert(rule AlertRule) (*alertRule, error) {
+	if rule.Name == "" {
+		return nil, fmt.Errorf("missing alert name")
+	}
+	if rule.When == "" {
+		return nil, fmt.Errorf("alert %s: missing expression", rule.Name)
+	}
+	if rule.For < 0 {
+		return nil, fmt.Errorf("alert %s: duration cannot be negative", rule.Name)
+	}
+
+	c := alertRule{rule: rule}
+	var err error
+	if c.when, err = parseExpression(rule.When); err != nil {
+		return nil, fmt.Errorf("alert %s: %w", rule.Name, err)
+	}
+	if rule.ClearWhen != "" {
+		if c.clear, err = parseExpression(rule.ClearWhen); err != nil {
+			return nil, fmt.Errorf("alert %s: %w", rule.Name, err)
+		}
+	}
+
+	return &c, nil
+}
+
+// check checks the rule against the values of routine r from an update that finished at now. If the
+// update raised or cleared the alert, check returns the alert.
+func (a *alertRule) check(r *routine, now time.Time, values []value.Value) *Alert {
+	when := a.when.eval(values)
+
+	if a.raised {
+		if (a.clear != nil && a.clear.eval(values)) || (a.clear == nil && !when) {
+			alert := a.alert(r)
+			alert.Cleared = now
+			a.raised = false
+			a.since = time.Time{}
+			return &alert
+		}
+		return nil
+	}
+
+	if !when {
+		a.since = time.Time{}
+		return nil
+	}
+	if a.since.IsZero() {
+		a.since = now
+	}
+	if now.Sub(a.since) >= a.rule.For {
+		a.raised = true
+		alert := a.alert(r)
+		return &alert
+	}
+
+	return nil
+}
+
+// alert returns the rule's alert for routine r.
+func (a *alertRule) alert(r *routine) Alert {
+	message := a.rule.Message
+	if message == "" {
+		message = a.rule.When
+	}
+
+	return Alert{
+		Routine:  r.moduleName(),
+		Name:     a.rule.Name,
+		Summary:  r.displayName() + ": " + a.rule.Name,
+		Message:  message,
+		Critical: a.rule.Critical,
+		Since:    a.since,
+	}
+}
+
+// keepAlertState carries over the state of the old rules to the new rules that are the same, so
+// that a reload doesn't raise an alert again or forget that it was raised.
+func keepAlertState(rules []*alertRule, old []*alertRule) {
+	for _, rule := range rules {
+		for _, o := range old {
+			if reflect.DeepEqual(rule.rule, o.rule) {
+				rule.since, rule.raised = o.since, o.raised
+				break
+			}
+		}
+	}
+}
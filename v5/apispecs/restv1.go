@@ -66,6 +66,26 @@ var RESTV1 = `
 								"active": {
 									"type": "boolean",
 									"description": "Whether or not routine is currently active"
+								},
+								"failures": {
+									"type": "number",
+									"description": "Number of updates in a row that have failed"
+								},
+								"next_retry": {
+									"type": "string",
+									"description": "Time of the next retry after a failed update (only if the last update failed)"
+								},
+								"restarts": {
+									"type": "number",
+									"description": "Number of times the routine has been restarted"
+								},
+								"visible": {
+									"type": "boolean",
+									"description": "Whether or not the routine's visibility rules let it be shown"
+								},
+								"slot": {
+									"type": "string",
+									"description": "Name of the slot that the routine takes turns in (only if it has one)"
 								}
 							}
 						}
@@ -93,6 +113,26 @@ var RESTV1 = `
 							"active": {
 								"type": "boolean",
 								"description": "Whether or not routine is currently active"
+							},
+							"failures": {
+								"type": "number",
+								"description": "Number of updates in a row that have failed"
+							},
+							"next_retry": {
+								"type": "string",
+								"description": "Time of the next retry after a failed update (only if the last update failed)"
+							},
+							"restarts": {
+								"type": "number",
+								"description": "Number of times the routine has been restarted"
+							},
+							"visible": {
+								"type": "boolean",
+								"description": "Whether or not the routine's visibility rules let it be shown"
+							},
+							"slot": {
+								"type": "string",
+								"description": "Name of the slot that the routine takes turns in (only if it has one)"
 							}
 						}
 					},
@@ -125,6 +165,80 @@ var RESTV1 = `
 					"callback": "HandlePatchRoutine"
 				},
 
+				{
+					"method": "POST",
+					"url": "/routines",
+					"description": "Start all stopped routines.",
+					"callback": "HandlePostRoutineAll"
+				},
+				{
+					"method": "POST",
+					"url": "/routines/:routine",
+					"description": "Start the specified routine if it is stopped.",
+					"callback": "HandlePostRoutine"
+				},
+
+				{
+					"method": "GET",
+					"url": "/routines/:routine/visibility",
+					"description": "Get the visibility rules of the specified routine.",
+					"response": {
+						"rules": {
+							"type": "array",
+							"description": "Name, hide_when, show_when, and enabled of each rule"
+						}
+					},
+					"callback": "HandleGetRoutineVisibility"
+				},
+				{
+					"method": "PUT",
+					"url": "/routines/:routine/visibility/:rule",
+					"description": "Turn the specified visibility rule on or off.",
+					"request": {
+						"enabled": {
+							"type": "boolean",
+							"description": "Whether or not the rule is turned on"
+						}
+					},
+					"callback": "HandlePutRoutineVisibilityRule"
+				},
+				{
+					"method": "GET",
+					"url": "/routines/:routine/history",
+					"description": "Get the history of the specified routine's numeric values.",
+					"response": {
+						"history": {
+							"type": "object",
+							"description": "Depth, resolution in seconds, unit of each value, and samples from oldest to newest"
+						}
+					},
+					"callback": "HandleGetRoutineHistory"
+				},
+				{
+					"method": "GET",
+					"url": "/routines/:routine/values",
+					"description": "Get the values that the specified routine reported with its latest output.",
+					"response": {
+						"values": {
+							"type": "array",
+							"description": "Name, kind, value, unit, and labels of each value"
+						}
+					},
+					"callback": "HandleGetRoutineValues"
+				},
+				{
+					"method": "GET",
+					"url": "/routines/:routine/logs",
+					"description": "Get the recent log messages about the specified routine.",
+					"response": {
+						"logs": {
+							"type": "array",
+							"description": "Time, level, message, and fields of each log message, from oldest to newest"
+						}
+					},
+					"callback": "HandleGetRoutineLogs"
+				},
+
 				{
 					"method": "DELETE",
 					"url": "/routines",
@@ -138,6 +252,126 @@ var RESTV1 = `
 					"callback": "HandleDeleteRoutine"
 				}
 			]
+		},
+		{
+			"name": "slots",
+			"description": "Endpoints related to the slots that routines take turns in",
+			"endpoints": [
+				{
+					"method": "GET",
+					"url": "/slots",
+					"description": "Get every slot and the member that it is showing.",
+					"response": {
+						"slots": {
+							"type": "array",
+							"description": "Name, period, pin, members, current member, and whether the current member is pinned"
+						}
+					},
+					"callback": "HandleGetSlotAll"
+				}
+			]
+		},
+		{
+			"name": "alerts",
+			"description": "Endpoints related to the alerts that routines raise",
+			"endpoints": [
+				{
+					"method": "GET",
+					"url": "/alerts",
+					"description": "Get every alert that is raised right now.",
+					"response": {
+						"alerts": {
+							"type": "array",
+							"description": "Routine, name, summary, message, whether the alert is critical, and when its condition started"
+						}
+					},
+					"callback": "HandleGetAlertAll"
+				}
+			]
+		},
+		{
+			"name": "layout",
+			"description": "Endpoints related to the order of the routines on the bar",
+			"endpoints": [
+				{
+					"method": "GET",
+					"url": "/layout",
+					"description": "Get the order of the routines, the split, and the regions.",
+					"response": {
+						"routines": {
+							"type": "array",
+							"description": "Names of the routines, in display order"
+						},
+						"split": {
+							"type": "number",
+							"description": "Index of the routine after which the bar is split, or -1"
+						},
+						"regions": {
+							"type": "array",
+							"description": "Name, alignment, and routine indexes of each region, if any were added"
+						}
+					},
+					"callback": "HandleGetLayout"
+				},
+				{
+					"method": "POST",
+					"url": "/layout/routines",
+					"description": "Create a routine from a registered module and add it to the bar.",
+					"request": {
+						"module": {
+							"type": "string",
+							"description": "Name of the routine's module"
+						},
+						"interval": {
+							"type": "number",
+							"description": "Update interval, in seconds"
+						},
+						"position": {
+							"type": "number",
+							"description": "Index to insert the routine at (default is the end)"
+						},
+						"region": {
+							"type": "string",
+							"description": "Name of the region to display the routine in"
+						},
+						"options": {
+							"type": "object",
+							"description": "Options for the module"
+						}
+					},
+					"callback": "HandlePostLayoutRoutine"
+				},
+				{
+					"method": "PATCH",
+					"url": "/layout/routines/:routine",
+					"description": "Move the specified routine to a new position.",
+					"request": {
+						"position": {
+							"type": "number",
+							"description": "New index of the routine"
+						}
+					},
+					"callback": "HandlePatchLayoutRoutine"
+				},
+				{
+					"method": "DELETE",
+					"url": "/layout/routines/:routine",
+					"description": "Stop the specified routine and remove it from the bar.",
+					"callback": "HandleDeleteLayoutRoutine"
+				},
+				{
+					"method": "PUT",
+					"url": "/layout/split",
+					"description": "Move the split.",
+					"request": {
+						"split": {
+							"type": "number",
+							"description": "Index of the routine after which to split the bar, or -1 for no split"
+						}
+					},
+					"callback": "HandlePutLayoutSplit"
+				}
+			]
 		}
 	]
 }
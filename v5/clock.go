@@ -0,0 +1,51 @@
+// This file holds the clock that the engine reads the time from.
+
+package statusbar
+
+import (
+	"time"
+)
+
+// Clock tells the engine what time it is. The engine reads the clock for everything that depends on
+// the time of day: slot rotations, marquee scrolling, histories, alerts, retries, logs, and uptimes.
+// The time between a routine's updates and the timeout of each update always follow the system's
+// clock. A fake clock can be set with SetClock to test how a statusbar behaves over time (see the
+// statusbartest package).
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// systemClock is the Clock that reads the system's time.
+type systemClock struct{}
+
+// Now returns the system's current time.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SetClock sets the clock that the statusbar and its routines read the time from. By default, the
+// system's clock is used. This must be called before any routines or slots are added.
+func (sb *Statusbar) SetClock(c Clock) {
+	if sb != nil && c != nil {
+		sb.mutex.Lock()
+		defer sb.mutex.Unlock()
+		sb.clock = c
+	}
+}
+
+// now returns the current time from the statusbar's clock.
+func (sb *Statusbar) now() time.Time {
+	if sb.clock == nil {
+		return time.Now()
+	}
+	return sb.clock.Now()
+}
+
+// now returns the current time from the routine's clock.
+func (r *routine) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock.Now()
+}
@@ -0,0 +1,122 @@
+// Command statusbar runs a statusbar that is built from a configuration file, so that no Go code
+// needs to be written or compiled to set up the bar. Every module in this repository is included.
+//
+// Usage:
+//
+//	statusbar [-config path] [-check] [-modules]
+//
+// If -config is not given, the first file found out of config.toml, config.yaml, config.yml, and
+// config.json in $XDG_CONFIG_HOME/statusbar (usually ~/.config/statusbar) is used. With -check, the
+// configuration is only checked for errors, and the statusbar is not run. With -modules, the
+// available modules are listed. See the config package for the format of the file.
+//
+// Sending SIGHUP to the program reloads the configuration file. Routines whose settings didn't change
+// keep running, and if the file has an error, the error is logged and the current bar is kept.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snhilde/statusbar/v5"
+	"github.com/snhilde/statusbar/v5/config"
+	"github.com/snhilde/statusbar/v5/registry"
+
+	// Register every module in this repository.
+	_ "github.com/snhilde/statusbar/v5/sbbattery"
+	_ "github.com/snhilde/statusbar/v5/sbcputemp"
+	_ "github.com/snhilde/statusbar/v5/sbcpuusage"
+	_ "github.com/snhilde/statusbar/v5/sbdisk"
+	_ "github.com/snhilde/statusbar/v5/sbfan"
+	_ "github.com/snhilde/statusbar/v5/sbgithubclones"
+	_ "github.com/snhilde/statusbar/v5/sbload"
+	_ "github.com/snhilde/statusbar/v5/sbnetwork"
+	_ "github.com/snhilde/statusbar/v5/sbnordvpn"
+	_ "github.com/snhilde/statusbar/v5/sbram"
+	_ "github.com/snhilde/statusbar/v5/sbtime"
+	_ "github.com/snhilde/statusbar/v5/sbtodo"
+	_ "github.com/snhilde/statusbar/v5/sbtravisci"
+	_ "github.com/snhilde/statusbar/v5/sbvolume"
+	_ "github.com/snhilde/statusbar/v5/sbweather"
+)
+
+func main() {
+	path := flag.String("config", "", "path to the configuration file")
+	check := flag.Bool("check", false, "check the configuration file for errors and exit")
+	modules := flag.Bool("modules", false, "list the available modules and exit")
+	flag.Parse()
+
+	if *modules {
+		fmt.Println(strings.Join(registry.Names(), "\n"))
+		return
+	}
+
+	if *path == "" {
+		p, err := defaultPath()
+		if err != nil {
+			fail(err)
+		}
+		*path = p
+	}
+
+	c, err := config.Load(*path)
+	if err != nil {
+		fail(err)
+	}
+
+	if *check {
+		fmt.Printf("%s: OK\n", *path)
+		return
+	}
+
+	bar, err := c.Build()
+	if err != nil {
+		fail(err)
+	}
+	statusbar.SetLogger(c.Logger())
+
+	// Reload the configuration file whenever we receive SIGHUP. The logger is only replaced once the
+	// new bar has been built, so a file with a mistake doesn't change it.
+	bar.SetReloader(func() (*statusbar.Statusbar, error) {
+		c, err := config.Load(*path)
+		if err != nil {
+			return nil, err
+		}
+		next, err := c.Build()
+		if err != nil {
+			return nil, err
+		}
+		statusbar.SetLogger(c.Logger())
+		return next, nil
+	})
+
+	bar.Run()
+}
+
+// defaultPath returns the path of the first configuration file found in the user's configuration
+// directory.
+func defaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "statusbar")
+
+	for _, name := range []string{"config.toml", "config.yaml", "config.yml", "config.json"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("no configuration file found in %s", dir)
+}
+
+// fail prints err and exits.
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "statusbar: %s\n", err.Error())
+	os.Exit(1)
+}
@@ -0,0 +1,949 @@
+// Package config builds a statusbar from a configuration file instead of Go code.
+//
+// A configuration file can be written in TOML, YAML, or JSON. It sets the statusbar's markers,
+// markup, theme, sinks, alert notifiers, and REST API, and it lists the routines to display, in
+// order. Each routine names its module, which must be registered with the registry package
+// (importing the module is enough), along with its interval and any options for the module. This is
+// a sample configuration in TOML:
+//
+//	markers = ["[", "]"]
+//	theme = "gruvbox"
+//	statuscmd = true
+//	log_level = "warn"
+//
+//	[rest]
+//	port = 1234
+//	metrics = true
+//	request_log = "off"
+//
+//	[[sinks]]
+//	type = "x11"
+//
+//	[[regions]]
+//	name = "main"
+//
+//	[[regions]]
+//	name = "status"
+//	separator = " | "
+//	markers = ["", ""]
+//	align = "right"
+//
+//	[[routines]]
+//	module = "sbtime"
+//	interval = 1
+//	options = { format = "Jan 2 - 03:04" }
+//
+//	[[routines]]
+//	module = "sbdisk"
+//	interval = 5
+//	theme = ["#FFFFFF", "#BB4F2E", "#A1273E"]
+//	region = "status"
+//	options = { paths = ["/", "/home"] }
+//
+//	[[routines]]
+//	module = "sbcputemp"
+//	interval = 1
+//	history = { depth = 60, resolution = "1m" }
+//
+//	[[routines]]
+//	module = "sbtodo"
+//	interval = 5
+//	marquee = { width = 30, step = "200ms" }
+//	options = { path = "/home/user/.todo" }
+//
+//	[[routines]]
+//	module = "sbbattery"
+//	interval = 30
+//	visibility = [{ name = "full", hide_when = "ac && perc >= 100" }]
+//	alerts = [{ name = "low", when = "perc < 10 && !ac", clear_when = "perc >= 15 || ac", critical = true }]
+//
+//	[[notifiers]]
+//	type = "dbus"
+//
+//	[[slots]]
+//	name = "online"
+//	period = "10s"
+//	pin = true
+//
+//	[[routines]]
+//	module = "sbweather"
+//	interval = 1800
+//	slot = "online"
+//	retry = { initial = "30s", max = "2h", jitter = 0.2 }
+//	restart = "on-failure"
+//	restart_delay = "1m"
+//	width = 40
+//	ellipsis = "middle"
+//	options = { lat = 40.7, lon = -74.0, key = "..." }
+//
+// Any problems in the file are reported as an *Error with the key and line of the problem.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/snhilde/statusbar/v5"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/registry"
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+// Error is a problem with a configuration file.
+type Error struct {
+	// Path of the file, if known.
+	File string
+
+	// Line of the problem, starting at 1. This is 0 if the line is not known.
+	Line int
+
+	// Full path of the key with the problem, like "routines[2].interval". This is empty if the
+	// problem isn't with a particular key.
+	Key string
+
+	// Description of the problem.
+	Msg string
+}
+
+// Error formats the problem like "config.toml:12: routines[2].interval: expected integer, found
+// string", or "line 12: ..." if the file isn't known.
+func (e *Error) Error() string {
+	b := new(strings.Builder)
+	switch {
+	case e.File != "" && e.Line > 0:
+		fmt.Fprintf(b, "%s:%d: ", e.File, e.Line)
+	case e.File != "":
+		fmt.Fprintf(b, "%s: ", e.File)
+	case e.Line > 0:
+		fmt.Fprintf(b, "line %d: ", e.Line)
+	}
+	if e.Key != "" {
+		b.WriteString(e.Key + ": ")
+	}
+	b.WriteString(e.Msg)
+
+	return b.String()
+}
+
+// Config is the configuration for a statusbar.
+type Config struct {
+	// Left and right markers around each routine's output. The default is "[" and "]".
+	Markers []string `config:"markers"`
+
+	// Name of the markup to use: "status2d" (the default), "pango", "lemonbar", "ansi", or "plain".
+	Markup string `config:"markup"`
+
+	// Default theme for every routine.
+	Theme theme.Theme `config:"theme"`
+
+	// Whether or not routines can be clicked through dwm's statuscmd patch (see
+	// statusbar.Statusbar.EnableStatusCmd).
+	StatusCmd bool `config:"statuscmd"`
+
+	// Lowest level of the log messages that are written to stderr: "debug", "info" (the default),
+	// "warn", or "error".
+	LogLevel string `config:"log_level"`
+
+	// Settings for the REST API.
+	REST REST `config:"rest"`
+
+	// Destinations for the statusbar. If this is empty, the statusbar's default sink is used.
+	Sinks []Sink `config:"sinks"`
+
+	// Destinations for the alerts that routines raise.
+	Notifiers []Notifier `config:"notifiers"`
+
+	// Regions that the routines are divided into, in the order they are displayed. If this is empty,
+	// the routines are displayed together, split after any routine that sets "split".
+	Regions []Region `config:"regions"`
+
+	// Slots that routines can take turns in.
+	Slots []Slot `config:"slots"`
+
+	// Routines to display, in order.
+	Routines []Routine `config:"routines"`
+
+	// Path of the file this configuration was loaded from, if any.
+	file string
+}
+
+// REST holds the settings for the REST API.
+type REST struct {
+	// Port to run the REST API on. The REST API is disabled if this is 0.
+	Port int `config:"port"`
+
+	// Whether or not metrics are served in Prometheus's text format at /metrics on the REST API's
+	// port (see statusbar.Statusbar.EnableMetrics).
+	Metrics bool `config:"metrics"`
+
+	// Where requests to the REST API are logged: "stdout" (the default), "stderr", or "off".
+	RequestLog string `config:"request_log"`
+}
+
+// Sink is a destination for the statusbar's output.
+type Sink struct {
+	// Type of the sink: "x11", "stdout", "i3bar", "fifo", or "file".
+	Type string `config:"type,required"`
+
+	// Path of the named pipe or file, for the "fifo" and "file" types.
+	Path string `config:"path"`
+
+	// Line of the sink in the file.
+	line int
+}
+
+// Notifier is a destination for the alerts that routines raise.
+type Notifier struct {
+	// Type of the notifier: "notify-send", "dbus", or "webhook".
+	Type string `config:"type,required"`
+
+	// Command to run for the "notify-send" type. The default is "notify-send".
+	Command string `config:"command"`
+
+	// URL to post alerts to, for the "webhook" type.
+	URL string `config:"url"`
+}
+
+// Region is a named part of the statusbar. See statusbar.Region for how each setting is used.
+type Region struct {
+	// Name of the region, which routines use to place themselves in it.
+	Name string `config:"name,required"`
+
+	// Text between the output of each routine in the region. The default is a single space.
+	Separator string `config:"separator"`
+
+	// Left and right markers around each routine's output in the region. If this is empty, the
+	// statusbar's markers are used.
+	Markers []string `config:"markers"`
+
+	// Where the region is placed on the bar: "left" (the default), "center", or "right".
+	Align string `config:"align"`
+}
+
+// Slot is a place on the statusbar that several routines take turns in. See statusbar.Slot for how
+// each setting is used.
+type Slot struct {
+	// Name of the slot, which routines use to join it.
+	Name string `config:"name,required"`
+
+	// How long each routine is shown, either as a string like "10s" or as a number of seconds. The
+	// default is 5 seconds.
+	Period time.Duration `config:"period"`
+
+	// Whether or not routines in the warning or error state stay shown until they go back to normal.
+	Pin bool `config:"pin"`
+}
+
+// Routine is a single routine on the statusbar.
+type Routine struct {
+	// Name of the routine's module, like "sbtime".
+	Module string `config:"module,required"`
+
+	// Seconds between each update of the routine. If this is 0, the routine only runs once.
+	Interval int `config:"interval,required"`
+
+	// Longest that a single update can run, either as a string like "10s" or as a number of seconds.
+	// If this is 0, updates can run indefinitely. If this is nil, the statusbar's default timeout is
+	// used.
+	Timeout *time.Duration `config:"timeout"`
+
+	// Whether or not to split the statusbar after this routine, as with Statusbar.Split. This can't be
+	// used with regions.
+	Split bool `config:"split"`
+
+	// Name of the region to display the routine in. If this is empty, the routine is displayed in the
+	// first region.
+	Region string `config:"region"`
+
+	// Name of the slot that the routine takes turns in, if any.
+	Slot string `config:"slot"`
+
+	// How to back off after failed updates. If this is nil, the statusbar's default policy is used.
+	Retry *Retry `config:"retry"`
+
+	// Whether or not to start the routine again after it stops: "never" (the default), "on-failure", or
+	// "always".
+	Restart string `config:"restart"`
+
+	// Time to wait before restarting the routine, either as a string like "10s" or as a number of
+	// seconds.
+	RestartDelay time.Duration `config:"restart_delay"`
+
+	// Most columns that the routine's output can take up. If this is 0, there is no limit. If this is
+	// nil, the statusbar's default width is used.
+	Width *int `config:"width"`
+
+	// Where to put the ellipsis when the output is shortened: "end" (the default), "middle", or
+	// "start".
+	Ellipsis string `config:"ellipsis"`
+
+	// Scrolling window for the routine's output, which takes the place of the width. If this is nil,
+	// the output doesn't scroll.
+	Marquee *Marquee `config:"marquee"`
+
+	// How much of the history of the routine's numeric values to keep. If this is nil, no history is
+	// kept.
+	History *History `config:"history"`
+
+	// Theme for this routine. Any colors not set here are inherited from the statusbar's theme.
+	Theme theme.Theme `config:"theme"`
+
+	// Rules that decide whether or not the routine is shown on the bar.
+	Visibility []Visibility `config:"visibility"`
+
+	// Rules that raise alerts based on the routine's values.
+	Alerts []Alert `config:"alerts"`
+
+	// Options for the module, as returned by the module's registered Options function and filled in
+	// from the "options" key. This is nil if the module doesn't have any options.
+	Options interface{} `config:"-"`
+
+	// Line of the routine in the file.
+	line int
+}
+
+// Marquee scrolls a routine's output. See statusbar.Marquee for how each setting is used.
+type Marquee struct {
+	// Width of the window, in columns.
+	Width int `config:"width,required"`
+
+	// Time between each step of one column, either as a string like "250ms" or as a number of seconds.
+	Step time.Duration `config:"step"`
+
+	// Text between the end of the output and its start.
+	Gap string `config:"gap"`
+}
+
+// History is how much of a routine's history to keep. See statusbar.History for how each setting is
+// used.
+type History struct {
+	// Most samples to keep.
+	Depth int `config:"depth,required"`
+
+	// Shortest time between samples, either as a string like "1m" or as a number of seconds.
+	Resolution time.Duration `config:"resolution"`
+}
+
+// Visibility is a rule that hides a routine. See statusbar.VisibilityRule for how the expressions
+// are written.
+type Visibility struct {
+	// Name of the rule, which is used to turn the rule on and off through the REST API.
+	Name string `config:"name,required"`
+
+	// Expression that hides the routine when it is true.
+	HideWhen string `config:"hide_when"`
+
+	// Expression that hides the routine when it is false.
+	ShowWhen string `config:"show_when"`
+}
+
+// Alert is a rule that raises an alert. See statusbar.AlertRule for how each setting is used.
+type Alert struct {
+	// Name of the rule, which identifies its alert.
+	Name string `config:"name,required"`
+
+	// Expression that raises the alert when it is true.
+	When string `config:"when,required"`
+
+	// Expression that clears the alert when it is true.
+	ClearWhen string `config:"clear_when"`
+
+	// How long "when" must stay true before the alert is raised, either as a string like "1m" or as a
+	// number of seconds.
+	For time.Duration `config:"for"`
+
+	// Text of the alert.
+	Message string `config:"message"`
+
+	// Whether or not the alert is critical.
+	Critical bool `config:"critical"`
+}
+
+// Retry is the retry policy for a routine. See statusbar.RetryPolicy for how each setting is used.
+type Retry struct {
+	// Time to wait after the first failure, either as a string like "10s" or as a number of seconds.
+	Initial time.Duration `config:"initial,required"`
+
+	// Longest time to wait between retries. If this is 0, there is no limit.
+	Max time.Duration `config:"max"`
+
+	// Factor that the wait is multiplied by after each failure. The default is 2.
+	Multiplier float64 `config:"multiplier"`
+
+	// Fraction of the wait (between 0 and 1) that is randomly added or removed.
+	Jitter float64 `config:"jitter"`
+
+	// Number of failures in a row after which the routine stops. If this is 0, the routine never stops.
+	MaxFailures int `config:"max_failures"`
+}
+
+// restartPolicies maps the names used in configuration files to their restart policies.
+var restartPolicies = map[string]statusbar.RestartPolicy{
+	"never":      statusbar.RestartNever,
+	"on-failure": statusbar.RestartOnFailure,
+	"always":     statusbar.RestartAlways,
+}
+
+// ellipses maps the names used in configuration files to their ellipsis placements.
+var ellipses = map[string]markup.Ellipsis{
+	"end":    markup.EllipsisEnd,
+	"middle": markup.EllipsisMiddle,
+	"start":  markup.EllipsisStart,
+}
+
+// aligns maps the names used in configuration files to their region alignments.
+var aligns = map[string]markup.Align{
+	"left":   markup.AlignLeft,
+	"center": markup.AlignCenter,
+	"right":  markup.AlignRight,
+}
+
+// requestLogs maps the names used in configuration files to the writers that REST API requests are
+// logged to.
+var requestLogs = map[string]io.Writer{
+	"stdout": os.Stdout,
+	"stderr": os.Stderr,
+	"off":    nil,
+}
+
+// markups maps the names used in configuration files to their markups.
+var markups = map[string]markup.Markup{
+	"status2d": markup.Status2d,
+	"pango":    markup.Pango,
+	"lemonbar": markup.Lemonbar,
+	"ansi":     markup.ANSI,
+	"plain":    markup.Plain,
+}
+
+// Load reads and parses the configuration file at path. The format of the file is determined by
+// its extension, which must be .toml, .yaml, .yml, or .json.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(path), ".")
+	c, err := Parse(data, format)
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			e.File = path
+		}
+		return nil, err
+	}
+	c.file = path
+
+	return c, nil
+}
+
+// Parse parses a configuration in the specified format ("toml", "yaml", or "json").
+func Parse(data []byte, format string) (*Config, error) {
+	root, err := parse(data, strings.ToLower(format))
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(Config)
+	if err := decode(root, reflect.ValueOf(c).Elem(), ""); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// decode decodes the configuration and checks the settings that have a fixed set of values.
+func (c *Config) decode(n *node, key string) error {
+	if err := decodeStruct(n, reflect.ValueOf(c).Elem(), key); err != nil {
+		return err
+	}
+
+	if len(c.Markers) != 0 && len(c.Markers) != 2 {
+		return fieldError(n, key, "markers", "expected left and right markers")
+	}
+
+	if _, ok := markups[c.Markup]; c.Markup != "" && !ok {
+		return fieldError(n, key, "markup", "unknown markup %q", c.Markup)
+	}
+
+	if c.REST.Port < 0 || c.REST.Port > 65535 {
+		return fieldError(n.fields["rest"], "rest", "port", "invalid port")
+	}
+	if c.REST.Metrics && c.REST.Port == 0 {
+		return fieldError(n.fields["rest"], "rest", "metrics", "metrics need a port")
+	}
+	if _, ok := requestLogs[c.REST.RequestLog]; c.REST.RequestLog != "" && !ok {
+		return fieldError(n.fields["rest"], "rest", "request_log", "unknown request log %q", c.REST.RequestLog)
+	}
+
+	if _, err := statusbar.ParseLevel(c.LogLevel); c.LogLevel != "" && err != nil {
+		return fieldError(n, key, "log_level", "%s", err.Error())
+	}
+
+	if err := c.checkRegions(n); err != nil {
+		return err
+	}
+
+	return c.checkSlots(n)
+}
+
+// checkRegions makes sure that the region names are unique and that every routine is placed in a
+// region that exists.
+func (c *Config) checkRegions(n *node) error {
+	names := make(map[string]bool, len(c.Regions))
+	for i, region := range c.Regions {
+		if names[region.Name] {
+			key := fmt.Sprintf("regions[%d]", i)
+			return fieldError(n.fields["regions"].items[i], key, "name", "duplicate region %q", region.Name)
+		}
+		names[region.Name] = true
+	}
+
+	for i, r := range c.Routines {
+		key := fmt.Sprintf("routines[%d]", i)
+		switch {
+		case r.Region != "" && !names[r.Region]:
+			return fieldError(n.fields["routines"].items[i], key, "region", "unknown region %q", r.Region)
+		case r.Split && len(c.Regions) > 0:
+			return fieldError(n.fields["routines"].items[i], key, "split", "split cannot be used with regions")
+		}
+	}
+
+	return nil
+}
+
+// checkSlots makes sure that the slot names are unique and that every routine joins a slot that
+// exists.
+func (c *Config) checkSlots(n *node) error {
+	names := make(map[string]bool, len(c.Slots))
+	for i, s := range c.Slots {
+		key := fmt.Sprintf("slots[%d]", i)
+		switch {
+		case names[s.Name]:
+			return fieldError(n.fields["slots"].items[i], key, "name", "duplicate slot %q", s.Name)
+		case s.Period < 0:
+			return fieldError(n.fields["slots"].items[i], key, "period", "period cannot be negative")
+		}
+		names[s.Name] = true
+	}
+
+	for i, r := range c.Routines {
+		if r.Slot != "" && !names[r.Slot] {
+			key := fmt.Sprintf("routines[%d]", i)
+			return fieldError(n.fields["routines"].items[i], key, "slot", "unknown slot %q", r.Slot)
+		}
+	}
+
+	return nil
+}
+
+// decode decodes a region and checks its markers and alignment.
+func (r *Region) decode(n *node, key string) error {
+	if err := decodeStruct(n, reflect.ValueOf(r).Elem(), key); err != nil {
+		return err
+	}
+
+	if len(r.Markers) != 0 && len(r.Markers) != 2 {
+		return fieldError(n, key, "markers", "expected left and right markers")
+	}
+
+	if _, ok := aligns[r.Align]; r.Align != "" && !ok {
+		return fieldError(n, key, "align", "unknown alignment %q", r.Align)
+	}
+
+	return nil
+}
+
+// decode decodes a sink and makes sure that it has the settings it needs.
+func (s *Sink) decode(n *node, key string) error {
+	if err := decodeStruct(n, reflect.ValueOf(s).Elem(), key); err != nil {
+		return err
+	}
+	s.line = n.line
+
+	switch s.Type {
+	case "x11", "stdout", "i3bar":
+	case "fifo", "file":
+		if s.Path == "" {
+			return fieldError(n, key, "path", "missing path for %s sink", s.Type)
+		}
+	default:
+		return fieldError(n, key, "type", "unknown sink type %q", s.Type)
+	}
+
+	return nil
+}
+
+// decode decodes a notifier and makes sure that it has the settings it needs.
+func (no *Notifier) decode(n *node, key string) error {
+	if err := decodeStruct(n, reflect.ValueOf(no).Elem(), key); err != nil {
+		return err
+	}
+
+	switch no.Type {
+	case "notify-send", "dbus":
+	case "webhook":
+		if no.URL == "" {
+			return fieldError(n, key, "url", "missing url for webhook notifier")
+		}
+	default:
+		return fieldError(n, key, "type", "unknown notifier type %q", no.Type)
+	}
+
+	return nil
+}
+
+// decode decodes a marquee and checks that its settings are in range.
+func (m *Marquee) decode(n *node, key string) error {
+	if err := decodeStruct(n, reflect.ValueOf(m).Elem(), key); err != nil {
+		return err
+	}
+
+	switch {
+	case m.Width <= 0:
+		return fieldError(n, key, "width", "width must be positive")
+	case m.Step < 0:
+		return fieldError(n, key, "step", "step cannot be negative")
+	}
+
+	return nil
+}
+
+// decode decodes a history and checks that its settings are in range.
+func (h *History) decode(n *node, key string) error {
+	if err := decodeStruct(n, reflect.ValueOf(h).Elem(), key); err != nil {
+		return err
+	}
+
+	switch {
+	case h.Depth <= 0:
+		return fieldError(n, key, "depth", "depth must be positive")
+	case h.Resolution < 0:
+		return fieldError(n, key, "resolution", "resolution cannot be negative")
+	}
+
+	return nil
+}
+
+// decode decodes a visibility rule and checks its expressions.
+func (v *Visibility) decode(n *node, key string) error {
+	if err := decodeStruct(n, reflect.ValueOf(v).Elem(), key); err != nil {
+		return err
+	}
+
+	if err := v.rule().Validate(); err != nil {
+		return &Error{Line: n.line, Key: key, Msg: err.Error()}
+	}
+
+	return nil
+}
+
+// decode decodes an alert rule and checks its expressions.
+func (a *Alert) decode(n *node, key string) error {
+	if err := decodeStruct(n, reflect.ValueOf(a).Elem(), key); err != nil {
+		return err
+	}
+
+	if err := a.rule().Validate(); err != nil {
+		return &Error{Line: n.line, Key: key, Msg: err.Error()}
+	}
+
+	return nil
+}
+
+// decode decodes a retry policy and checks that its settings are in range.
+func (r *Retry) decode(n *node, key string) error {
+	r.Multiplier = 2
+	if err := decodeStruct(n, reflect.ValueOf(r).Elem(), key); err != nil {
+		return err
+	}
+
+	switch {
+	case r.Initial <= 0:
+		return fieldError(n, key, "initial", "initial wait must be positive")
+	case r.Max < 0:
+		return fieldError(n, key, "max", "maximum wait cannot be negative")
+	case r.Multiplier < 1:
+		return fieldError(n, key, "multiplier", "multiplier must be at least 1")
+	case r.Jitter < 0 || r.Jitter > 1:
+		return fieldError(n, key, "jitter", "jitter must be between 0 and 1")
+	case r.MaxFailures < 0:
+		return fieldError(n, key, "max_failures", "maximum failures cannot be negative")
+	}
+
+	return nil
+}
+
+// decode decodes a routine, looks up its module, and decodes the module's options.
+func (r *Routine) decode(n *node, key string) error {
+	if err := decodeStruct(n, reflect.ValueOf(r).Elem(), key, "options"); err != nil {
+		return err
+	}
+	r.line = n.line
+
+	module, ok := registry.Lookup(r.Module)
+	if !ok {
+		return fieldError(n, key, "module", "unknown module %q", r.Module)
+	}
+
+	if r.Interval < 0 {
+		return fieldError(n, key, "interval", "interval cannot be negative")
+	}
+
+	if _, ok := restartPolicies[r.Restart]; r.Restart != "" && !ok {
+		return fieldError(n, key, "restart", "unknown restart policy %q", r.Restart)
+	}
+	if r.RestartDelay < 0 {
+		return fieldError(n, key, "restart_delay", "restart delay cannot be negative")
+	}
+
+	if r.Width != nil && *r.Width < 0 {
+		return fieldError(n, key, "width", "width cannot be negative")
+	}
+	if _, ok := ellipses[r.Ellipsis]; r.Ellipsis != "" && !ok {
+		return fieldError(n, key, "ellipsis", "unknown ellipsis position %q", r.Ellipsis)
+	}
+
+	options, hasOptions := n.fields["options"]
+	if module.Options == nil {
+		if hasOptions {
+			return fieldError(n, key, "options", "%s does not have any options", r.Module)
+		}
+		return nil
+	}
+
+	// Start with the module's defaults, and then fill in anything that was set in the file. If there
+	// aren't any options in the file, then we'll still need to check for required options.
+	r.Options = module.Options()
+	if !hasOptions {
+		options = newMap(n.line)
+	}
+	if options.kind != mapNode {
+		return typeError(options, joinKey(key, "options"), "table")
+	}
+
+	// The registry fills in the options the same way for every source, so we only need to point its
+	// errors at the right line.
+	err := registry.FillOptions(r.Options, options.plain().(map[string]interface{}))
+	var e *registry.OptionError
+	if errors.As(err, &e) {
+		optionsKey := joinKey(key, "options")
+		if e.Key != "" {
+			optionsKey = joinKey(optionsKey, e.Key)
+		}
+		return &Error{Line: options.lineOf(e.Key), Key: optionsKey, Msg: e.Msg}
+	}
+
+	return err
+}
+
+// Build creates a statusbar from the configuration. This creates every sink and routine, either of
+// which might fail.
+func (c *Config) Build() (*statusbar.Statusbar, error) {
+	sb := statusbar.New()
+
+	if len(c.Markers) == 2 {
+		sb.SetMarkers(c.Markers[0], c.Markers[1])
+	}
+	if m, ok := markups[c.Markup]; ok {
+		sb.SetMarkup(m)
+	}
+	sb.SetTheme(c.Theme)
+
+	for i, s := range c.Sinks {
+		sink, err := s.build()
+		if err != nil {
+			return nil, &Error{File: c.file, Line: s.line, Key: fmt.Sprintf("sinks[%d]", i), Msg: err.Error()}
+		}
+		sb.AddSink(sink)
+	}
+	for _, n := range c.Notifiers {
+		sb.AddAlertNotifier(n.build())
+	}
+
+	for i, r := range c.Regions {
+		region := statusbar.Region{Name: r.Name, Separator: r.Separator, Align: aligns[r.Align]}
+		if len(r.Markers) == 2 {
+			region.Markers = r.Markers
+		}
+		if err := sb.AddRegion(region); err != nil {
+			return nil, &Error{File: c.file, Key: fmt.Sprintf("regions[%d]", i), Msg: err.Error()}
+		}
+	}
+
+	for i, s := range c.Slots {
+		if err := sb.AddSlot(statusbar.Slot{Name: s.Name, Period: s.Period, Pin: s.Pin}); err != nil {
+			return nil, &Error{File: c.file, Key: fmt.Sprintf("slots[%d]", i), Msg: err.Error()}
+		}
+	}
+
+	for i, r := range c.Routines {
+		module, ok := registry.Lookup(r.Module)
+		if !ok {
+			return nil, &Error{File: c.file, Line: r.line, Key: fmt.Sprintf("routines[%d]", i), Msg: "unknown module"}
+		}
+
+		handler, err := module.New(r.Options, r.Theme)
+		if err != nil {
+			return nil, &Error{File: c.file, Line: r.line, Key: fmt.Sprintf("routines[%d]", i), Msg: err.Error()}
+		}
+
+		// The interval, timeout, retry policy, restart policy, width, marquee, history, region,
+		// visibility rules, alert rules, and slot are left out of the fingerprint, because a reload can
+		// change them on a running routine.
+		fingerprint := fmt.Sprintf("%s %+v %+v %+v", r.Module, r.Theme, c.Theme, r.Options)
+		sb.Append(handler, r.Interval, r.options(fingerprint)...)
+
+		if r.Split {
+			sb.Split()
+		}
+	}
+
+	if c.StatusCmd {
+		sb.EnableStatusCmd()
+	}
+	if c.REST.Port > 0 {
+		sb.EnableRESTAPI(c.REST.Port)
+	}
+	if c.REST.Metrics {
+		sb.EnableMetrics()
+	}
+	if w, ok := requestLogs[c.REST.RequestLog]; ok {
+		sb.SetRequestLog(w)
+	}
+
+	return &sb, nil
+}
+
+// Logger returns the logger for the level set in the configuration, which writes to stderr, or nil if
+// no level is set. The logger covers the whole program, so Build doesn't set it. Pass it to
+// statusbar.SetLogger, where nil goes back to the default logger.
+func (c *Config) Logger() statusbar.Logger {
+	level, err := statusbar.ParseLevel(c.LogLevel)
+	if err != nil {
+		return nil
+	}
+
+	return statusbar.NewLogger(os.Stderr, level)
+}
+
+// options returns the statusbar options for the routine's settings.
+func (r Routine) options(fingerprint string) []statusbar.RoutineOption {
+	options := []statusbar.RoutineOption{statusbar.WithFingerprint(fingerprint)}
+	if r.Timeout != nil {
+		options = append(options, statusbar.WithTimeout(*r.Timeout))
+	}
+	if r.Retry != nil {
+		options = append(options, statusbar.WithRetryPolicy(r.Retry.policy()))
+	}
+	if policy, ok := restartPolicies[r.Restart]; ok {
+		options = append(options, statusbar.WithRestartPolicy(policy, r.RestartDelay))
+	}
+	if r.Width != nil || r.Ellipsis != "" {
+		options = append(options, statusbar.WithWidth(r.width(), ellipses[r.Ellipsis]))
+	}
+	if r.Marquee != nil {
+		m := statusbar.Marquee{Width: r.Marquee.Width, Step: r.Marquee.Step, Gap: r.Marquee.Gap}
+		options = append(options, statusbar.WithMarquee(m))
+	}
+	if r.History != nil {
+		h := statusbar.History{Depth: r.History.Depth, Resolution: r.History.Resolution}
+		options = append(options, statusbar.WithHistory(h))
+	}
+	if r.Region != "" {
+		options = append(options, statusbar.WithRegion(r.Region))
+	}
+	if r.Slot != "" {
+		options = append(options, statusbar.WithSlot(r.Slot))
+	}
+	if len(r.Visibility) > 0 {
+		rules := make([]statusbar.VisibilityRule, len(r.Visibility))
+		for i, v := range r.Visibility {
+			rules[i] = v.rule()
+		}
+		options = append(options, statusbar.WithVisibility(rules...))
+	}
+	if len(r.Alerts) > 0 {
+		rules := make([]statusbar.AlertRule, len(r.Alerts))
+		for i, a := range r.Alerts {
+			rules[i] = a.rule()
+		}
+		options = append(options, statusbar.WithAlerts(rules...))
+	}
+
+	return options
+}
+
+// width returns the routine's width, or the default width if it isn't set.
+func (r Routine) width() int {
+	if r.Width == nil {
+		return statusbar.DefaultWidth
+	}
+
+	return *r.Width
+}
+
+// rule converts the visibility settings to a statusbar.VisibilityRule.
+func (v Visibility) rule() statusbar.VisibilityRule {
+	return statusbar.VisibilityRule{Name: v.Name, HideWhen: v.HideWhen, ShowWhen: v.ShowWhen}
+}
+
+// rule converts the alert settings to a statusbar.AlertRule.
+func (a Alert) rule() statusbar.AlertRule {
+	return statusbar.AlertRule{
+		Name:      a.Name,
+		When:      a.When,
+		ClearWhen: a.ClearWhen,
+		For:       a.For,
+		Message:   a.Message,
+		Critical:  a.Critical,
+	}
+}
+
+// policy converts the retry settings to a statusbar.RetryPolicy.
+func (r *Retry) policy() statusbar.RetryPolicy {
+	return statusbar.RetryPolicy{
+		Initial:     r.Initial,
+		Max:         r.Max,
+		Multiplier:  r.Multiplier,
+		Jitter:      r.Jitter,
+		MaxFailures: r.MaxFailures,
+	}
+}
+
+// build creates the sink.
+func (s Sink) build() (statusbar.Sink, error) {
+	switch s.Type {
+	case "x11":
+		return statusbar.NewXSink(), nil
+	case "stdout":
+		return statusbar.NewStdoutSink(), nil
+	case "i3bar":
+		return statusbar.NewI3barSink(), nil
+	case "fifo":
+		return statusbar.NewFIFOSink(s.Path)
+	case "file":
+		return statusbar.NewFileSink(s.Path), nil
+	}
+
+	return nil, fmt.Errorf("unknown sink type %q", s.Type)
+}
+
+// build creates the notifier.
+func (n Notifier) build() statusbar.AlertNotifier {
+	switch n.Type {
+	case "dbus":
+		return statusbar.NewDBusNotifier()
+	case "webhook":
+		return statusbar.NewWebhookNotifier(n.URL)
+	}
+
+	return statusbar.NewExecNotifier(n.Command)
+}
@@ -0,0 +1,267 @@
+package config_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/snhilde/statusbar/v5/config"
+	"github.com/snhilde/statusbar/v5/sbdisk"
+	"github.com/snhilde/statusbar/v5/sbram"
+	"github.com/snhilde/statusbar/v5/sbtime"
+	"github.com/snhilde/statusbar/v5/theme"
+
+	// Register the other modules used in the tests.
+	_ "github.com/snhilde/statusbar/v5/sbload"
+	_ "github.com/snhilde/statusbar/v5/sbtodo"
+)
+
+const tomlConfig = `
+markers = ["<", ">"]
+markup = "pango"
+theme = "nord"
+
+[rest]
+port = 1234
+
+[[sinks]]
+type = "file"
+path = "/tmp/statusbar"
+
+[[routines]]
+module = "sbtime"
+interval = 1
+split = true
+options = { format = "15:04" }
+
+[[routines]]
+module = "sbdisk"
+interval = 5
+timeout = "10s"
+retry = { initial = "30s", max = 600, max_failures = 10 }
+theme = ["#FFFFFF", "#BB4F2E", "#A1273E"]
+
+[routines.options]
+paths = ["/", "/home"]
+
+[[routines]]
+module = "sbram"
+interval = 5
+timeout = 0
+width = 0
+ellipsis = "middle"
+restart = "always"
+restart_delay = 30
+theme = { palette = "nord", error = { foreground = "#FF0000", background = "#000000" } }
+`
+
+const yamlConfig = `
+markers: ["<", ">"]
+markup: pango
+theme: nord
+rest:
+  port: 1234
+sinks:
+  - type: file
+    path: /tmp/statusbar
+routines:
+  - module: sbtime
+    interval: 1
+    split: true
+    options:
+      format: "15:04"
+  - module: sbdisk
+    interval: 5
+    timeout: 10
+    retry: {initial: 30s, max: 600, max_failures: 10}
+    theme: ["#FFFFFF", "#BB4F2E", "#A1273E"]
+    options:
+      paths: [/, /home]
+  - module: sbram
+    interval: 5
+    timeout: 0
+    width: 0
+    ellipsis: middle
+    restart: always
+    restart_delay: 30s
+    theme:
+      palette: nord
+      error:
+        foreground: "#FF0000"
+        background: "#000000"
+`
+
+const jsonConfig = `{
+	"markers": ["<", ">"],
+	"markup": "pango",
+	"theme": "nord",
+	"rest": {"port": 1234},
+	"sinks": [{"type": "file", "path": "/tmp/statusbar"}],
+	"routines": [
+		{"module": "sbtime", "interval": 1, "split": true, "options": {"format": "15:04"}},
+		{
+			"module": "sbdisk",
+			"interval": 5,
+			"timeout": "10s",
+			"retry": {"initial": "30s", "max": 600, "max_failures": 10},
+			"theme": ["#FFFFFF", "#BB4F2E", "#A1273E"],
+			"options": {"paths": ["/", "/home"]}
+		},
+		{
+			"module": "sbram",
+			"interval": 5,
+			"timeout": 0,
+			"width": 0,
+			"ellipsis": "middle",
+			"restart": "always",
+			"restart_delay": "30s",
+			"theme": {"palette": "nord", "error": {"foreground": "#FF0000", "background": "#000000"}}
+		}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	nord, err := theme.Palette("nord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ramTheme := nord
+	ramTheme.Error = theme.Colors{Foreground: "#FF0000", Background: "#000000"}
+
+	formats := map[string]string{"toml": tomlConfig, "yaml": yamlConfig, "json": jsonConfig}
+	for format, data := range formats {
+		c, err := config.Parse([]byte(data), format)
+		if err != nil {
+			t.Errorf("%s: failed to parse: %v", format, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(c.Markers, []string{"<", ">"}) || c.Markup != "pango" || c.Theme != nord {
+			t.Errorf("%s: bad statusbar settings: %v, %v, %v", format, c.Markers, c.Markup, c.Theme)
+		}
+		if c.REST.Port != 1234 {
+			t.Errorf("%s: expected port 1234, got %d", format, c.REST.Port)
+		}
+		if len(c.Sinks) != 1 || c.Sinks[0].Type != "file" || c.Sinks[0].Path != "/tmp/statusbar" {
+			t.Errorf("%s: bad sinks: %+v", format, c.Sinks)
+		}
+		if len(c.Routines) != 3 {
+			t.Errorf("%s: expected 3 routines, got %d", format, len(c.Routines))
+			continue
+		}
+
+		clock, disk, ram := c.Routines[0], c.Routines[1], c.Routines[2]
+		if clock.Module != "sbtime" || clock.Interval != 1 || !clock.Split {
+			t.Errorf("%s: bad sbtime routine: %+v", format, clock)
+		}
+		if o, ok := clock.Options.(*sbtime.Options); !ok || o.Format != "15:04" {
+			t.Errorf("%s: bad sbtime options: %+v", format, clock.Options)
+		}
+		if disk.Timeout == nil || *disk.Timeout != 10*time.Second || disk.Theme != theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E") {
+			t.Errorf("%s: bad sbdisk routine: %+v", format, disk)
+		}
+		retry := config.Retry{Initial: 30 * time.Second, Max: 10 * time.Minute, Multiplier: 2, MaxFailures: 10}
+		if disk.Retry == nil || *disk.Retry != retry || clock.Retry != nil {
+			t.Errorf("%s: bad retry policies: %+v, %+v", format, disk.Retry, clock.Retry)
+		}
+		if o, ok := disk.Options.(*sbdisk.Options); !ok || !reflect.DeepEqual(o.Paths, []string{"/", "/home"}) {
+			t.Errorf("%s: bad sbdisk options: %+v", format, disk.Options)
+		}
+		if o, ok := ram.Options.(*sbram.Options); !ok || o.Graph.Style != "" {
+			t.Errorf("%s: bad sbram options: %+v", format, ram.Options)
+		}
+		if ram.Theme != ramTheme || ram.Restart != "always" || ram.RestartDelay != 30*time.Second {
+			t.Errorf("%s: bad sbram routine: %+v", format, ram)
+		}
+		if ram.Width == nil || *ram.Width != 0 || ram.Ellipsis != "middle" || clock.Width != nil {
+			t.Errorf("%s: bad widths: %v, %v", format, ram.Width, clock.Width)
+		}
+		if ram.Timeout == nil || *ram.Timeout != 0 || clock.Timeout != nil {
+			t.Errorf("%s: bad timeouts: %v, %v", format, ram.Timeout, clock.Timeout)
+		}
+
+		if _, err := c.Build(); err != nil {
+			t.Errorf("%s: failed to build: %v", format, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format string
+		data   string
+		line   int
+		key    string
+	}{
+		{"toml", "markup = \"pango\"\ncolour = \"red\"\n", 2, "colour"},
+		{"toml", "[[routines]]\nmodule = \"sbtime\"\ninterval = \"1\"\n", 3, "routines[0].interval"},
+		{"toml", "[[routines]]\nmodule = \"sbtime\"\ninterval = 1\n\n[[routines]]\nmodule = \"sbnope\"\ninterval = 1\n",
+			6, "routines[1].module"},
+		{"yaml", "routines:\n  - module: sbtodo\n    interval: 5\n", 2, "routines[0].options.path"},
+		{"yaml", "routines:\n  - module: sbload\n    interval: 5\n    options:\n      size: 1\n", 5, "routines[0].options"},
+		{"yaml", "routines:\n  - module: sbram\n    interval: 5\n    options:\n      graph: {size: 1}\n", 5,
+			"routines[0].options.graph.size"},
+		{"yaml", "routines:\n  - module: sbdisk\n    interval: 5\n    options:\n      paths:\n        - /\n        - 5\n", 7,
+			"routines[0].options.paths[1]"},
+		{"yaml", "markup: blink\n", 1, "markup"},
+		{"toml", "[rest]\nmetrics = true\n", 2, "rest.metrics"},
+		{"toml", "[rest]\nport = 1234\nrequest_log = \"syslog\"\n", 3, "rest.request_log"},
+		{"toml", "statuscmd = true\nlog_level = \"loud\"\n", 2, "log_level"},
+		{"yaml", "routines:\n  - module: sbram\n    interval: 5\n    restart: sometimes\n", 4, "routines[0].restart"},
+		{"yaml", "routines:\n  - module: sbram\n    interval: 5\n    retry: {initial: 1, jitter: 2}\n", 4,
+			"routines[0].retry.jitter"},
+		{"json", "{\n\t\"sinks\": [\n\t\t{\"type\": \"fifo\"}\n\t]\n}", 3, "sinks[0].path"},
+		{"json", "{\n\t\"theme\": [\"#FFFFFF\"]\n}", 2, "theme"},
+		{"toml", "[[regions]]\nname = \"a\"\n\n[[routines]]\nmodule = \"sbtime\"\ninterval = 1\nregion = \"b\"\n", 7,
+			"routines[0].region"},
+		{"yaml", "regions:\n  - name: a\n  - name: b\n    align: top\n", 4, "regions[1].align"},
+		{"yaml", "routines:\n  - module: sbram\n    interval: 5\n    visibility:\n      - name: busy\n        hide_when: perc >\n",
+			5, "routines[0].visibility[0]"},
+		{"yaml", "routines:\n  - module: sbram\n    interval: 5\n    marquee: {step: 1}\n", 4, "routines[0].marquee.width"},
+		{"yaml", "routines:\n  - module: sbram\n    interval: 5\n    history: {depth: 0}\n", 4, "routines[0].history.depth"},
+		{"yaml", "routines:\n  - module: sbram\n    interval: 5\n    alerts:\n      - name: full\n        when: perc >= 90 &&\n",
+			5, "routines[0].alerts[0]"},
+		{"toml", "[[notifiers]]\ntype = \"webhook\"\n", 1, "notifiers[0].url"},
+		{"toml", "[[notifiers]]\ntype = \"pager\"\n", 2, "notifiers[0].type"},
+		{"toml", "[[slots]]\nname = \"a\"\n\n[[routines]]\nmodule = \"sbtime\"\ninterval = 1\nslot = \"b\"\n", 7,
+			"routines[0].slot"},
+	}
+
+	for i, test := range tests {
+		_, err := config.Parse([]byte(test.data), test.format)
+
+		var e *config.Error
+		if !errors.As(err, &e) {
+			t.Errorf("Test %d: expected config error, got %v", i, err)
+			continue
+		}
+		if e.Line != test.line || e.Key != test.key {
+			t.Errorf("Test %d: expected error at line %d for %s, got %q", i, test.line, test.key, e.Error())
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	c, err := config.Parse([]byte("log_level = \"debug\"\n"), "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Logger() == nil {
+		t.Error("Expected a logger for the debug level")
+	}
+
+	c, err = config.Parse(nil, "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Logger() != nil {
+		t.Error("Expected no logger without a level")
+	}
+}
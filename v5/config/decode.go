@@ -0,0 +1,271 @@
+// This file holds the decoder that fills in Go values from a tree of nodes.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+// decoder is implemented by types that need to decode themselves from a node, usually to do extra
+// validation that needs the node's line numbers.
+type decoder interface {
+	decode(n *node, key string) error
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	themeType    = reflect.TypeOf(theme.Theme{})
+)
+
+// decode fills in v from n. key is the full path of n in the file, used in error messages.
+func decode(n *node, v reflect.Value, key string) error {
+	if v.CanAddr() {
+		if d, ok := v.Addr().Interface().(decoder); ok {
+			return d.decode(n, key)
+		}
+	}
+
+	switch v.Type() {
+	case durationType:
+		return decodeDuration(n, v, key)
+	case themeType:
+		t, err := decodeTheme(n, key)
+		if err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s, ok := n.value.(string)
+		if !ok || n.kind != scalarNode {
+			return typeError(n, key, "string")
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		b, ok := n.value.(bool)
+		if !ok || n.kind != scalarNode {
+			return typeError(n, key, "boolean")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := n.value.(int64)
+		if !ok || n.kind != scalarNode {
+			return typeError(n, key, "integer")
+		}
+		if v.OverflowInt(i) {
+			return &Error{Line: n.line, Key: key, Msg: fmt.Sprintf("%d is out of range", i)}
+		}
+		v.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch value := n.value.(type) {
+		case float64:
+			f = value
+		case int64:
+			f = float64(value)
+		default:
+			return typeError(n, key, "number")
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if n.kind != listNode {
+			return typeError(n, key, "list")
+		}
+		list := reflect.MakeSlice(v.Type(), len(n.items), len(n.items))
+		for i, item := range n.items {
+			if err := decode(item, list.Index(i), fmt.Sprintf("%s[%d]", key, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(list)
+	case reflect.Struct:
+		return decodeStruct(n, v, key)
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decode(n, v.Elem(), key)
+	default:
+		return &Error{Line: n.line, Key: key, Msg: fmt.Sprintf("unsupported type %s", v.Type())}
+	}
+
+	return nil
+}
+
+// decodeStruct fills in the fields of the struct v from the table n. Fields are matched to keys
+// with the "config" struct tag, like `config:"name"` or `config:"name,required"`. Fields without
+// the tag or with a tag of "-" are skipped, as are any keys listed in skip.
+func decodeStruct(n *node, v reflect.Value, key string, skip ...string) error {
+	if n.kind != mapNode {
+		return typeError(n, key, "table")
+	}
+
+	// Build a list of the keys that this struct accepts.
+	t := v.Type()
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		name, _ := parseTag(t.Field(i))
+		if name != "" {
+			fields[name] = i
+		}
+	}
+	for _, name := range skip {
+		fields[name] = -1
+	}
+
+	// Make sure every key in the file is valid, and fill in the ones that are.
+	for _, name := range n.keys {
+		i, ok := fields[name]
+		if !ok {
+			return &Error{Line: n.keyLines[name], Key: joinKey(key, name), Msg: "unknown key"}
+		}
+		if i < 0 {
+			continue
+		}
+		if err := decode(n.fields[name], v.Field(i), joinKey(key, name)); err != nil {
+			return err
+		}
+	}
+
+	// Make sure every required key was set.
+	for i := 0; i < t.NumField(); i++ {
+		name, required := parseTag(t.Field(i))
+		if _, ok := n.fields[name]; required && !ok {
+			return &Error{Line: n.line, Key: joinKey(key, name), Msg: "missing required key"}
+		}
+	}
+
+	return nil
+}
+
+// decodeDuration decodes either a string like "1m30s" or a number of seconds.
+func decodeDuration(n *node, v reflect.Value, key string) error {
+	switch value := n.value.(type) {
+	case int64:
+		v.SetInt(int64(time.Duration(value) * time.Second))
+	case string:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return &Error{Line: n.line, Key: key, Msg: fmt.Sprintf("invalid duration %q", value)}
+		}
+		v.SetInt(int64(d))
+	default:
+		return typeError(n, key, "duration")
+	}
+
+	return nil
+}
+
+// decodeTheme decodes a theme. A theme can be written as the name of a palette, as a list of the
+// normal, warning, and error foreground colors, or as a table with any of the keys "palette",
+// "normal", "warning", and "error". In a table, the palette is used as the base, and each state is
+// either a foreground color or a table with the keys "foreground" and "background".
+func decodeTheme(n *node, key string) (theme.Theme, error) {
+	switch n.kind {
+	case scalarNode:
+		name, ok := n.value.(string)
+		if !ok {
+			return theme.Theme{}, typeError(n, key, "palette name")
+		}
+		t, err := theme.Palette(name)
+		if err != nil {
+			return theme.Theme{}, &Error{Line: n.line, Key: key, Msg: err.Error()}
+		}
+		return t, nil
+	case listNode:
+		var colors []string
+		if err := decode(n, reflect.ValueOf(&colors).Elem(), key); err != nil {
+			return theme.Theme{}, err
+		}
+		if len(colors) != 3 {
+			return theme.Theme{}, &Error{Line: n.line, Key: key, Msg: "expected 3 colors (normal, warning, and error)"}
+		}
+		return theme.FromColors(colors[0], colors[1], colors[2]), nil
+	}
+
+	var t theme.Theme
+	states := map[string]*theme.Colors{"normal": &t.Normal, "warning": &t.Warning, "error": &t.Error}
+	for _, name := range n.keys {
+		child, childKey := n.fields[name], joinKey(key, name)
+		if name == "palette" {
+			palette, err := decodeTheme(child, childKey)
+			if err != nil {
+				return theme.Theme{}, err
+			}
+			t = t.Inherit(palette)
+			continue
+		}
+
+		colors, ok := states[name]
+		if !ok {
+			return theme.Theme{}, &Error{Line: n.keyLines[name], Key: childKey, Msg: "unknown key"}
+		}
+
+		// A plain string is just the foreground color.
+		if s, ok := child.value.(string); ok && child.kind == scalarNode {
+			colors.Foreground = s
+			continue
+		}
+
+		c := struct {
+			Foreground string `config:"foreground"`
+			Background string `config:"background"`
+		}{}
+		if err := decodeStruct(child, reflect.ValueOf(&c).Elem(), childKey); err != nil {
+			return theme.Theme{}, err
+		}
+		colors.Foreground, colors.Background = c.Foreground, c.Background
+	}
+
+	return t, nil
+}
+
+// parseTag returns the key name of a struct field and whether or not the key is required.
+func parseTag(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("config")
+	if tag == "" || tag == "-" || field.PkgPath != "" {
+		return "", false
+	}
+
+	parts := strings.Split(tag, ",")
+	required := false
+	for _, option := range parts[1:] {
+		if option == "required" {
+			required = true
+		}
+	}
+
+	return parts[0], required
+}
+
+// joinKey adds name to the end of the key path.
+func joinKey(key string, name string) string {
+	if key == "" {
+		return name
+	}
+
+	return key + "." + name
+}
+
+// fieldError returns an error for the key name in the table n, which is at key. The error is on the
+// line of the key's value, or on the line of the table if the key isn't set.
+func fieldError(n *node, key string, name string, format string, args ...interface{}) error {
+	line := n.line
+	if child, ok := n.fields[name]; ok {
+		line = child.line
+	}
+
+	return &Error{Line: line, Key: joinKey(key, name), Msg: fmt.Sprintf(format, args...)}
+}
+
+// typeError returns an error for a node that is the wrong type.
+func typeError(n *node, key string, want string) error {
+	return &Error{Line: n.line, Key: key, Msg: fmt.Sprintf("expected %s, found %s", want, n.describe())}
+}
@@ -0,0 +1,524 @@
+// This file holds the parsers that turn each supported file format into a tree of nodes.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+// nodeKind is the type of value held by a node.
+type nodeKind int
+
+// These are the kinds of nodes.
+const (
+	scalarNode nodeKind = iota
+	listNode
+	mapNode
+)
+
+// String returns the name of the kind, as used in error messages.
+func (k nodeKind) String() string {
+	switch k {
+	case listNode:
+		return "list"
+	case mapNode:
+		return "table"
+	}
+
+	return "value"
+}
+
+// node is a single value in a configuration file, along with the line it was found on. Every file
+// format is parsed into the same tree of nodes so that they can all be decoded the same way.
+type node struct {
+	kind nodeKind
+
+	// Line number of the value, starting at 1.
+	line int
+
+	// Value of a scalar node. This is a string, int64, float64, or bool, or nil for a null value.
+	value interface{}
+
+	// Items in a list node.
+	items []*node
+
+	// Keys of a map node, in the order they appear in the file, and their values.
+	keys   []string
+	fields map[string]*node
+
+	// Lines of each key in a map node.
+	keyLines map[string]int
+}
+
+// newMap returns an empty map node.
+func newMap(line int) *node {
+	return &node{kind: mapNode, line: line, fields: make(map[string]*node), keyLines: make(map[string]int)}
+}
+
+// set adds a key to a map node. It returns false if the key already exists.
+func (n *node) set(key string, line int, value *node) bool {
+	if _, ok := n.fields[key]; ok {
+		return false
+	}
+
+	n.keys = append(n.keys, key)
+	n.fields[key] = value
+	n.keyLines[key] = line
+
+	return true
+}
+
+// describe returns a description of the node's type, for error messages.
+func (n *node) describe() string {
+	if n.kind != scalarNode {
+		return n.kind.String()
+	}
+
+	switch n.value.(type) {
+	case string:
+		return "string"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	case bool:
+		return "boolean"
+	}
+
+	return "null"
+}
+
+// parse parses data in the specified format ("toml", "yaml", or "json") into a tree of nodes.
+func parse(data []byte, format string) (*node, error) {
+	switch format {
+	case "toml":
+		return parseTOML(data)
+	case "yaml", "yml":
+		return parseYAML(data)
+	case "json":
+		return parseJSON(data)
+	}
+
+	return nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+// parseYAML parses a YAML document.
+func parseYAML(data []byte) (*node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		// The error is formatted like "yaml: line 3: some problem".
+		e := &Error{Msg: strings.TrimPrefix(err.Error(), "yaml: ")}
+		if _, scanErr := fmt.Sscanf(e.Msg, "line %d:", &e.Line); scanErr == nil {
+			e.Msg = strings.TrimSpace(e.Msg[strings.Index(e.Msg, ":")+1:])
+		}
+		return nil, e
+	}
+
+	// An empty document is an empty configuration.
+	if len(doc.Content) == 0 {
+		return newMap(1), nil
+	}
+
+	return fromYAML(doc.Content[0])
+}
+
+// fromYAML converts a YAML node into a node.
+func fromYAML(y *yaml.Node) (*node, error) {
+	switch y.Kind {
+	case yaml.DocumentNode:
+		if len(y.Content) == 0 {
+			return &node{line: y.Line}, nil
+		}
+		return fromYAML(y.Content[0])
+	case yaml.AliasNode:
+		return fromYAML(y.Alias)
+	case yaml.SequenceNode:
+		n := &node{kind: listNode, line: y.Line}
+		for _, item := range y.Content {
+			child, err := fromYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, child)
+		}
+		return n, nil
+	case yaml.MappingNode:
+		n := newMap(y.Line)
+		for i := 0; i+1 < len(y.Content); i += 2 {
+			key, value := y.Content[i], y.Content[i+1]
+			child, err := fromYAML(value)
+			if err != nil {
+				return nil, err
+			}
+			if !n.set(key.Value, key.Line, child) {
+				return nil, &Error{Line: key.Line, Key: key.Value, Msg: "duplicate key"}
+			}
+		}
+		return n, nil
+	}
+
+	// This is a scalar. Let the YAML package figure out its type.
+	var v interface{}
+	if err := y.Decode(&v); err != nil {
+		return nil, &Error{Line: y.Line, Msg: err.Error()}
+	}
+
+	n := &node{line: y.Line}
+	switch v := v.(type) {
+	case int:
+		n.value = int64(v)
+	case uint64:
+		n.value = float64(v)
+	case string, int64, float64, bool, nil:
+		n.value = v
+	default:
+		// Timestamps and binary data are kept as they were written.
+		n.value = y.Value
+	}
+
+	return n, nil
+}
+
+// parseJSON parses a JSON document.
+func parseJSON(data []byte) (*node, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	// line returns the line of the next token, skipping past any whitespace and separators.
+	line := func() int {
+		offset := int(d.InputOffset())
+		for offset < len(data) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+			offset++
+		}
+		return bytes.Count(data[:offset], []byte{'\n'}) + 1
+	}
+
+	var next func() (*node, error)
+	next = func() (*node, error) {
+		start := line()
+		token, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case json.Delim:
+			if t == '[' {
+				n := &node{kind: listNode, line: start}
+				for d.More() {
+					item, err := next()
+					if err != nil {
+						return nil, err
+					}
+					n.items = append(n.items, item)
+				}
+				_, err := d.Token()
+				return n, err
+			}
+
+			n := newMap(start)
+			for d.More() {
+				keyLine := line()
+				key, err := d.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := next()
+				if err != nil {
+					return nil, err
+				}
+				if !n.set(key.(string), keyLine, value) {
+					return nil, &Error{Line: keyLine, Key: key.(string), Msg: "duplicate key"}
+				}
+			}
+			_, err := d.Token()
+			return n, err
+		case json.Number:
+			if i, err := t.Int64(); err == nil {
+				return &node{line: start, value: i}, nil
+			}
+			f, err := t.Float64()
+			return &node{line: start, value: f}, err
+		}
+
+		return &node{line: start, value: token}, nil
+	}
+
+	n, err := next()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return newMap(1), nil
+		}
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, &Error{Line: bytes.Count(data[:syntaxErr.Offset], []byte{'\n'}) + 1, Msg: err.Error()}
+		}
+		return nil, &Error{Line: line(), Msg: err.Error()}
+	}
+
+	return n, nil
+}
+
+// parseTOML parses a TOML document.
+func parseTOML(data []byte) (*node, error) {
+	p := new(unstable.Parser)
+	p.Reset(data)
+
+	// lineOf returns the line of a node that has its position set.
+	lineOf := func(n *unstable.Node) int {
+		if n == nil || n.Raw.Length == 0 {
+			return 0
+		}
+		return p.Shape(n.Raw).Start.Line
+	}
+
+	root := newMap(1)
+	current := root
+	for p.NextExpression() {
+		expr := p.Expression()
+		switch expr.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			keys, line := tomlKey(expr.Key(), lineOf)
+			table, err := tomlTable(root, keys, line, expr.Kind == unstable.ArrayTable)
+			if err != nil {
+				return nil, err
+			}
+			current = table
+		case unstable.KeyValue:
+			if err := tomlKeyValue(current, expr, lineOf); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := p.Error(); err != nil {
+		e := &Error{Msg: err.Error()}
+		var parserErr *unstable.ParserError
+		if errors.As(err, &parserErr) && len(parserErr.Highlight) > 0 {
+			e.Line = p.Shape(p.Range(parserErr.Highlight)).Start.Line
+		}
+		return nil, e
+	}
+
+	return root, nil
+}
+
+// tomlKey returns the parts of a dotted key and the line it is on.
+func tomlKey(it unstable.Iterator, lineOf func(*unstable.Node) int) ([]string, int) {
+	var keys []string
+	line := 0
+	for it.Next() {
+		keys = append(keys, string(it.Node().Data))
+		if line == 0 {
+			line = lineOf(it.Node())
+		}
+	}
+
+	return keys, line
+}
+
+// tomlTable finds or creates the table at the path of keys, starting from root. If array is true,
+// the last key holds an array of tables, and a new table is added to it.
+func tomlTable(root *node, keys []string, line int, array bool) (*node, error) {
+	table := root
+	for i, key := range keys {
+		last := i == len(keys)-1
+		child, ok := table.fields[key]
+		switch {
+		case !ok && last && array:
+			child = &node{kind: listNode, line: line}
+			table.set(key, line, child)
+		case !ok:
+			child = newMap(line)
+			table.set(key, line, child)
+		}
+
+		switch {
+		case child.kind == listNode && last && array:
+			item := newMap(line)
+			child.items = append(child.items, item)
+			table = item
+		case child.kind == listNode && len(child.items) > 0:
+			// A table inside an array of tables belongs to the last table in the array.
+			table = child.items[len(child.items)-1]
+		case child.kind == mapNode:
+			table = child
+		default:
+			return nil, &Error{Line: line, Key: strings.Join(keys[:i+1], "."), Msg: "key is already set to a value"}
+		}
+	}
+
+	return table, nil
+}
+
+// tomlKeyValue adds a key/value pair to table.
+func tomlKeyValue(table *node, expr *unstable.Node, lineOf func(*unstable.Node) int) error {
+	keys, line := tomlKey(expr.Key(), lineOf)
+
+	// Walk any dotted keys down to the table that holds the value.
+	for i, key := range keys[:len(keys)-1] {
+		child, ok := table.fields[key]
+		if !ok {
+			child = newMap(line)
+			table.set(key, line, child)
+		} else if child.kind != mapNode {
+			return &Error{Line: line, Key: strings.Join(keys[:i+1], "."), Msg: "key is already set to a value"}
+		}
+		table = child
+	}
+
+	value, err := fromTOML(expr.Value(), line)
+	if err != nil {
+		return err
+	}
+
+	key := keys[len(keys)-1]
+	if !table.set(key, line, value) {
+		return &Error{Line: line, Key: strings.Join(keys, "."), Msg: "duplicate key"}
+	}
+
+	return nil
+}
+
+// fromTOML converts a TOML value into a node. line is used for the node's line.
+func fromTOML(v *unstable.Node, line int) (*node, error) {
+	n := &node{line: line}
+
+	switch v.Kind {
+	case unstable.Array:
+		n.kind = listNode
+		it := v.Children()
+		for it.Next() {
+			item, err := fromTOML(it.Node(), line)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		}
+	case unstable.InlineTable:
+		n = newMap(line)
+		it := v.Children()
+		for it.Next() {
+			if err := tomlKeyValue(n, it.Node(), func(*unstable.Node) int { return line }); err != nil {
+				return nil, err
+			}
+		}
+	case unstable.String:
+		n.value = string(v.Data)
+	case unstable.Bool:
+		n.value = string(v.Data) == "true"
+	case unstable.Integer:
+		i, err := parseTOMLInt(string(v.Data))
+		if err != nil {
+			return nil, &Error{Line: line, Msg: err.Error()}
+		}
+		n.value = i
+	case unstable.Float:
+		f, err := parseTOMLFloat(string(v.Data))
+		if err != nil {
+			return nil, &Error{Line: line, Msg: err.Error()}
+		}
+		n.value = f
+	default:
+		// Dates and times are kept as they were written.
+		n.value = string(v.Data)
+	}
+
+	return n, nil
+}
+
+// parseTOMLInt parses an integer in any of the forms allowed by TOML.
+func parseTOMLInt(s string) (int64, error) {
+	s = strings.ReplaceAll(s, "_", "")
+	if len(s) > 2 && s[0] == '0' {
+		switch s[1] {
+		case 'x':
+			return strconv.ParseInt(s[2:], 16, 64)
+		case 'o':
+			return strconv.ParseInt(s[2:], 8, 64)
+		case 'b':
+			return strconv.ParseInt(s[2:], 2, 64)
+		}
+	}
+
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// parseTOMLFloat parses a float in any of the forms allowed by TOML.
+func parseTOMLFloat(s string) (float64, error) {
+	s = strings.ReplaceAll(s, "_", "")
+	switch strings.TrimLeft(s, "+-") {
+	case "inf", "nan":
+		s = strings.Replace(s, "inf", "Inf", 1)
+		s = strings.Replace(s, "nan", "NaN", 1)
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
+
+// plain returns the node's value as plain Go values: a map[string]interface{} for a map node, an
+// []interface{} for a list node, and the value itself for a scalar node.
+func (n *node) plain() interface{} {
+	switch n.kind {
+	case listNode:
+		items := make([]interface{}, len(n.items))
+		for i, item := range n.items {
+			items[i] = item.plain()
+		}
+		return items
+	case mapNode:
+		fields := make(map[string]interface{}, len(n.fields))
+		for name, field := range n.fields {
+			fields[name] = field.plain()
+		}
+		return fields
+	}
+
+	return n.value
+}
+
+// lineOf returns the line of the value at path below the node, where path is written like
+// "graph.width" or "paths[1]". If the path leads to a key, then this is the key's line. If only part
+// of the path exists, then this is the line of the last part that does.
+func (n *node) lineOf(path string) int {
+	line := n.line
+	for _, part := range strings.Split(path, ".") {
+		name, indexes := part, ""
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			name, indexes = part[:i], part[i:]
+		}
+
+		if name != "" {
+			child, ok := n.fields[name]
+			if !ok {
+				return line
+			}
+			line, n = n.keyLines[name], child
+		}
+
+		// Each index is written like "[1]".
+		for strings.HasPrefix(indexes, "[") {
+			end := strings.IndexByte(indexes, ']')
+			if end < 0 {
+				return line
+			}
+			i, err := strconv.Atoi(indexes[1:end])
+			if err != nil || i < 0 || i >= len(n.items) {
+				return line
+			}
+			n, indexes = n.items[i], indexes[end+1:]
+			line = n.line
+		}
+	}
+
+	return line
+}
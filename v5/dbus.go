@@ -0,0 +1,330 @@
+// This file holds the alert notifier that calls the desktop's notification server over D-Bus.
+
+package statusbar
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// These are the types of D-Bus messages that the notifier sends and reads.
+const (
+	dbusMethodCall   = 1
+	dbusMethodReturn = 2
+	dbusError        = 3
+)
+
+// dbusMaxMessage is the longest that a D-Bus message can be.
+const dbusMaxMessage = 1 << 27
+
+// These are the codes of the D-Bus header fields that the notifier uses.
+const (
+	dbusFieldPath        = 1
+	dbusFieldInterface   = 2
+	dbusFieldMember      = 3
+	dbusFieldErrorName   = 4
+	dbusFieldReplySerial = 5
+	dbusFieldDestination = 6
+	dbusFieldSignature   = 8
+)
+
+// dbusNotifier calls the org.freedesktop.Notifications service on the session bus for every raised
+// alert.
+type dbusNotifier struct {
+	address string
+
+	// Function that connects to the bus at the address.
+	dial func(address string) (net.Conn, error)
+}
+
+// NewDBusNotifier returns an AlertNotifier that shows raised alerts as desktop notifications by
+// calling the Notify method of the freedesktop.org notification service on the D-Bus session bus.
+// This talks to the bus directly, without running any commands. The bus is found through
+// DBUS_SESSION_BUS_ADDRESS, or at /run/user/<uid>/bus if that isn't set. Critical alerts are sent
+// with the critical urgency. Cleared alerts aren't shown.
+func NewDBusNotifier() AlertNotifier {
+	address := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	if address == "" {
+		address = fmt.Sprintf("unix:path=/run/user/%d/bus", os.Getuid())
+	}
+
+	return &dbusNotifier{address: address, dial: dialDBus}
+}
+
+// Notify sends a raised alert to the notification service.
+func (n *dbusNotifier) Notify(alert Alert) error {
+	if n == nil {
+		return fmt.Errorf("invalid notifier")
+	}
+	if !alert.Cleared.IsZero() {
+		return nil
+	}
+
+	conn, err := n.dial(n.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(notifyTimeout)); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	if err := authDBus(conn, r); err != nil {
+		return err
+	}
+
+	// Every connection has to say hello to the bus before it can call anything else.
+	hello := dbusMessage(1, "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", "org.freedesktop.DBus", "", nil)
+	if _, err := conn.Write(hello); err != nil {
+		return err
+	}
+
+	// The signature of Notify is "susssasa{sv}i": the app's name, the ID of the notification to
+	// replace, the icon, the summary, the body, the actions, the hints, and the timeout.
+	urgency := byte(1)
+	if alert.Critical {
+		urgency = 2
+	}
+	body := new(dbusEncoder)
+	body.string("statusbar")
+	body.uint32(0)
+	body.string("")
+	body.string(alert.Summary)
+	body.string(alert.Message)
+	body.array(4, func() {})
+	body.array(8, func() {
+		body.align(8)
+		body.string("urgency")
+		body.signature("y")
+		body.b = append(body.b, urgency)
+	})
+	body.uint32(0xFFFFFFFF) // -1, for the server's default timeout.
+
+	notify := dbusMessage(2, "/org/freedesktop/Notifications", "org.freedesktop.Notifications", "Notify",
+		"org.freedesktop.Notifications", "susssasa{sv}i", body.b)
+	if _, err := conn.Write(notify); err != nil {
+		return err
+	}
+
+	return readDBusReply(r, 2)
+}
+
+// dialDBus connects to the first unix socket in a D-Bus address, like
+// "unix:path=/run/user/1000/bus" or "unix:abstract=/tmp/dbus-xyz,guid=...".
+func dialDBus(address string) (net.Conn, error) {
+	for _, entry := range strings.Split(address, ";") {
+		if !strings.HasPrefix(entry, "unix:") {
+			continue
+		}
+		for _, param := range strings.Split(strings.TrimPrefix(entry, "unix:"), ",") {
+			switch {
+			case strings.HasPrefix(param, "path="):
+				return net.Dial("unix", strings.TrimPrefix(param, "path="))
+			case strings.HasPrefix(param, "abstract="):
+				return net.Dial("unix", "@"+strings.TrimPrefix(param, "abstract="))
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported D-Bus address %q", address)
+}
+
+// authDBus logs in to the bus as the current user.
+func authDBus(w io.Writer, r *bufio.Reader) error {
+	uid := fmt.Sprintf("%x", strconv.Itoa(os.Getuid()))
+	if _, err := io.WriteString(w, "\x00AUTH EXTERNAL "+uid+"\r\n"); err != nil {
+		return err
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("D-Bus authentication failed: %s", strings.TrimSpace(line))
+	}
+
+	_, err = io.WriteString(w, "BEGIN\r\n")
+	return err
+}
+
+// dbusMessage builds a method call.
+func dbusMessage(serial uint32, path, iface, member, dest, signature string, body []byte) []byte {
+	e := new(dbusEncoder)
+	e.b = append(e.b, 'l', dbusMethodCall, 0, 1)
+	e.uint32(uint32(len(body)))
+	e.uint32(serial)
+
+	e.array(8, func() {
+		field := func(code byte, signature string, write func()) {
+			e.align(8)
+			e.b = append(e.b, code)
+			e.signature(signature)
+			write()
+		}
+		field(dbusFieldPath, "o", func() { e.string(path) })
+		field(dbusFieldInterface, "s", func() { e.string(iface) })
+		field(dbusFieldMember, "s", func() { e.string(member) })
+		field(dbusFieldDestination, "s", func() { e.string(dest) })
+		if signature != "" {
+			field(dbusFieldSignature, "g", func() { e.signature(signature) })
+		}
+	})
+	e.align(8)
+
+	return append(e.b, body...)
+}
+
+// readDBusReply reads messages from the bus until it finds the reply to the call with the serial.
+// It returns an error if the call failed.
+func readDBusReply(r io.Reader, serial uint32) error {
+	for {
+		kind, reply, errName, err := readDBusMessage(r)
+		if err != nil {
+			return err
+		}
+		if reply != serial {
+			continue
+		}
+
+		switch kind {
+		case dbusMethodReturn:
+			return nil
+		case dbusError:
+			return fmt.Errorf("D-Bus error: %s", errName)
+		}
+	}
+}
+
+// readDBusMessage reads one message from the bus and returns its type, the serial that it replies
+// to, and its error name, if it has them.
+func readDBusMessage(r io.Reader) (byte, uint32, string, error) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return 0, 0, "", err
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if head[0] == 'B' {
+		order = binary.BigEndian
+	}
+	bodyLen := order.Uint32(head[4:])
+	fieldsLen := order.Uint32(head[12:])
+	if bodyLen > dbusMaxMessage || fieldsLen > dbusMaxMessage {
+		return 0, 0, "", fmt.Errorf("D-Bus message is too long")
+	}
+
+	// The header fields are padded to a multiple of 8 bytes before the body starts.
+	end := 16 + int(fieldsLen)
+	rest := make([]byte, (end+7)/8*8-16+int(bodyLen))
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, 0, "", err
+	}
+	msg := append(head, rest...)
+
+	reply, errName, err := dbusHeaderFields(msg[:end], order)
+	return head[1], reply, errName, err
+}
+
+// dbusHeaderFields returns the reply serial and the error name from the header fields of msg, which
+// ends after the fields. Fields that aren't there are left empty.
+func dbusHeaderFields(msg []byte, order binary.ByteOrder) (uint32, string, error) {
+	var reply uint32
+	var errName string
+	invalid := fmt.Errorf("invalid D-Bus header")
+	for pos := 16; pos < len(msg); {
+		// Each field is a code and a variant: the signature of the value, and then the value.
+		pos = (pos + 7) / 8 * 8
+		if pos+2 > len(msg) || pos+3+int(msg[pos+1]) > len(msg) {
+			return 0, "", invalid
+		}
+		code, signature := msg[pos], string(msg[pos+2:pos+2+int(msg[pos+1])])
+		pos += 3 + len(signature)
+
+		switch signature {
+		case "u", "s", "o":
+			pos = (pos + 3) / 4 * 4
+			if pos+4 > len(msg) {
+				return 0, "", invalid
+			}
+			n := order.Uint32(msg[pos:])
+			pos += 4
+			if signature == "u" {
+				if code == dbusFieldReplySerial {
+					reply = n
+				}
+				continue
+			}
+			if pos+int(n)+1 > len(msg) {
+				return 0, "", invalid
+			}
+			if code == dbusFieldErrorName {
+				errName = string(msg[pos : pos+int(n)])
+			}
+			pos += int(n) + 1
+		case "g":
+			if pos >= len(msg) {
+				return 0, "", invalid
+			}
+			pos += 2 + int(msg[pos])
+		default:
+			return 0, "", fmt.Errorf("unexpected D-Bus header field type %q", signature)
+		}
+	}
+
+	return reply, errName, nil
+}
+
+// dbusEncoder marshals values in D-Bus's little-endian wire format. Values are aligned from the
+// start of the encoder, so messages and bodies must each be encoded from the start.
+type dbusEncoder struct {
+	b []byte
+}
+
+// align pads the encoded bytes to a multiple of n.
+func (e *dbusEncoder) align(n int) {
+	for len(e.b)%n != 0 {
+		e.b = append(e.b, 0)
+	}
+}
+
+// uint32 encodes a 32-bit number.
+func (e *dbusEncoder) uint32(v uint32) {
+	e.align(4)
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	e.b = append(e.b, b[:]...)
+}
+
+// string encodes a string or an object path.
+func (e *dbusEncoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.b = append(e.b, s...)
+	e.b = append(e.b, 0)
+}
+
+// signature encodes a type signature.
+func (e *dbusEncoder) signature(s string) {
+	e.b = append(e.b, byte(len(s)))
+	e.b = append(e.b, s...)
+	e.b = append(e.b, 0)
+}
+
+// array encodes an array whose elements are aligned to align bytes and written by fill. The padding
+// before the first element doesn't count toward the array's length.
+func (e *dbusEncoder) array(align int, fill func()) {
+	e.uint32(0)
+	lenPos := len(e.b) - 4
+	e.align(align)
+	start := len(e.b)
+	fill()
+	binary.LittleEndian.PutUint32(e.b[lenPos:], uint32(len(e.b)-start))
+}
@@ -21,6 +21,112 @@ interface, which includes these methods:
 
 It is suggested that this object be created by New(), which will also initialize any members of the object (if needed).
 
+Routines can also implement the Segmenter interface to return their output as a list of markup.Segment values (text,
+colors, and state) instead of a preformatted status2d string. The engine renders the segments with the markup set with
+SetMarkup, so the same routine works on dwm, lemonbar, swaybar, or a terminal.
+
+Routines that can block while updating, such as those that run a command or make a network request, should also
+implement the ContextUpdater interface. The engine cancels the context passed to UpdateContext when the update runs
+longer than the routine's timeout (see WithTimeout) or when the routine is stopped.
+
+Routines can report their readings as typed values, like a percentage or a temperature, by implementing the Valuer
+interface (see the value package), which every bundled module does. The latest values of each routine can be fetched
+through the REST API. WithVisibility adds rules that hide a routine based on these values and its state, such as hiding
+the battery when it is full on AC power or showing the fan only when it spins above some speed. Rules can be turned on
+and off while the bar is running through the REST API.
+
+WithAlerts adds rules that raise an alert when a routine's values stay in a bad range for some time, such as a low
+battery or a hot CPU. An alert stays raised until its clearing condition is true, so a value that hovers around a limit
+doesn't raise it over and over. Raised and cleared alerts are sent to every AlertNotifier added with AddAlertNotifier:
+NewExecNotifier and NewDBusNotifier show desktop notifications, and NewWebhookNotifier posts them as JSON. The alerts
+that are raised right now can be listed through the REST API.
+
+WithHistory keeps a history of a routine's numeric values, like a CPU temperature or a load average. The history holds
+a set number of samples, at most one for each period of a set resolution, and it can be fetched through the REST API.
+
+The graph package draws small graphs of values: sparklines of recent samples, gauges of block characters, and
+progress bars drawn with status2d's rectangles. sbcpuusage, sbram, and sbnetwork can show their readings as graphs
+(see their SetGraph methods), and other routines can return a graph's drawing in a markup.Segment.
+
+EnableMetrics serves the statistics of every routine in Prometheus's text format at /metrics on the REST API's port,
+such as its uptime, the duration of its latest update, and its number of errors, along with every numeric value that
+the routine reports. This way, the readings on the bar can be scraped and graphed over a longer time.
+
+Output that is too long is shortened to the routine's width, which is DefaultWidth columns unless it is changed with
+WithWidth. Widths are measured in display columns, so wide characters count as two and markup doesn't count at all,
+and the ellipsis can go at the end, in the middle, or at the start of the output.
+
+With WithMarquee, output that is too long scrolls through a window of a fixed width instead of being shortened. The
+output scrolls on its own between updates, one column at a time, without breaking apart wide characters or markup.
+
+When an update fails, the routine backs off before trying again. The wait starts small and doubles with every failure
+in a row, up to a limit, and goes back to the normal interval after a successful update. WithRetryPolicy sets the
+initial wait, the limit, the growth, the amount of random jitter, and the number of failures after which the routine
+gives up.
+
+Routines can be divided into any number of named regions with AddRegion and WithRegion. Each region has its own
+separator and markers, and an alignment for programs that can place text on the left, in the center, or on the right of
+the bar. The markup decides how the regions are combined: lemonbar gets its alignment blocks, and the others get the
+regions joined with ';' for dwm's dualstatus and extrabar patches. Split is a shortcut for two unnamed regions.
+
+To save space, several routines can take turns in one place on the bar. AddSlot adds a slot that shows one of its
+members at a time and moves on to the next one after a set period, and WithSlot adds a routine to it. A slot can pin a
+member whose output is in the warning or error state so that it stays on screen. Members keep updating on their own
+intervals while they wait for their turn.
+
+The list of routines can also be changed while the bar is running. Insert, Remove, and Move change the routines and
+their order, SetSplit moves the split, and Routines lists the routines in order. The same changes can be made with the
+REST API.
+
+Routines that stop on their own stay stopped by default. With WithRestartPolicy, a routine is started again after a
+delay when it fails (RestartOnFailure) or whenever it stops (RestartAlways), and it keeps its place on the bar. Routines
+that are stopped on purpose, such as through the REST API, are only started again by hand. While the REST API is
+running, Run keeps going even after every routine has stopped so that routines can still be started from the API.
+
+The bar is only redrawn when a routine's output changes, and changes that happen close together are drawn in a single
+frame. Routines that find out about changes on their own, for example by watching for events instead of polling, can
+implement the Notifier interface to have their output refreshed and drawn right away.
+
+The engine logs its messages, such as failed updates, through a Logger with a level and key/value fields. The default
+logger writes messages at the info level and above with the standard log package, NewLogger writes them to any
+io.Writer at a chosen level, and SetLogger plugs in a different logger for the whole program. The recent messages about
+each routine are kept regardless of their level and can be fetched through the REST API. The REST API's own request log
+goes to stdout unless it is moved or turned off with SetRequestLog.
+
+By default, the statusbar is printed to the name of the X root window, which is where dwm reads its status text. Other
+destinations can be added with AddSink, such as stdout (NewStdoutSink) for lemonbar or dwl/somebar, a named pipe
+(NewFIFOSink), or a plain file (NewFileSink) for tmux. Custom destinations only need to implement the Sink interface.
+
+For i3bar and swaybar, use NewI3barSink as the only sink and run the program as the bar's status_command. Each routine
+is then sent as its own block, and clicks on a block are passed to the routine if it implements the Clicker interface.
+
+With dwm's statuscmd patch, EnableStatusCmd makes routines clickable on dwm's bar too. The engine places a marker byte
+before each routine's output, and dwm sends the statusbar a real-time signal with the mouse button when that output is
+clicked. The click is passed to the routine if it implements the Clicker interface, like it is for i3bar.
+
+Each routine colors its output with its own theme (see the theme package), which sets the foreground and background
+colors for normal, warning, and error output. Colors missing from a routine's theme are inherited from the statusbar's
+theme, as set with SetTheme, for routines that implement the Themer interface. Themes can be written out by hand, built
+from a normal/warning/error triplet with theme.FromColors, or looked up from the named palettes with theme.Palette.
+
+Instead of writing a Go program, the statusbar can also be set up with a TOML, YAML, or JSON configuration file and run
+with the statusbar command in cmd/statusbar. See the config package for the format of the file. Modules are created
+from the file by name, using the constructors and typed options that each module adds to the registry package.
+
+A running statusbar can be switched over to new settings with Reload. Routines that match a running routine (see
+WithFingerprint) keep running with all their state, removed routines are stopped, and new routines are started. With
+SetReloader, this happens every time the program receives SIGHUP. The statusbar command uses this to reload its
+configuration file.
+
+A statusbar can also be driven by hand, which is how the statusbartest package tests modules and the engine without
+running anything in the background. UpdateRoutine runs one update of a routine, Draw composes the bar and writes it to
+the sinks, and SetClock sets the Clock that slots, marquees, histories, and alerts read the time from.
+
+Printing to X requires cgo and libX11. To build without them (for example, with CGO_ENABLED=0 or on a headless
+machine), set the nox11 build tag or disable cgo. In that build, NewXSink always fails, and the bar is printed to stdout
+if no other sinks are added. Even with X11 support, the connection to the display is not opened until the bar is first
+printed there.
+
 The sample code below creates a new statusbar, adds some routines to it, and begins displaying the formatted output. In
 dwm, we are using the dualstatus patch, which creates a top and bottom bar for extra statusbar real estate. The top bar
 will display the time, and the bottom bar will display the disk usage and CPU stats.
@@ -31,17 +137,18 @@ will display the time, and the bottom bar will display the disk usage and CPU st
 		"github.com/snhilde/statusbar/v5/sbdisk"
 		"github.com/snhilde/statusbar/v5/sbcpuusage"
 		"github.com/snhilde/statusbar/v5/sbcputemp"
+		"github.com/snhilde/statusbar/v5/theme"
 	)
 
 	func main() {
 		// Create the initial engine.
 		bar := statusbar.New()
 
-		// sbtime.New() takes two arguments: time format and a triplet of color codes for normal, warning, and error
+		// sbtime.New() takes two arguments: time format and a theme with the colors for normal, warning, and error
 		// outputs. It returns a new routine that implements the RoutineHandler interface.
 		// bar.Append() takes two arguments: the new routine object and the update interval (how often in seconds the
 		// routine should run its Update() method).
-		bar.Append(sbtime.New("Jan 2 - 03:04", [3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 1)
+		bar.Append(sbtime.New("Jan 2 - 03:04", theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 1)
 
 		// This inserts the splitting character for the dualstatus patch. Before this is called, the routines already
 		// added are displayed on the top bar. After this is called, all subsequently added routines are displayed on
@@ -50,12 +157,12 @@ will display the time, and the bottom bar will display the disk usage and CPU st
 
 		// The second bar will start with the output from the disk routine. It will display the space used
 		// and total space of the given filesystem. The routine will update every 5 seconds.
-		bar.Append(sbdisk.New([]string{"/"}, [3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 5)
+		bar.Append(sbdisk.New([]string{"/"}, theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 5)
 
 		// The next two routines will display (separately) the current percentage of CPU used and the
 		// temperature of the CPU, each updated every second.
-		bar.Append(sbcpuusage.New([3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 1)
-		bar.Append(sbcputemp.New([3]string{"#8FFFFF", "#BB4F2E", "#A1273E"}), 1)
+		bar.Append(sbcpuusage.New(theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 1)
+		bar.Append(sbcputemp.New(theme.FromColors("#8FFFFF", "#BB4F2E", "#A1273E")), 1)
 
 		// The statusbar will now run indefinitely, updating every routine at the provided interval. All routines run
 		// concurrently in their own thread and are independent of each other.
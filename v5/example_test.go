@@ -2,20 +2,22 @@ package statusbar_test
 
 import (
 	"github.com/snhilde/statusbar/v5"
+	"github.com/snhilde/statusbar/v5/sbram"
 	"github.com/snhilde/statusbar/v5/sbtime"
+	"github.com/snhilde/statusbar/v5/theme"
 )
 
 func ExampleStatusbar_Append() {
 	bar := statusbar.New()
 
 	// Add the sbtime routine to our statusbar.
-	// sbtime.New() takes two arguments: the format to use for the time string and a triplet of color codes.
+	// sbtime.New() takes two arguments: the format to use for the time string and a theme of colors.
 	timeFmt := "Jan 2 - 03:04"
 
 	colorNormal := "#FFFFFF"
 	colorWarning := "#BB4F2E"
 	colorError := "#A1273E"
-	colors := [3]string{colorNormal, colorWarning, colorError}
+	colors := theme.FromColors(colorNormal, colorWarning, colorError)
 
 	// Create a new routine.
 	timeRoutine := sbtime.New(timeFmt, colors)
@@ -24,5 +26,22 @@ func ExampleStatusbar_Append() {
 	bar.Append(timeRoutine, 1)
 
 	// Or, as a one-liner:
-	bar.Append(sbtime.New("Jan 2 - 03:04", [3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 1)
+	bar.Append(sbtime.New("Jan 2 - 03:04", theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 1)
+}
+
+func ExampleStatusbar_SetTheme() {
+	bar := statusbar.New()
+
+	// Use one of the named palettes for every routine.
+	palette, err := theme.Palette("gruvbox")
+	if err == nil {
+		bar.SetTheme(palette)
+	}
+
+	// This routine uses the palette as is.
+	bar.Append(sbtime.New("Jan 2 - 03:04"), 1)
+
+	// This routine sets its own normal colors and inherits the warning and error colors from the
+	// palette.
+	bar.Append(sbram.New(theme.Theme{Normal: theme.Colors{Foreground: "#000000", Background: "#8EC07C"}}), 5)
 }
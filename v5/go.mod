@@ -1,8 +1,10 @@
 module github.com/snhilde/statusbar/v5
 
-go 1.15
+go 1.16
 
 require (
 	github.com/gin-gonic/gin v1.7.0
+	github.com/pelletier/go-toml/v2 v2.0.9
 	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
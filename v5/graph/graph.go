@@ -0,0 +1,260 @@
+// Package graph draws small graphs of a routine's values, so that routines can show a trend or a
+// level at a glance instead of a bare number.
+//
+// Sparklines show the recent samples of a value as a line of block characters, like ▁▂▃▅▇. Gauges
+// show the latest sample as a horizontal bar of block characters. Progress bars are drawn with the
+// rectangles of dwm's status2d patch (see markup.Segment's Drawing), with a gauge in their place for
+// every other markup. Routines draw their recent samples with a Graph, either from the history that
+// the engine keeps for them (see statusbar.Historian) or from a Series of their own.
+package graph
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Style is the kind of graph to draw.
+type Style int
+
+// These are the possible styles of a graph.
+const (
+	// None doesn't draw a graph. Routines show their value as text instead.
+	None Style = iota
+
+	// Sparkline draws the recent samples as a line of block characters.
+	Sparkline
+
+	// Gauge draws the latest sample as a horizontal bar of block characters.
+	Gauge
+
+	// Progress draws the latest sample as a status2d progress bar.
+	Progress
+)
+
+// styles maps the name of each style to the style.
+var styles = map[string]Style{
+	"none":      None,
+	"sparkline": Sparkline,
+	"gauge":     Gauge,
+	"progress":  Progress,
+}
+
+// String returns the name of the style.
+func (s Style) String() string {
+	for name, style := range styles {
+		if style == s {
+			return name
+		}
+	}
+
+	return "unknown"
+}
+
+// ParseStyle returns the style with the name: "none", "sparkline", "gauge", or "progress". An empty
+// name is the same as "none".
+func ParseStyle(name string) (Style, error) {
+	if name == "" {
+		return None, nil
+	}
+
+	style, ok := styles[name]
+	if !ok {
+		return None, fmt.Errorf("unknown graph style %q", name)
+	}
+
+	return style, nil
+}
+
+// These are the defaults for the size of a graph.
+const (
+	defaultWidth         = 8
+	defaultProgressWidth = 40
+	defaultHeight        = 4
+)
+
+// DefaultTop is the distance between the top of the bar and the top of a progress bar that roughly
+// centers a progress bar of the default height on dwm's bar.
+const DefaultTop = 7
+
+// pixelsPerColumn is the number of pixels of a progress bar that each column of its gauge stands for
+// when it is shown with a markup other than status2d.
+const pixelsPerColumn = defaultProgressWidth / defaultWidth
+
+// levels are the block characters of a sparkline, from lowest to highest.
+var levels = []rune("▁▂▃▄▅▆▇█")
+
+// eighths are the block characters that fill part of a gauge's column, from one eighth to seven
+// eighths.
+var eighths = []rune("▏▎▍▌▋▊▉")
+
+// Graph sets how a routine draws its samples.
+type Graph struct {
+	// Kind of graph to draw.
+	Style Style
+
+	// Number of samples in a sparkline and columns in a gauge, or the width of a progress bar in
+	// pixels. If this is 0, sparklines and gauges are 8 columns wide, and progress bars are 40
+	// pixels wide. Progress bars are shown as gauges with one column for every 5 pixels with any
+	// markup other than status2d.
+	Width int
+
+	// Height of a progress bar, in pixels. If this is 0, progress bars are 4 pixels high.
+	Height int
+
+	// Distance between the top of the bar and the top of a progress bar, in pixels. Use DefaultTop
+	// to roughly center a progress bar of the default height on dwm's bar.
+	Top int
+}
+
+// Samples returns how many of the most recent samples the graph draws.
+func (g Graph) Samples() int {
+	if g.Style != Sparkline {
+		return 1
+	}
+
+	return g.width()
+}
+
+// Draw draws the samples (from oldest to newest) in the graph's style. min and max are the range of
+// the values, and samples outside of the range are drawn at its edges. If max isn't greater than min,
+// the range is from 0 to the largest sample. text is the graph drawn with characters, and drawing is
+// the status2d escapes that draw a progress bar, or an empty string for the other styles.
+func (g Graph) Draw(samples []float64, min, max float64) (text string, drawing string) {
+	if max <= min {
+		min, max = 0, 0
+		for _, sample := range samples {
+			max = math.Max(max, sample)
+		}
+	}
+
+	var latest float64
+	if len(samples) > 0 {
+		latest = samples[len(samples)-1]
+	}
+
+	switch g.Style {
+	case Sparkline:
+		if n := g.width(); len(samples) > n {
+			samples = samples[len(samples)-n:]
+		}
+		return SparklineText(samples, min, max), ""
+	case Gauge:
+		return GaugeText(latest, min, max, g.width()), ""
+	case Progress:
+		width, height := g.Width, g.Height
+		if width <= 0 {
+			width = defaultProgressWidth
+		}
+		if height <= 0 {
+			height = defaultHeight
+		}
+		columns := width / pixelsPerColumn
+		if columns < 1 {
+			columns = 1
+		}
+		return GaugeText(latest, min, max, columns), ProgressBar(latest, min, max, width, height, g.Top)
+	}
+
+	return "", ""
+}
+
+// width returns the width of a sparkline or gauge.
+func (g Graph) width() int {
+	if g.Width <= 0 {
+		return defaultWidth
+	}
+
+	return g.Width
+}
+
+// SparklineText draws the samples as a line of block characters, one for each sample, where ▁ is min
+// and █ is max.
+func SparklineText(samples []float64, min, max float64) string {
+	b := new(strings.Builder)
+	for _, sample := range samples {
+		level := int(fraction(sample, min, max) * float64(len(levels)-1))
+		b.WriteRune(levels[level])
+	}
+
+	return b.String()
+}
+
+// GaugeText draws v as a horizontal bar width columns wide, where min is an empty bar and max is a
+// full bar. The bar is filled in eighths of a column, and the empty part is shaded with ░.
+func GaugeText(v float64, min, max float64, width int) string {
+	filled := int(math.Round(fraction(v, min, max) * float64(width*8)))
+
+	b := new(strings.Builder)
+	b.WriteString(strings.Repeat("█", filled/8))
+	columns := filled / 8
+	if filled%8 > 0 {
+		b.WriteRune(eighths[filled%8-1])
+		columns++
+	}
+	b.WriteString(strings.Repeat("░", width-columns))
+
+	return b.String()
+}
+
+// ProgressBar draws v as a status2d progress bar that is width pixels wide and height pixels high,
+// starting top pixels from the top of dwm's bar. min is an empty bar and max is a full bar. The empty
+// part of the bar is drawn as a line along its bottom edge. The bar is drawn with the current
+// foreground color, and the text after it starts after the bar.
+func ProgressBar(v float64, min, max float64, width, height, top int) string {
+	filled := int(math.Round(fraction(v, min, max) * float64(width)))
+
+	b := new(strings.Builder)
+	if filled > 0 {
+		fmt.Fprintf(b, "^r0,%d,%d,%d^", top, filled, height)
+	}
+	if filled < width {
+		fmt.Fprintf(b, "^r%d,%d,%d,1^", filled, top+height-1, width-filled)
+	}
+	fmt.Fprintf(b, "^f%d^", width)
+
+	return b.String()
+}
+
+// fraction returns where v falls between min and max, from 0 to 1.
+func fraction(v float64, min, max float64) float64 {
+	if max <= min || math.IsNaN(v) {
+		return 0
+	}
+
+	return math.Min(math.Max((v-min)/(max-min), 0), 1)
+}
+
+// Options are the settings of a graph as they are written in a configuration file. See Graph for
+// how each one is used.
+type Options struct {
+	// Name of the style: "none" (the default), "sparkline", "gauge", or "progress".
+	Style string `config:"style"`
+
+	// Width of the graph.
+	Width int `config:"width"`
+
+	// Height of a progress bar, in pixels.
+	Height int `config:"height"`
+
+	// Distance between the top of the bar and the top of a progress bar, in pixels. If this isn't
+	// set, DefaultTop is used.
+	Top *int `config:"top"`
+}
+
+// Graph returns the graph with these settings.
+func (o Options) Graph() (Graph, error) {
+	style, err := ParseStyle(o.Style)
+	if err != nil {
+		return Graph{}, err
+	}
+	top := DefaultTop
+	if o.Top != nil {
+		top = *o.Top
+	}
+	if o.Width < 0 || o.Height < 0 || top < 0 {
+		return Graph{}, fmt.Errorf("graph sizes cannot be negative")
+	}
+
+	return Graph{Style: style, Width: o.Width, Height: o.Height, Top: top}, nil
+}
@@ -0,0 +1,68 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/snhilde/statusbar/v5/graph"
+)
+
+func TestSparkline(t *testing.T) {
+	t.Parallel()
+
+	if got := graph.SparklineText([]float64{0, 25, 50, 75, 100, 150}, 0, 100); got != "▁▂▄▆██" {
+		t.Errorf("Expected ▁▂▄▆██, got %q", got)
+	}
+
+	// Only the newest samples are drawn, scaled to the largest sample.
+	s := graph.NewSeries(4)
+	for _, v := range []float64{9, 1, 2, 4, 8} {
+		s.Add(v)
+	}
+	g := graph.Graph{Style: graph.Sparkline, Width: 3}
+	if text, drawing := g.Draw(s.Samples(), 0, 0); text != "▂▄█" || drawing != "" {
+		t.Errorf("Expected ▂▄█, got %q and %q", text, drawing)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	t.Parallel()
+
+	tests := map[float64]string{0: "░░░░", 50: "██░░", 60: "██▍░", 100: "████", 200: "████"}
+	for v, want := range tests {
+		if got := graph.GaugeText(v, 0, 100, 4); got != want {
+			t.Errorf("%v: expected %q, got %q", v, want, got)
+		}
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	t.Parallel()
+
+	if got := graph.ProgressBar(25, 0, 100, 40, 4, 7); got != "^r0,7,10,4^^r10,10,30,1^^f40^" {
+		t.Errorf("Bad progress bar: %q", got)
+	}
+
+	g := graph.Graph{Style: graph.Progress, Top: graph.DefaultTop}
+	if text, drawing := g.Draw([]float64{100}, 0, 100); text != "████████" || drawing != "^r0,7,40,4^^f40^" {
+		t.Errorf("Bad progress graph: %q and %q", text, drawing)
+	}
+
+	// The gauge shown in place of a progress bar is as wide as the progress bar.
+	g = graph.Graph{Style: graph.Progress, Width: 20}
+	if text, drawing := g.Draw([]float64{50}, 0, 100); text != "██░░" || drawing != "^r0,0,10,4^^r10,3,10,1^^f20^" {
+		t.Errorf("Bad narrow progress graph: %q and %q", text, drawing)
+	}
+
+	if _, err := (graph.Options{Style: "pie"}).Graph(); err == nil {
+		t.Errorf("Expected an error for an unknown style")
+	}
+
+	// A progress bar can start at the very top of the bar, and it starts a little lower by default.
+	top := 0
+	if g, err := (graph.Options{Style: "progress", Top: &top}).Graph(); err != nil || g.Top != 0 {
+		t.Errorf("Expected a top of 0, got %d (%v)", g.Top, err)
+	}
+	if g, err := (graph.Options{Style: "progress"}).Graph(); err != nil || g.Top != graph.DefaultTop {
+		t.Errorf("Expected the default top, got %d (%v)", g.Top, err)
+	}
+}
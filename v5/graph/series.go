@@ -0,0 +1,41 @@
+// This file holds the series that routines keep their recent samples in.
+
+package graph
+
+// Series holds the most recent samples of a value, up to a set number of them.
+type Series struct {
+	size    int
+	samples []float64
+}
+
+// NewSeries returns a series that keeps the size most recent samples. A size of 0 or less keeps one
+// sample.
+func NewSeries(size int) *Series {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &Series{size: size, samples: make([]float64, 0, size)}
+}
+
+// Add adds a sample to the series, dropping the oldest sample if the series is full.
+func (s *Series) Add(v float64) {
+	if s == nil {
+		return
+	}
+
+	if len(s.samples) == s.size {
+		copy(s.samples, s.samples[1:])
+		s.samples = s.samples[:s.size-1]
+	}
+	s.samples = append(s.samples, v)
+}
+
+// Samples returns the samples in the series, from oldest to newest.
+func (s *Series) Samples() []float64 {
+	if s == nil {
+		return nil
+	}
+
+	return append([]float64(nil), s.samples...)
+}
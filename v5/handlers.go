@@ -10,8 +10,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/snhilde/statusbar/v5/registry"
 	"github.com/snhilde/statusbar/v5/restapi"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
 )
 
 // apiHandler is a wrapper object for convenience reasons: in order for the restapi package to be
@@ -36,6 +41,36 @@ type routineInfo struct {
 
 	// Whether or not the routine is currently active.
 	Active bool `json:"active"`
+
+	// Number of updates in a row that have failed.
+	Failures int `json:"failures"`
+
+	// Time of the next retry after a failed update, in RFC 3339 format. This is empty if the last update succeeded.
+	NextRetry string `json:"next_retry,omitempty"`
+
+	// Number of times the routine has been restarted, either by its restart policy or through the API.
+	Restarts int `json:"restarts"`
+
+	// Whether or not the routine's visibility rules let it be shown on the bar.
+	Visible bool `json:"visible"`
+
+	// Name of the slot that the routine takes turns in, if any.
+	Slot string `json:"slot,omitempty"`
+}
+
+// ruleInfo holds the information that is returned for each visibility rule.
+type ruleInfo struct {
+	// Name of the rule.
+	Name string `json:"name"`
+
+	// Expression that hides the routine when it is true.
+	HideWhen string `json:"hide_when,omitempty"`
+
+	// Expression that hides the routine when it is false.
+	ShowWhen string `json:"show_when,omitempty"`
+
+	// Whether or not the rule is turned on.
+	Enabled bool `json:"enabled"`
 }
 
 // HandleGetPing responds to a ping request with "pong".
@@ -79,11 +114,11 @@ func (a apiHandler) HandleGetEndpoints(endpoint restapi.Endpoint, params restapi
 // HandleGetRoutineAll responds with information about all the routines (active and inactive).
 // endpoint: GET /routines
 func (a apiHandler) HandleGetRoutineAll(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	routines := a.routineList()
+	names := routineNames(routines)
 	infos := make(map[string]routineInfo)
-	for _, routine := range a.routines {
-		name := routine.moduleName()
-		info := getRoutineInfo(routine)
-		infos[name] = info
+	for i, routine := range routines {
+		infos[names[i]] = getRoutineInfo(routine)
 	}
 
 	return 200, encodePair("routines", infos)
@@ -92,18 +127,19 @@ func (a apiHandler) HandleGetRoutineAll(endpoint restapi.Endpoint, params restap
 // HandleGetRoutine responds with information about the specified routine.
 // endpoint: GET /routines/:routine
 func (a apiHandler) HandleGetRoutine(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
-	routine, err := getRoutine(a.routines, params["routine"])
+	routines := a.routineList()
+	index, err := getRoutineIndex(routines, params["routine"])
 	if err != nil {
 		return 400, encodePair("error", err.Error())
 	}
 
-	return 200, encodePair(routine.moduleName(), getRoutineInfo(routine))
+	return 200, encodePair(routineNames(routines)[index], getRoutineInfo(routines[index]))
 }
 
 // HandlePutRoutineAll restarts all active routines.
 // endpoint: PUT /routines
 func (a apiHandler) HandlePutRoutineAll(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
-	for _, routine := range a.routines {
+	for _, routine := range a.routineList() {
 		if routine.isActive() {
 			routine.update()
 		}
@@ -115,7 +151,7 @@ func (a apiHandler) HandlePutRoutineAll(endpoint restapi.Endpoint, params restap
 // HandlePutRoutine restarts the specified routine.
 // endpoint: PUT /routines/:routine
 func (a apiHandler) HandlePutRoutine(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
-	routine, err := getRoutine(a.routines, params["routine"])
+	routine, err := getRoutine(a.routineList(), params["routine"])
 	if err != nil {
 		return 400, encodePair("error", err.Error())
 	}
@@ -131,7 +167,7 @@ func (a apiHandler) HandlePutRoutine(endpoint restapi.Endpoint, params restapi.P
 // interval time.
 // endpoint: PATCH /routines/:routine
 func (a apiHandler) HandlePatchRoutine(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
-	routine, err := getRoutine(a.routines, params["routine"])
+	routine, err := getRoutine(a.routineList(), params["routine"])
 	if err != nil {
 		return 400, encodePair("error", err.Error())
 	}
@@ -163,10 +199,39 @@ func (a apiHandler) HandlePatchRoutine(endpoint restapi.Endpoint, params restapi
 	return 202, ""
 }
 
-// HandleDeleteRoutineAll stops all routines (and therefore the statusbar and API engine).
+// HandlePostRoutineAll starts all stopped routines again.
+// endpoint: POST /routines
+func (a apiHandler) HandlePostRoutineAll(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	for _, routine := range a.routineList() {
+		if !routine.isActive() {
+			if err := a.start(routine); err != nil {
+				return 500, encodePair("error", err.Error())
+			}
+		}
+	}
+
+	return 204, ""
+}
+
+// HandlePostRoutine starts the specified routine again if it has stopped.
+// endpoint: POST /routines/:routine
+func (a apiHandler) HandlePostRoutine(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	routine, err := getRoutine(a.routineList(), params["routine"])
+	if err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	if err := a.start(routine); err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	return 204, ""
+}
+
+// HandleDeleteRoutineAll stops all routines. They can be started again with POST /routines.
 // endpoint: DELETE /routines
 func (a apiHandler) HandleDeleteRoutineAll(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
-	for _, routine := range a.routines {
+	for _, routine := range a.routineList() {
 		if routine.isActive() {
 			if !routine.stop(5) {
 				return 500, encodePair("error", "failure")
@@ -180,7 +245,7 @@ func (a apiHandler) HandleDeleteRoutineAll(endpoint restapi.Endpoint, params res
 // HandleDeleteRoutine stops the specified routine.
 // endpoint: DELETE /routines/:routine
 func (a apiHandler) HandleDeleteRoutine(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
-	routine, err := getRoutine(a.routines, params["routine"])
+	routine, err := getRoutine(a.routineList(), params["routine"])
 	if err != nil {
 		return 400, encodePair("error", err.Error())
 	}
@@ -194,15 +259,509 @@ func (a apiHandler) HandleDeleteRoutine(endpoint restapi.Endpoint, params restap
 	return 204, ""
 }
 
-// getRoutine is a helper function that gets the specified routine from the list of routines.
+// HandleGetRoutineVisibility responds with the visibility rules of the specified routine.
+// endpoint: GET /routines/:routine/visibility
+func (a apiHandler) HandleGetRoutineVisibility(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	routine, err := getRoutine(a.routineList(), params["routine"])
+	if err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	rules, enabled := routine.visibilityRules()
+	infos := make([]ruleInfo, len(rules))
+	for i, rule := range rules {
+		infos[i] = ruleInfo{Name: rule.Name, HideWhen: rule.HideWhen, ShowWhen: rule.ShowWhen, Enabled: enabled[i]}
+	}
+
+	return 200, encodePair("rules", infos)
+}
+
+// HandlePutRoutineVisibilityRule turns the specified visibility rule of the specified routine on or
+// off.
+// endpoint: PUT /routines/:routine/visibility/:rule
+func (a apiHandler) HandlePutRoutineVisibilityRule(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	routine, err := getRoutine(a.routineList(), params["routine"])
+	if err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := readBody(request, &req); err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+	if req.Enabled == nil {
+		return 400, encodePair("error", "missing enabled")
+	}
+
+	if err := routine.enableRule(params["rule"], *req.Enabled); err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	return 204, ""
+}
+
+// historyInfo holds the history of a routine's numeric values.
+type historyInfo struct {
+	// Most samples that are kept.
+	Depth int `json:"depth"`
+
+	// Shortest time between samples, in seconds.
+	Resolution float64 `json:"resolution"`
+
+	// Unit of each value, by name. Values without a unit have an empty unit.
+	Units map[string]string `json:"units"`
+
+	// Recorded samples, from oldest to newest.
+	Samples []sampleInfo `json:"samples"`
+}
+
+// sampleInfo holds the numeric values of a routine at one point in time.
+type sampleInfo struct {
+	// Time of the sample, in RFC 3339 format.
+	Time string `json:"time"`
+
+	// Numeric values of the routine, by name.
+	Values map[string]float64 `json:"values"`
+}
+
+// HandleGetRoutineHistory responds with the history of the specified routine's numeric values.
+// endpoint: GET /routines/:routine/history
+func (a apiHandler) HandleGetRoutineHistory(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	routine, err := getRoutine(a.routineList(), params["routine"])
+	if err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	settings := routine.historySettings()
+	samples, units := routine.historySamples()
+	info := historyInfo{
+		Depth:      settings.Depth,
+		Resolution: settings.Resolution.Seconds(),
+		Units:      units,
+		Samples:    make([]sampleInfo, len(samples)),
+	}
+	for i, s := range samples {
+		info.Samples[i] = sampleInfo{Time: s.time.Format(time.RFC3339), Values: s.values}
+	}
+
+	return 200, encodePair("history", info)
+}
+
+// valueInfo holds the information that is returned for each of a routine's values.
+type valueInfo struct {
+	// Name of the value.
+	Name string `json:"name"`
+
+	// Kind of the value: "number", "bool", or "text".
+	Kind string `json:"kind"`
+
+	// The value itself, as a JSON number, boolean, or string.
+	Value interface{} `json:"value"`
+
+	// Unit of a number, if it has one.
+	Unit string `json:"unit,omitempty"`
+
+	// Labels that tell apart values with the same name, by label name, if the value has any.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// HandleGetRoutineValues responds with the values that the specified routine reported with its
+// latest output. Routines that don't implement Valuer, or whose latest update failed, don't have any
+// values.
+// endpoint: GET /routines/:routine/values
+func (a apiHandler) HandleGetRoutineValues(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	routine, err := getRoutine(a.routineList(), params["routine"])
+	if err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	values := routine.output().values
+	info := make([]valueInfo, len(values))
+	for i, v := range values {
+		info[i] = valueInfo{Name: v.Name, Kind: v.Kind.String(), Unit: v.Unit}
+		if len(v.Labels) > 0 {
+			info[i].Labels = make(map[string]string, len(v.Labels))
+			for _, label := range v.Labels {
+				info[i].Labels[label.Name] = label.Value
+			}
+		}
+		switch v.Kind {
+		case value.Number:
+			info[i].Value = v.Number
+		case value.Bool:
+			info[i].Value = v.Bool
+		default:
+			info[i].Value = v.Text
+		}
+	}
+
+	return 200, encodePair("values", info)
+}
+
+// logInfo holds the information that is returned for each log message.
+type logInfo struct {
+	// Time that the message was logged, in RFC 3339 format.
+	Time string `json:"time"`
+
+	// Level of the message: "debug", "info", "warn", or "error".
+	Level string `json:"level"`
+
+	// Text of the message.
+	Message string `json:"message"`
+
+	// Fields of the message, if it has any.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// HandleGetRoutineLogs responds with the recent log messages about the specified routine, from
+// oldest to newest.
+// endpoint: GET /routines/:routine/logs
+func (a apiHandler) HandleGetRoutineLogs(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	routine, err := getRoutine(a.routineList(), params["routine"])
+	if err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	entries := routine.logEntries()
+	info := make([]logInfo, len(entries))
+	for i, e := range entries {
+		info[i] = logInfo{Time: e.time.Format(time.RFC3339), Level: e.level.String(), Message: e.message}
+		forEachField(e.fields, func(key string, value string) {
+			if info[i].Fields == nil {
+				info[i].Fields = make(map[string]string)
+			}
+			info[i].Fields[key] = value
+		})
+	}
+
+	return 200, encodePair("logs", info)
+}
+
+// slotInfo holds the information that is returned for each slot.
+type slotInfo struct {
+	// Name of the slot.
+	Name string `json:"name"`
+
+	// How long each member is shown, in seconds.
+	Period float64 `json:"period"`
+
+	// Whether or not members in the warning or error state stay shown.
+	Pin bool `json:"pin"`
+
+	// Module names of the members, in order.
+	Members []string `json:"members"`
+
+	// Module name of the member that is shown right now. This is empty if no member has anything
+	// to display.
+	Current string `json:"current"`
+
+	// Whether or not the current member is pinned because its output needs attention.
+	Pinned bool `json:"pinned"`
+}
+
+// HandleGetSlotAll responds with every slot and the member that each one is showing.
+// endpoint: GET /slots
+func (a apiHandler) HandleGetSlotAll(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	now := a.now()
+	names := make(map[*routine]string, len(a.routines))
+	for i, name := range routineNames(a.routines) {
+		names[a.routines[i]] = name
+	}
+	infos := make([]slotInfo, 0, len(a.slots))
+	for _, s := range a.slots {
+		info := slotInfo{Name: s.Name, Period: s.Period.Seconds(), Pin: s.Pin, Members: []string{}}
+		for _, r := range a.members(s) {
+			info.Members = append(info.Members, names[r])
+		}
+		if current, pinned := a.current(s, now); current != nil {
+			info.Current, info.Pinned = names[current], pinned
+		}
+		infos = append(infos, info)
+	}
+
+	return 200, encodePair("slots", infos)
+}
+
+// alertInfo holds the information that is returned for each raised alert.
+type alertInfo struct {
+	// Module name of the routine that raised the alert.
+	Routine string `json:"routine"`
+
+	// Name of the rule that raised the alert.
+	Name string `json:"name"`
+
+	// Short description of the alert.
+	Summary string `json:"summary"`
+
+	// Text of the alert.
+	Message string `json:"message"`
+
+	// Whether or not the alert is critical.
+	Critical bool `json:"critical"`
+
+	// Time that the rule's condition became true, in RFC 3339 format.
+	Since string `json:"since"`
+}
+
+// HandleGetAlertAll responds with every alert that is raised right now, in the order of the routines.
+// endpoint: GET /alerts
+func (a apiHandler) HandleGetAlertAll(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	infos := []alertInfo{}
+	for _, r := range a.routineList() {
+		for _, alert := range r.raisedAlerts() {
+			infos = append(infos, alertInfo{
+				Routine:  alert.Routine,
+				Name:     alert.Name,
+				Summary:  alert.Summary,
+				Message:  alert.Message,
+				Critical: alert.Critical,
+				Since:    alert.Since.Format(time.RFC3339),
+			})
+		}
+	}
+
+	return 200, encodePair("alerts", infos)
+}
+
+// layoutInfo holds the order of the routines on the bar.
+type layoutInfo struct {
+	// Module names of the routines, in the order that they are displayed.
+	Routines []string `json:"routines"`
+
+	// Index of the routine after which the bar is split, or -1 if the bar isn't split.
+	Split int `json:"split"`
+
+	// Regions of the bar, in the order that they are displayed. This is empty if no regions were added.
+	Regions []regionInfo `json:"regions,omitempty"`
+}
+
+// regionInfo holds the layout of one region of the bar.
+type regionInfo struct {
+	// Name of the region.
+	Name string `json:"name"`
+
+	// Where the region is placed on the bar: "left", "center", or "right".
+	Align string `json:"align"`
+
+	// Indexes of the routines in the region, in the order that they are displayed.
+	Routines []int `json:"routines"`
+}
+
+// newRoutineRequest holds the settings for a routine that is added through the API.
+type newRoutineRequest struct {
+	// Name of the routine's module, like "sbtime". The module must be in the registry.
+	Module string `json:"module"`
+
+	// Interval time between update runs, in seconds.
+	Interval int `json:"interval"`
+
+	// Index to insert the routine at. If this is missing, the routine is added to the end.
+	Position *int `json:"position"`
+
+	// Name of the region to display the routine in. If this is empty, the routine is displayed in the
+	// first region.
+	Region string `json:"region"`
+
+	// Options for the module, with the same keys as in a configuration file.
+	Options json.RawMessage `json:"options"`
+}
+
+// HandleGetLayout responds with the order of the routines, the split, and the regions.
+// endpoint: GET /layout
+func (a apiHandler) HandleGetLayout(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	a.mutex.RLock()
+	info := layoutInfo{Split: a.split}
+	info.Routines = routineNames(a.routines)
+	indexes := make(map[*routine]int, len(a.routines))
+	for i, r := range a.routines {
+		indexes[r] = i
+	}
+	if len(a.regions) > 0 {
+		regions, groups := a.layout()
+		for i, region := range regions {
+			ri := regionInfo{Name: region.Name, Align: region.Align.String(), Routines: []int{}}
+			for _, r := range groups[i] {
+				ri.Routines = append(ri.Routines, indexes[r])
+			}
+			info.Regions = append(info.Regions, ri)
+		}
+	}
+	a.mutex.RUnlock()
+
+	b, _ := json.Marshal(info)
+	return 200, string(b)
+}
+
+// HandlePostLayoutRoutine creates a new routine from a registered module and adds it to the bar.
+// endpoint: POST /layout/routines
+func (a apiHandler) HandlePostLayoutRoutine(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	var req newRoutineRequest
+	if err := readBody(request, &req); err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	module, ok := registry.Lookup(req.Module)
+	if !ok {
+		return 400, encodePair("error", "invalid module")
+	}
+	if req.Interval < 0 {
+		return 400, encodePair("error", "invalid interval")
+	}
+
+	var options interface{}
+	if module.Options != nil {
+		options = module.Options()
+		if err := registry.DecodeOptions(req.Options, options); err != nil {
+			return 400, encodePair("error", err.Error())
+		}
+	} else if len(req.Options) > 0 {
+		return 400, encodePair("error", req.Module+" does not have any options")
+	}
+
+	handler, err := module.New(options, theme.Theme{})
+	if err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	index := len(a.routineList())
+	if req.Position != nil {
+		index = *req.Position
+	}
+	if req.Region != "" && !a.hasRegion(req.Region) {
+		return 400, encodePair("error", "invalid region")
+	}
+	if err := a.Insert(index, handler, req.Interval, WithRegion(req.Region)); err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	return 201, ""
+}
+
+// HandlePatchLayoutRoutine moves the specified routine to a new position on the bar.
+// endpoint: PATCH /layout/routines/:routine
+func (a apiHandler) HandlePatchLayoutRoutine(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	from, err := getRoutineIndex(a.routineList(), params["routine"])
+	if err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	var req struct {
+		Position *int `json:"position"`
+	}
+	if err := readBody(request, &req); err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+	if req.Position == nil {
+		return 400, encodePair("error", "missing position")
+	}
+
+	if err := a.Move(from, *req.Position); err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	return 202, ""
+}
+
+// HandleDeleteLayoutRoutine stops the specified routine and removes it from the bar.
+// endpoint: DELETE /layout/routines/:routine
+func (a apiHandler) HandleDeleteLayoutRoutine(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	index, err := getRoutineIndex(a.routineList(), params["routine"])
+	if err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	if err := a.Remove(index); err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	return 204, ""
+}
+
+// HandlePutLayoutSplit moves the split to just after the specified index.
+// endpoint: PUT /layout/split
+func (a apiHandler) HandlePutLayoutSplit(endpoint restapi.Endpoint, params restapi.Params, request *http.Request) (int, string) {
+	var req struct {
+		Split *int `json:"split"`
+	}
+	if err := readBody(request, &req); err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+	if req.Split == nil {
+		return 400, encodePair("error", "missing split")
+	}
+
+	if err := a.SetSplit(*req.Split); err != nil {
+		return 400, encodePair("error", err.Error())
+	}
+
+	return 202, ""
+}
+
+// routineNames is a helper function that gives every routine in the list a unique name. A routine is
+// named after its module, and routines after the first of the same module are numbered in order, like
+// "sbdisk", "sbdisk-2", and "sbdisk-3".
+func routineNames(routines []*routine) []string {
+	names := make([]string, len(routines))
+	counts := make(map[string]int)
+	for i, routine := range routines {
+		name := routine.moduleName()
+		counts[name]++
+		if n := counts[name]; n > 1 {
+			name = fmt.Sprintf("%s-%d", name, n)
+		}
+		names[i] = name
+	}
+
+	return names
+}
+
+// getRoutine is a helper function that gets the specified routine from the list of routines. See
+// getRoutineIndex for how routines are specified.
 func getRoutine(routines []*routine, name string) (*routine, error) {
-	for _, routine := range routines {
-		if name == routine.moduleName() {
-			return routine, nil
+	i, err := getRoutineIndex(routines, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return routines[i], nil
+}
+
+// getRoutineIndex is a helper function that gets the index of the specified routine in the list of
+// routines. The routine is specified either by its index or by its name from routineNames.
+func getRoutineIndex(routines []*routine, name string) (int, error) {
+	if i, err := strconv.Atoi(name); err == nil {
+		if i < 0 || i >= len(routines) {
+			return -1, fmt.Errorf("invalid routine")
+		}
+		return i, nil
+	}
+
+	for i, n := range routineNames(routines) {
+		if name == n {
+			return i, nil
 		}
 	}
 
-	return nil, fmt.Errorf("invalid routine")
+	return -1, fmt.Errorf("invalid routine")
+}
+
+// readBody is a helper function that decodes the JSON request body into v.
+func readBody(request *http.Request, v interface{}) error {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+
+	if len(body) == 0 {
+		return fmt.Errorf("missing request body")
+	}
+
+	return json.Unmarshal(body, v)
 }
 
 // encodePair is a helper function that JSON-encodes a key/value pair.
@@ -218,12 +777,21 @@ func encodePair(key string, value interface{}) string {
 // getRoutineInfo returns the routine's information.
 func getRoutineInfo(r *routine) routineInfo {
 	if r != nil {
-		return routineInfo{
+		failures, nextRetry := r.retryState()
+		info := routineInfo{
 			Name:     r.displayName(),
 			Uptime:   r.uptime(),
 			Interval: r.interval(),
 			Active:   r.isActive(),
+			Failures: failures,
+			Restarts: r.restartCount(),
+			Visible:  !r.isHidden(r.output()),
+			Slot:     r.slotName(),
+		}
+		if !nextRetry.IsZero() {
+			info.NextRetry = nextRetry.Format(time.RFC3339)
 		}
+		return info
 	}
 	return routineInfo{}
 }
@@ -0,0 +1,130 @@
+// This file holds the history of the numeric values that each routine reports.
+
+package statusbar
+
+import (
+	"time"
+
+	"github.com/snhilde/statusbar/v5/value"
+)
+
+// History sets how much of a routine's history is kept. After every successful update, the engine
+// records the numeric values that the routine reports (see Valuer) as one sample. The samples can be
+// fetched through the REST API.
+type History struct {
+	// Most samples to keep. When the history is full, the oldest sample is dropped to make room for
+	// the newest one. If this is 0 or less, no history is kept.
+	Depth int
+
+	// Shortest time between samples. Updates that finish less than this after the start of the newest
+	// sample replace its values instead of adding a new sample, so a routine that updates every second
+	// can keep an hour of history in 60 samples with a resolution of 1 minute. If this is 0, every
+	// update is recorded.
+	Resolution time.Duration
+}
+
+// WithHistory keeps a history of the routine's numeric values. Routines that don't implement Valuer
+// don't have any values to record.
+func WithHistory(h History) RoutineOption {
+	return func(r *routine) {
+		r.setHistory(h)
+	}
+}
+
+// sample holds the numeric values of a routine at one point in time.
+type sample struct {
+	// Time that the sample was first recorded.
+	time time.Time
+
+	// Numeric values of the routine, by key (see value.Value.Key).
+	values map[string]float64
+}
+
+// history is a ring buffer of samples.
+type history struct {
+	History
+
+	// Fewest samples to keep, no matter the depth, for routines that draw their history (see Historian).
+	min int
+
+	// Recorded samples. Once the buffer is full, the oldest sample is at start.
+	samples []sample
+	start   int
+
+	// Units of the recorded values, by key.
+	units map[string]string
+}
+
+// record adds the numeric values to the history as a sample taken at now. Values of other kinds are
+// left out, and nothing is recorded if there aren't any numeric values.
+func (h *history) record(now time.Time, values []value.Value) {
+	depth := h.depth()
+	if depth <= 0 {
+		return
+	}
+
+	numbers := make(map[string]float64)
+	for _, v := range values {
+		if v.Kind == value.Number {
+			numbers[v.Key()] = v.Number
+			if h.units == nil {
+				h.units = make(map[string]string)
+			}
+			h.units[v.Key()] = v.Unit
+		}
+	}
+	if len(numbers) == 0 {
+		return
+	}
+
+	if len(h.samples) > 0 {
+		newest := &h.samples[(h.start+len(h.samples)-1)%len(h.samples)]
+		if now.Sub(newest.time) < h.Resolution {
+			newest.values = numbers
+			return
+		}
+	}
+
+	s := sample{time: now, values: numbers}
+	if len(h.samples) < depth {
+		h.samples = append(h.samples, s)
+		return
+	}
+	h.samples[h.start] = s
+	h.start = (h.start + 1) % len(h.samples)
+}
+
+// list returns the recorded samples, from oldest to newest.
+func (h *history) list() []sample {
+	samples := make([]sample, 0, len(h.samples))
+	samples = append(samples, h.samples[h.start:]...)
+	samples = append(samples, h.samples[:h.start]...)
+
+	return samples
+}
+
+// resize changes the depth and resolution of the history. The newest samples that still fit are
+// kept.
+func (h *history) resize(settings History) {
+	h.History = settings
+	samples, depth := h.list(), h.depth()
+	if depth <= 0 {
+		samples = nil
+	} else if len(samples) > depth {
+		samples = samples[len(samples)-depth:]
+	}
+
+	h.samples, h.start = samples, 0
+	if len(samples) == 0 {
+		h.units = nil
+	}
+}
+
+// depth returns the most samples to keep.
+func (h *history) depth() int {
+	if h.min > h.Depth {
+		return h.min
+	}
+
+	return h.Depth
+}
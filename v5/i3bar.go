@@ -0,0 +1,167 @@
+// This file holds the sink that speaks the i3bar JSON protocol, which is also used by swaybar.
+
+package statusbar
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/snhilde/statusbar/v5/markup"
+)
+
+// Block is the output of a single routine, as used by sinks that display each routine separately
+// instead of as one composed string.
+type Block struct {
+	// Module name of the routine that produced this block.
+	Name string `json:"name"`
+
+	// Identifier that is unique to the routine that produced this block. This is used to route
+	// clicks back to the correct routine.
+	Instance string `json:"instance"`
+
+	// Routine's output, without any markup.
+	FullText string `json:"full_text"`
+
+	// Foreground color of the text, as a hex code like "#FFFFFF". Empty for the default color.
+	Color string `json:"color,omitempty"`
+
+	// Background color of the block, as a hex code. Empty for the default color.
+	Background string `json:"background,omitempty"`
+
+	// Whether or not the routine is reporting an error.
+	Urgent bool `json:"urgent,omitempty"`
+
+	// Name of the region that the routine is displayed in (see AddRegion), for sinks that lay out the
+	// regions themselves. This is empty if no regions were added.
+	Region string `json:"-"`
+}
+
+// BlockSink is an optional interface for sinks that display every routine in its own block. If a
+// sink implements this interface, the engine calls WriteBlocks instead of Write for each frame.
+type BlockSink interface {
+	Sink
+
+	// WriteBlocks outputs one frame of the statusbar, with one block for every routine that has
+	// output to display.
+	WriteBlocks(blocks []Block) error
+}
+
+// ClickSink is an optional interface for sinks that receive mouse clicks on the statusbar.
+type ClickSink interface {
+	Sink
+
+	// OnClick is called once by the engine before the statusbar starts. The sink should call
+	// handler for every click it receives, with the Instance of the block that was clicked and
+	// the mouse button that was pressed.
+	OnClick(handler func(instance string, button int))
+}
+
+// i3barHeader is the first thing sent to i3bar to start the protocol.
+type i3barHeader struct {
+	Version     int  `json:"version"`
+	ClickEvents bool `json:"click_events"`
+}
+
+// i3barClick is a click event as sent by i3bar.
+type i3barClick struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance"`
+	Button   int    `json:"button"`
+}
+
+// i3barSink writes frames using the i3bar protocol and reads click events back.
+type i3barSink struct {
+	mutex   sync.Mutex
+	w       io.Writer
+	r       io.Reader
+	started bool
+}
+
+// NewI3barSink returns a sink that speaks the i3bar JSON protocol on stdout and reads click events
+// from stdin. Use this as the status_command for i3bar or swaybar.
+func NewI3barSink() Sink {
+	return NewI3barSinkIO(os.Stdout, os.Stdin)
+}
+
+// NewI3barSinkIO is like NewI3barSink, but it writes the protocol to w and reads click events from
+// r. r can be nil if click events are not needed.
+func NewI3barSinkIO(w io.Writer, r io.Reader) Sink {
+	return &i3barSink{w: w, r: r}
+}
+
+// Write writes bar as a single block. The engine calls WriteBlocks for normal output; this is used
+// only for status messages that don't belong to a routine.
+func (s *i3barSink) Write(bar string) error {
+	text := markup.Text(markup.ParseStatus2d(bar))
+	return s.WriteBlocks([]Block{{Name: "statusbar", FullText: text}})
+}
+
+// WriteBlocks writes one element of the infinite array of the i3bar protocol.
+func (s *i3barSink) WriteBlocks(blocks []Block) error {
+	if s == nil || s.w == nil {
+		return fmt.Errorf("invalid sink")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.started {
+		header, err := json.Marshal(i3barHeader{Version: 1, ClickEvents: s.r != nil})
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(s.w, "%s\n[\n", header); err != nil {
+			return err
+		}
+		s.started = true
+	}
+
+	// Make sure we always send an array, even when there aren't any blocks.
+	if blocks == nil {
+		blocks = []Block{}
+	}
+
+	b, err := json.Marshal(blocks)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(s.w, "%s,\n", b)
+	return err
+}
+
+// Close does nothing for the i3bar sink. i3bar handles the end of the stream itself.
+func (s *i3barSink) Close() error {
+	return nil
+}
+
+// OnClick starts reading click events in a new goroutine and calls handler for each one.
+func (s *i3barSink) OnClick(handler func(instance string, button int)) {
+	if s == nil || s.r == nil || handler == nil {
+		return
+	}
+
+	go func() {
+		scanner := bufio.NewScanner(s.r)
+		for scanner.Scan() {
+			// The click events are sent as an infinite array. The first line opens the array, and
+			// every line after that has one event, possibly with a leading comma.
+			line := bytes.TrimSpace(scanner.Bytes())
+			line = bytes.TrimSpace(bytes.TrimPrefix(line, []byte(",")))
+			if len(line) == 0 || line[0] != '{' {
+				continue
+			}
+
+			var click i3barClick
+			if err := json.Unmarshal(line, &click); err != nil {
+				continue
+			}
+			handler(click.Instance, click.Button)
+		}
+	}()
+}
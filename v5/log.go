@@ -0,0 +1,179 @@
+// This file holds the engine's leveled logger and the recent logs that are kept for each routine.
+
+package statusbar
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log message.
+type Level int
+
+// These are the levels of log messages, from least to most severe.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// levelNames are the names of the levels, in order.
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// String returns the name of the level, like "warn".
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "Level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the name, which is one of "debug", "info", "warn", or "error".
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if name == n {
+			return Level(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// Logger receives the engine's log messages. Along with its message, each log has a level and any
+// number of fields, which are passed as alternating keys and values, like "routine", "sbbattery",
+// "error", err. Messages about a routine always start with the "routine" field. Loggers must be safe
+// to call from many goroutines at once.
+type Logger interface {
+	Log(level Level, msg string, keyvals ...interface{})
+}
+
+// textLogger writes each message on one line, with its fields written as key=value after it.
+type textLogger struct {
+	// Function that writes a line.
+	print func(v ...interface{})
+
+	// Lowest level of the messages that are written.
+	level Level
+}
+
+// NewLogger returns a Logger that writes every message at level or above to w on its own line, like
+// "2021/04/05 13:02:00 WARN Update failed routine=sbweather error="connection refused"".
+func NewLogger(w io.Writer, level Level) Logger {
+	return &textLogger{print: log.New(w, "", log.LstdFlags).Print, level: level}
+}
+
+// Log writes the message if it is at the logger's level or above.
+func (t *textLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	if t == nil || level < t.level {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteString(" ")
+	b.WriteString(msg)
+	forEachField(keyvals, func(key string, value string) {
+		b.WriteString(" " + key + "=" + quoteField(value))
+	})
+	t.print(b.String())
+}
+
+// logger is the Logger that every message is sent to. By default, messages at the info level and
+// above are written with the standard log package.
+var logger = struct {
+	sync.RWMutex
+	l Logger
+}{l: &textLogger{print: log.Print, level: LevelInfo}}
+
+// SetLogger sets the Logger that the engine sends its messages to. This covers the whole program,
+// including messages from every statusbar and routine. A nil logger goes back to the default, which
+// writes messages at the info level and above with the standard log package.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = &textLogger{print: log.Print, level: LevelInfo}
+	}
+
+	logger.Lock()
+	defer logger.Unlock()
+	logger.l = l
+}
+
+// logAt sends a message to the logger.
+func logAt(level Level, msg string, keyvals ...interface{}) {
+	logger.RLock()
+	l := logger.l
+	logger.RUnlock()
+
+	l.Log(level, msg, keyvals...)
+}
+
+// forEachField calls fn with every key and its value from keyvals, formatted as strings. If the last
+// key is missing its value, the value is used with the key "!BADKEY".
+func forEachField(keyvals []interface{}, fn func(key string, value string)) {
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 == len(keyvals) {
+			fn("!BADKEY", fmt.Sprint(keyvals[i]))
+			break
+		}
+		fn(fmt.Sprint(keyvals[i]), fmt.Sprint(keyvals[i+1]))
+	}
+}
+
+// quoteField quotes a field's value if it is empty or has spaces, quotes, or equal signs in it.
+func quoteField(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// logDepth is the number of log entries that are kept for each routine.
+const logDepth = 100
+
+// logEntry is a log message that was kept for a routine.
+type logEntry struct {
+	// Time that the message was logged.
+	time time.Time
+
+	// Level of the message.
+	level Level
+
+	// Text of the message.
+	message string
+
+	// Alternating keys and values of the message's fields.
+	fields []interface{}
+}
+
+// logRing keeps a routine's most recent log entries in a ring buffer.
+type logRing struct {
+	// Entries in the buffer. Once the buffer is full, the oldest entry is at next.
+	entries []logEntry
+
+	// Index in entries where the next entry will be written.
+	next int
+}
+
+// add adds an entry to the buffer, replacing the oldest entry if the buffer is full.
+func (l *logRing) add(e logEntry) {
+	if len(l.entries) < logDepth {
+		l.entries = append(l.entries, e)
+		return
+	}
+
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % logDepth
+}
+
+// list returns the entries in the buffer, from oldest to newest.
+func (l *logRing) list() []logEntry {
+	entries := make([]logEntry, 0, len(l.entries))
+	entries = append(entries, l.entries[l.next:]...)
+	return append(entries, l.entries[:l.next]...)
+}
@@ -0,0 +1,302 @@
+// Package markup describes routine output as structured segments and renders those segments for
+// the various statusbar programs.
+//
+// A routine that implements the statusbar's Segmenter interface returns its output as a list of
+// Segments, each holding plain text along with its colors and state. The statusbar engine then
+// renders the segments with the Markup that matches the program displaying the bar, so the same
+// routine can be shown with dwm's status2d patch, Pango (i3bar, swaybar, somebar), lemonbar, or a
+// terminal.
+package markup
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// State is the condition that a segment of output is reporting.
+type State int
+
+// These are the possible states of a segment.
+const (
+	// StateNormal is for output that doesn't need any attention.
+	StateNormal State = iota
+
+	// StateWarning is for output that is approaching a problem.
+	StateWarning
+
+	// StateError is for output that is reporting a problem.
+	StateError
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateNormal:
+		return "normal"
+	case StateWarning:
+		return "warning"
+	case StateError:
+		return "error"
+	}
+
+	return "unknown"
+}
+
+// Segment is a piece of a routine's output.
+type Segment struct {
+	// Text to display, without any markup.
+	Text string
+
+	// Foreground (text) color as a hex code, like "#FFFFFF". Leave empty for the default color.
+	Foreground string
+
+	// Background color as a hex code. Leave empty for the default color.
+	Background string
+
+	// State that this segment is reporting.
+	State State
+
+	// Status2d escapes, such as ^r^ rectangles, that the Status2d markup draws in place of Text. Other
+	// markups display Text, so Text should show the same thing with plain characters. The drawing is
+	// dropped when Text is shortened or scrolled.
+	Drawing string
+}
+
+// Markup renders segments into the format understood by a particular statusbar program.
+type Markup interface {
+	// Render formats the segments into a single string.
+	Render(segments []Segment) string
+}
+
+// These are the markups that are included in this package.
+var (
+	// Status2d renders segments with the escape sequences used by dwm's status2d patch.
+	Status2d Markup = status2d{}
+
+	// Pango renders segments with Pango markup, as used by i3bar, swaybar, and somebar.
+	Pango Markup = pango{}
+
+	// Lemonbar renders segments with lemonbar's formatting blocks.
+	Lemonbar Markup = lemonbar{}
+
+	// ANSI renders segments with 24-bit ANSI color escapes for terminals and tmux.
+	ANSI Markup = ansi{}
+
+	// Plain renders only the text of the segments, without any colors.
+	Plain Markup = plain{}
+)
+
+// Text returns the text of all the segments without any markup.
+func Text(segments []Segment) string {
+	return Plain.Render(segments)
+}
+
+// WorstState returns the most severe state of all the segments.
+func WorstState(segments []Segment) State {
+	state := StateNormal
+	for _, segment := range segments {
+		if segment.State > state {
+			state = segment.State
+		}
+	}
+
+	return state
+}
+
+// status2d renders segments for dwm's status2d patch.
+type status2d struct{}
+
+// Render sets the colors for each segment with ^c#RRGGBB^ and ^b#RRGGBB^ and resets them
+// afterwards with ^d^. Segments with a drawing are drawn instead of printed.
+func (status2d) Render(segments []Segment) string {
+	b := new(strings.Builder)
+	for _, segment := range segments {
+		if segment.Foreground != "" {
+			fmt.Fprintf(b, "^c%s^", segment.Foreground)
+		}
+		if segment.Background != "" {
+			fmt.Fprintf(b, "^b%s^", segment.Background)
+		}
+
+		// A caret would start a new escape sequence, and there's no way to escape it.
+		if segment.Drawing != "" {
+			b.WriteString(segment.Drawing)
+		} else {
+			b.WriteString(strings.ReplaceAll(segment.Text, "^", ""))
+		}
+
+		if segment.Foreground != "" || segment.Background != "" {
+			b.WriteString("^d^")
+		}
+	}
+
+	return b.String()
+}
+
+// pango renders segments with Pango markup.
+type pango struct{}
+
+// Render wraps each colored segment in a span tag.
+func (pango) Render(segments []Segment) string {
+	b := new(strings.Builder)
+	for _, segment := range segments {
+		text := html.EscapeString(segment.Text)
+		if segment.Foreground == "" && segment.Background == "" {
+			b.WriteString(text)
+			continue
+		}
+
+		b.WriteString("<span")
+		if segment.Foreground != "" {
+			fmt.Fprintf(b, " foreground=\"%s\"", html.EscapeString(segment.Foreground))
+		}
+		if segment.Background != "" {
+			fmt.Fprintf(b, " background=\"%s\"", html.EscapeString(segment.Background))
+		}
+		fmt.Fprintf(b, ">%s</span>", text)
+	}
+
+	return b.String()
+}
+
+// lemonbar renders segments with lemonbar's formatting blocks.
+type lemonbar struct{}
+
+// Render sets the colors for each segment with %{F#RRGGBB} and %{B#RRGGBB} and resets them
+// afterwards with %{F-} and %{B-}.
+func (lemonbar) Render(segments []Segment) string {
+	b := new(strings.Builder)
+	for _, segment := range segments {
+		if segment.Foreground != "" {
+			fmt.Fprintf(b, "%%{F%s}", segment.Foreground)
+		}
+		if segment.Background != "" {
+			fmt.Fprintf(b, "%%{B%s}", segment.Background)
+		}
+
+		b.WriteString(strings.ReplaceAll(segment.Text, "%", "%%"))
+
+		if segment.Foreground != "" {
+			b.WriteString("%{F-}")
+		}
+		if segment.Background != "" {
+			b.WriteString("%{B-}")
+		}
+	}
+
+	return b.String()
+}
+
+// ansi renders segments with ANSI escape sequences.
+type ansi struct{}
+
+// Render sets the colors for each segment with 24-bit color escapes and resets them afterwards.
+// Colors that aren't valid hex codes are ignored.
+func (ansi) Render(segments []Segment) string {
+	b := new(strings.Builder)
+	for _, segment := range segments {
+		colored := false
+		if r, g, bl, ok := parseHex(segment.Foreground); ok {
+			fmt.Fprintf(b, "\x1b[38;2;%d;%d;%dm", r, g, bl)
+			colored = true
+		}
+		if r, g, bl, ok := parseHex(segment.Background); ok {
+			fmt.Fprintf(b, "\x1b[48;2;%d;%d;%dm", r, g, bl)
+			colored = true
+		}
+
+		b.WriteString(segment.Text)
+
+		if colored {
+			b.WriteString("\x1b[0m")
+		}
+	}
+
+	return b.String()
+}
+
+// plain renders only the text of the segments.
+type plain struct{}
+
+// Render joins the text of every segment.
+func (plain) Render(segments []Segment) string {
+	b := new(strings.Builder)
+	for _, segment := range segments {
+		b.WriteString(segment.Text)
+	}
+
+	return b.String()
+}
+
+// ParseStatus2d splits a string formatted with status2d escape sequences into segments. Only the
+// color escapes (^c^, ^b^, and ^d^) are kept; all other escapes are dropped. This is used to
+// render routines that only format their output with status2d for other markups.
+func ParseStatus2d(s string) []Segment {
+	var segments []Segment
+	var current Segment
+
+	// flush adds the current text as a segment and starts a new one with the same colors.
+	flush := func() {
+		if current.Text != "" {
+			segments = append(segments, current)
+		}
+		current.Text = ""
+	}
+
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '^')
+		if i < 0 {
+			current.Text += s
+			break
+		}
+		current.Text += s[:i]
+		s = s[i+1:]
+
+		// Find the end of the escape. If there isn't one, then this was a literal caret.
+		j := strings.IndexByte(s, '^')
+		if j < 0 {
+			current.Text += "^" + s
+			break
+		}
+
+		escape := s[:j]
+		s = s[j+1:]
+		switch {
+		case strings.HasPrefix(escape, "c"):
+			flush()
+			current.Foreground = escape[1:]
+		case strings.HasPrefix(escape, "b"):
+			flush()
+			current.Background = escape[1:]
+		case escape == "d":
+			flush()
+			current.Foreground = ""
+			current.Background = ""
+		}
+	}
+	flush()
+
+	return segments
+}
+
+// parseHex parses a color code of the form "#RRGGBB" into its red, green, and blue values.
+func parseHex(color string) (int, int, int, bool) {
+	var r, g, b int
+	if len(color) != 7 || color[0] != '#' {
+		return 0, 0, 0, false
+	}
+
+	if _, err := fmt.Sscanf(color[1:], "%02x%02x%02x", &r, &g, &b); err != nil {
+		return 0, 0, 0, false
+	}
+
+	return r, g, b, true
+}
+
+// Truncate shortens the segments so that their text takes up no more than max columns. If the text
+// needs to be shortened, the last characters that fit are replaced with "...". The segments' colors
+// are kept intact. This is the same as TruncateWidth with EllipsisEnd.
+func Truncate(segments []Segment, max int) []Segment {
+	return TruncateWidth(segments, max, EllipsisEnd)
+}
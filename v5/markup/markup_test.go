@@ -0,0 +1,169 @@
+package markup_test
+
+import (
+	"testing"
+
+	"github.com/snhilde/statusbar/v5/markup"
+)
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	segments := []markup.Segment{
+		{Text: "42% CPU", Foreground: "#FFFFFF"},
+		{Text: ", "},
+		{Text: "<hot>", Foreground: "#A1273E", Background: "#000000", State: markup.StateError},
+	}
+
+	tests := []struct {
+		name   string
+		markup markup.Markup
+		want   string
+	}{
+		{"status2d", markup.Status2d, "^c#FFFFFF^42% CPU^d^, ^c#A1273E^^b#000000^<hot>^d^"},
+		{"pango", markup.Pango,
+			"<span foreground=\"#FFFFFF\">42% CPU</span>, " +
+				"<span foreground=\"#A1273E\" background=\"#000000\">&lt;hot&gt;</span>"},
+		{"lemonbar", markup.Lemonbar, "%{F#FFFFFF}42%% CPU%{F-}, %{F#A1273E}%{B#000000}<hot>%{F-}%{B-}"},
+		{"ansi", markup.ANSI, "\x1b[38;2;255;255;255m42% CPU\x1b[0m, \x1b[38;2;161;39;62m\x1b[48;2;0;0;0m<hot>\x1b[0m"},
+		{"plain", markup.Plain, "42% CPU, <hot>"},
+	}
+
+	for _, test := range tests {
+		if got := test.markup.Render(segments); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+
+	if state := markup.WorstState(segments); state != markup.StateError {
+		t.Errorf("Expected worst state to be %v, got %v", markup.StateError, state)
+	}
+}
+
+func TestParseStatus2d(t *testing.T) {
+	t.Parallel()
+
+	s := "^c#FFFFFF^42% CPU^d^, ^c#A1273E^^b#000000^hot^d^"
+	if got := markup.Status2d.Render(markup.ParseStatus2d(s)); got != s {
+		t.Errorf("Expected %q, got %q", s, got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	segments := []markup.Segment{{Text: "abcde", Foreground: "#FFFFFF"}, {Text: "fghij"}}
+	got := markup.Status2d.Render(markup.Truncate(segments, 8))
+	if want := "^c#FFFFFF^abcde...^d^"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	bar := []markup.Segment{{Text: "████░░░░", Drawing: "^r0,7,20,4^^f40^"}, {Text: " CPU"}}
+	if got := markup.Status2d.Render(bar); got != "^r0,7,20,4^^f40^ CPU" {
+		t.Errorf("Expected drawing in place of text, got %q", got)
+	}
+	if got := markup.Status2d.Render(markup.Truncate(bar, 6)); got != "███..." {
+		t.Errorf("Expected drawing to be dropped when shortened, got %q", got)
+	}
+	if got := markup.Plain.Render(bar); got != "████░░░░ CPU" {
+		t.Errorf("Expected text without drawing, got %q", got)
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	t.Parallel()
+
+	red := []markup.Segment{{Text: "↓ 12.5 MB/s", Foreground: "#FF0000"}, {Text: " 45°C"}}
+	tests := []struct {
+		segments []markup.Segment
+		width    int
+		ellipsis markup.Ellipsis
+		want     string
+	}{
+		{red, 16, markup.EllipsisEnd, "^c#FF0000^↓ 12.5 MB/s^d^ 45°C"},
+		{red, 0, markup.EllipsisEnd, "^c#FF0000^↓ 12.5 MB/s^d^ 45°C"},
+		{red, 14, markup.EllipsisEnd, "^c#FF0000^↓ 12.5 MB/s...^d^"},
+		{red, 9, markup.EllipsisStart, "^c#FF0000^...s^d^ 45°C"},
+		{red, 10, markup.EllipsisMiddle, "^c#FF0000^↓ 12...^d^5°C"},
+		{[]markup.Segment{{Text: "日本語のテキスト"}}, 8, markup.EllipsisEnd, "日本..."},
+		{[]markup.Segment{{Text: "日本語のテキスト"}}, 8, markup.EllipsisStart, "...スト"},
+		{[]markup.Segment{{Text: "café olé"}}, 7, markup.EllipsisEnd, "café..."},
+
+		// Widths too narrow for the ellipsis cut the text without one.
+		{red, 1, markup.EllipsisEnd, "^c#FF0000^↓^d^"},
+		{red, 2, markup.EllipsisEnd, "^c#FF0000^↓ ^d^"},
+		{red, 1, markup.EllipsisStart, "C"},
+		{red, 2, markup.EllipsisStart, "°C"},
+		{red, 2, markup.EllipsisMiddle, "^c#FF0000^↓ ^d^"},
+		{[]markup.Segment{{Text: "日本語"}}, 1, markup.EllipsisEnd, ""},
+		{[]markup.Segment{{Text: "日本語"}}, 2, markup.EllipsisEnd, "日"},
+	}
+
+	for i, test := range tests {
+		got := markup.Status2d.Render(markup.TruncateWidth(test.segments, test.width, test.ellipsis))
+		if got != test.want {
+			t.Errorf("Test %d: expected %q, got %q", i, test.want, got)
+		}
+	}
+
+	if width := markup.Width("↓ 日本 é"); width != 8 {
+		t.Errorf("Expected width of 8, got %d", width)
+	}
+}
+
+func TestJoinRegions(t *testing.T) {
+	regions := []markup.Region{
+		{Name: "left", Text: "a"},
+		{Name: "right", Align: markup.AlignRight, Text: "b"},
+		{Name: "center", Align: markup.AlignCenter, Text: "c"},
+		{Name: "empty", Align: markup.AlignCenter},
+	}
+
+	if s := markup.JoinRegions(markup.Status2d, regions); s != "a;b;c;" {
+		t.Errorf("Expected regions joined with semicolons, got %q", s)
+	}
+	if s := markup.JoinRegions(markup.Lemonbar, regions); s != "%{l}a%{c}c%{r}b" {
+		t.Errorf("Expected aligned regions, got %q", s)
+	}
+
+	// Markups without a way to split or align the bar join the regions with a space.
+	for _, m := range []markup.Markup{markup.Pango, markup.ANSI, markup.Plain} {
+		if s := markup.JoinRegions(m, regions); s != "a b c" {
+			t.Errorf("Expected regions joined with spaces, got %q", s)
+		}
+	}
+}
+
+func TestScroll(t *testing.T) {
+	red := markup.Segment{Text: "ab", Foreground: "#FF0000"}
+	wide := markup.Segment{Text: "日本"}
+	segments := []markup.Segment{red, wide}
+
+	tests := []struct {
+		offset int
+		want   string
+	}{
+		{0, "ab日"},
+		{1, "b日 "},
+		{2, "日本"},
+		{3, " 本 "},
+		{6, " ab "},
+		{7, "ab日"},
+	}
+	for _, test := range tests {
+		scrolled := markup.Scroll(segments, 4, test.offset, " ")
+		if s := markup.Text(scrolled); s != test.want {
+			t.Errorf("Offset %d: expected %q, got %q", test.offset, test.want, s)
+		}
+		if markup.Width(markup.Text(scrolled)) != 4 {
+			t.Errorf("Offset %d: expected 4 columns, got %q", test.offset, markup.Text(scrolled))
+		}
+	}
+
+	if scrolled := markup.Scroll(segments, 4, 0, " "); scrolled[0].Foreground != "#FF0000" || scrolled[1].Foreground != "" {
+		t.Errorf("Expected colors to be kept: %+v", scrolled)
+	}
+	if scrolled := markup.Scroll(segments, 6, 3, " "); len(scrolled) != 2 {
+		t.Errorf("Expected segments that fit to be kept as is: %+v", scrolled)
+	}
+}
@@ -0,0 +1,130 @@
+// This file holds the regions that the bar is divided into and how each markup lays them out.
+
+package markup
+
+import (
+	"strings"
+)
+
+// Align is where a region is placed on the bar.
+type Align int
+
+// These are the possible alignments of a region.
+const (
+	// AlignLeft places the region on the left side of the bar.
+	AlignLeft Align = iota
+
+	// AlignCenter places the region in the center of the bar.
+	AlignCenter
+
+	// AlignRight places the region on the right side of the bar.
+	AlignRight
+)
+
+// String returns the name of the alignment.
+func (a Align) String() string {
+	switch a {
+	case AlignLeft:
+		return "left"
+	case AlignCenter:
+		return "center"
+	case AlignRight:
+		return "right"
+	}
+
+	return "unknown"
+}
+
+// Region is the rendered output of one region of the bar.
+type Region struct {
+	// Name of the region.
+	Name string
+
+	// Where the region is placed on the bar.
+	Align Align
+
+	// Output of every routine in the region, already rendered with the markup and joined together.
+	Text string
+}
+
+// RegionJoiner is an optional interface for markups whose programs have their own way of laying out
+// the regions of the bar, such as by alignment.
+type RegionJoiner interface {
+	// JoinRegions combines the regions, in order, into the output for the whole bar.
+	JoinRegions(regions []Region) string
+}
+
+// JoinRegions combines the regions into the output for the whole bar with the markup m. If m
+// implements RegionJoiner, then m lays out the regions. Otherwise, the regions that aren't empty are
+// joined in order with a space. Every markup in this package implements RegionJoiner.
+func JoinRegions(m Markup, regions []Region) string {
+	if joiner, ok := m.(RegionJoiner); ok {
+		return joiner.JoinRegions(regions)
+	}
+
+	return joinInline(regions)
+}
+
+// joinInline joins the regions that aren't empty in order with a space, for programs that show the
+// bar as one line of text without any way to align parts of it.
+func joinInline(regions []Region) string {
+	texts := make([]string, 0, len(regions))
+	for _, region := range regions {
+		if region.Text != "" {
+			texts = append(texts, region.Text)
+		}
+	}
+
+	return strings.Join(texts, " ")
+}
+
+// JoinRegions joins the regions in order with a semicolon (';'), which is how dwm's dualstatus and
+// extrabar patches split the bar. Empty regions are kept so that each region stays in its place.
+func (status2d) JoinRegions(regions []Region) string {
+	texts := make([]string, len(regions))
+	for i, region := range regions {
+		texts[i] = region.Text
+	}
+
+	return strings.Join(texts, ";")
+}
+
+// JoinRegions joins the regions that aren't empty in order with a space. A line of Pango markup
+// can't align parts of itself, so the regions' alignments aren't used.
+func (pango) JoinRegions(regions []Region) string {
+	return joinInline(regions)
+}
+
+// JoinRegions joins the regions that aren't empty in order with a space. A line of terminal output
+// can't align parts of itself, so the regions' alignments aren't used.
+func (ansi) JoinRegions(regions []Region) string {
+	return joinInline(regions)
+}
+
+// JoinRegions joins the regions that aren't empty in order with a space. The regions' alignments
+// aren't used.
+func (plain) JoinRegions(regions []Region) string {
+	return joinInline(regions)
+}
+
+// JoinRegions places each region with lemonbar's alignment blocks (%{l}, %{c}, and %{r}). Regions
+// with the same alignment are joined with a space.
+func (lemonbar) JoinRegions(regions []Region) string {
+	b := new(strings.Builder)
+	for _, align := range []Align{AlignLeft, AlignCenter, AlignRight} {
+		var texts []string
+		for _, region := range regions {
+			if region.Align == align && region.Text != "" {
+				texts = append(texts, region.Text)
+			}
+		}
+		if len(texts) == 0 {
+			continue
+		}
+
+		b.WriteString("%{" + align.String()[:1] + "}")
+		b.WriteString(strings.Join(texts, " "))
+	}
+
+	return b.String()
+}
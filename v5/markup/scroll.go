@@ -0,0 +1,100 @@
+// This file scrolls segments through a window of a fixed width.
+
+package markup
+
+// cell is one character of a segment's text, along with any combining marks that follow it.
+type cell struct {
+	// Index of the segment that the character belongs to, or -1 for the gap between loops.
+	segment int
+
+	// Text of the character and its combining marks.
+	text string
+
+	// Number of columns that the character takes up (see RuneWidth).
+	width int
+}
+
+// Scroll returns the part of the segments that is shown in a window width columns wide after the text
+// has scrolled offset columns to the left, like a marquee. The text loops around with gap in between
+// the end and the start, so any offset is valid. Each character keeps the colors and state of its
+// segment, so markup is never cut apart, but drawings are dropped. If a wide character is cut in half
+// by either edge of the window, the half that is shown is replaced with a space. If the text already
+// fits in width columns, the segments are returned as is.
+func Scroll(segments []Segment, width int, offset int, gap string) []Segment {
+	cells := toCells(segments)
+	total := 0
+	for _, c := range cells {
+		total += c.width
+	}
+	if width <= 0 || total <= width {
+		return segments
+	}
+
+	for _, c := range toCells([]Segment{{Text: gap}}) {
+		c.segment = -1
+		cells = append(cells, c)
+	}
+	columns := make([]int, 0, total) // Cell that each column belongs to.
+	for i, c := range cells {
+		for j := 0; j < c.width; j++ {
+			columns = append(columns, i)
+		}
+	}
+
+	offset %= len(columns)
+	if offset < 0 {
+		offset += len(columns)
+	}
+
+	var scrolled []Segment
+	var owners []int // Segment that each scrolled segment was taken from.
+	add := func(segment int, text string) {
+		if last := len(scrolled) - 1; last >= 0 && owners[last] == segment {
+			scrolled[last].Text += text
+			return
+		}
+
+		s := Segment{Text: text}
+		if segment >= 0 {
+			s = segments[segment]
+			s.Text, s.Drawing = text, ""
+		}
+		scrolled = append(scrolled, s)
+		owners = append(owners, segment)
+	}
+
+	for col := 0; col < width; {
+		index := (offset + col) % len(columns)
+		c := cells[columns[index]]
+		starts := index == 0 || columns[index-1] != columns[index]
+		if !starts || col+c.width > width {
+			// Only part of a wide character fits in the window.
+			add(c.segment, " ")
+			col++
+			continue
+		}
+		add(c.segment, c.text)
+		col += c.width
+	}
+
+	return scrolled
+}
+
+// toCells breaks the text of the segments into characters, each marked with the index of its segment.
+func toCells(segments []Segment) []cell {
+	var cells []cell
+	for i, s := range segments {
+		for _, r := range s.Text {
+			w := RuneWidth(r)
+			switch {
+			case w > 0:
+				cells = append(cells, cell{segment: i, text: string(r), width: w})
+			case len(cells) > 0:
+				// Keep combining marks with the character that they belong to.
+				cells[len(cells)-1].text += string(r)
+			}
+		}
+	}
+
+	return cells
+}
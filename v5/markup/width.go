@@ -0,0 +1,221 @@
+// This file measures text in display columns and shortens segments to fit a width.
+
+package markup
+
+import (
+	"unicode"
+)
+
+// Ellipsis is where the ellipsis goes when output is shortened.
+type Ellipsis int
+
+// These are the possible places for the ellipsis.
+const (
+	// EllipsisEnd keeps the start of the text and puts the ellipsis at the end.
+	EllipsisEnd Ellipsis = iota
+
+	// EllipsisMiddle keeps the start and the end of the text and puts the ellipsis in between.
+	EllipsisMiddle
+
+	// EllipsisStart keeps the end of the text and puts the ellipsis at the start.
+	EllipsisStart
+)
+
+// String returns the name of the placement.
+func (e Ellipsis) String() string {
+	switch e {
+	case EllipsisEnd:
+		return "end"
+	case EllipsisMiddle:
+		return "middle"
+	case EllipsisStart:
+		return "start"
+	}
+
+	return "unknown"
+}
+
+// ellipsis is the text that marks where output was shortened.
+const ellipsis = "..."
+
+// wideRanges are the ranges of characters that take up two columns, such as CJK characters and
+// most emoji.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo
+	{0x231A, 0x231B},   // Watch, hourglass
+	{0x2329, 0x232A},   // Angle brackets
+	{0x23E9, 0x23EC},   // Media controls
+	{0x23F0, 0x23F0},   // Alarm clock
+	{0x23F3, 0x23F3},   // Hourglass
+	{0x25FD, 0x25FE},   // Small squares
+	{0x2614, 0x2615},   // Umbrella, hot beverage
+	{0x26A1, 0x26A1},   // High voltage
+	{0x26D4, 0x26D4},   // No entry
+	{0x2705, 0x2705},   // Check mark
+	{0x274C, 0x274C},   // Cross mark
+	{0x2E80, 0x303E},   // CJK radicals and punctuation
+	{0x3041, 0x33FF},   // Kana and CJK symbols
+	{0x3400, 0x4DBF},   // CJK extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xA960, 0xA97F},   // Hangul Jamo extended A
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE10, 0xFE19},   // Vertical forms
+	{0xFE30, 0xFE6F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x1F300, 0x1F64F}, // Pictographs and emoticons
+	{0x1F680, 0x1F6FF}, // Transport and map symbols
+	{0x1F900, 0x1F9FF}, // Supplemental pictographs
+	{0x1FA70, 0x1FAFF}, // Extended pictographs
+	{0x20000, 0x3FFFD}, // CJK extensions B and later
+}
+
+// RuneWidth returns the number of columns that r takes up when displayed. Control characters and
+// combining marks take up no columns, wide characters such as CJK characters and emoji take up two,
+// and everything else takes up one.
+func RuneWidth(r rune) int {
+	switch {
+	case r < 0x20 || (r >= 0x7F && r < 0xA0):
+		return 0
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+
+	for _, span := range wideRanges {
+		if r < span[0] {
+			break
+		}
+		if r <= span[1] {
+			return 2
+		}
+	}
+
+	return 1
+}
+
+// Width returns the number of columns that s takes up when displayed. s should be plain text
+// without any markup.
+func Width(s string) int {
+	width := 0
+	for _, r := range s {
+		width += RuneWidth(r)
+	}
+
+	return width
+}
+
+// TruncateWidth shortens the segments so that their text takes up no more than width columns (see
+// Width). If the text needs to be shortened, part of it is replaced with "..." at the position set
+// by e, and the ellipsis takes on the colors of the text next to it. If width is too narrow for the
+// ellipsis, the text is cut to width columns without one, keeping the end of the text for
+// EllipsisStart and the start of it otherwise. Because only the segments' text is counted, markup is
+// never cut apart. A width of 0 or less means no limit.
+func TruncateWidth(segments []Segment, width int, e Ellipsis) []Segment {
+	total := 0
+	for _, segment := range segments {
+		total += Width(segment.Text)
+	}
+	if width <= 0 || total <= width {
+		return segments
+	}
+
+	// There isn't room for the ellipsis, so just cut the text.
+	if width < len(ellipsis) {
+		if e == EllipsisStart {
+			return takeEnd(segments, width)
+		}
+		return takeStart(segments, width)
+	}
+
+	left := width - len(ellipsis)
+	switch e {
+	case EllipsisStart:
+		tail := takeEnd(segments, left)
+		if len(tail) == 0 {
+			return []Segment{{Text: ellipsis}}
+		}
+		tail[0].Text, tail[0].Drawing = ellipsis+tail[0].Text, ""
+		return tail
+	case EllipsisMiddle:
+		head := takeStart(segments, (left+1)/2)
+		tail := takeEnd(segments, left/2)
+		if len(head) == 0 {
+			head = []Segment{{}}
+		}
+		head[len(head)-1].Text += ellipsis
+		head[len(head)-1].Drawing = ""
+		return append(head, tail...)
+	}
+
+	head := takeStart(segments, left)
+	if len(head) == 0 {
+		return []Segment{{Text: ellipsis}}
+	}
+	head[len(head)-1].Text += ellipsis
+	head[len(head)-1].Drawing = ""
+	return head
+}
+
+// takeStart returns as many segments from the start as fit in width columns, shortening the last
+// one if needed.
+func takeStart(segments []Segment, width int) []Segment {
+	taken := make([]Segment, 0, len(segments))
+	for _, segment := range segments {
+		used, cut := 0, false
+		for i, r := range segment.Text {
+			if used+RuneWidth(r) > width {
+				segment.Text, segment.Drawing, cut = segment.Text[:i], "", true
+				break
+			}
+			used += RuneWidth(r)
+		}
+		if segment.Text != "" {
+			taken = append(taken, segment)
+		}
+		width -= used
+		if cut || width <= 0 {
+			break
+		}
+	}
+
+	return taken
+}
+
+// takeEnd returns as many segments from the end as fit in width columns, shortening the first one
+// if needed.
+func takeEnd(segments []Segment, width int) []Segment {
+	var taken []Segment
+	for i := len(segments) - 1; i >= 0 && width > 0; i-- {
+		segment := segments[i]
+		runes := []rune(segment.Text)
+		start := len(runes)
+		used := 0
+		for start > 0 && used+RuneWidth(runes[start-1]) <= width {
+			start--
+			used += RuneWidth(runes[start])
+		}
+
+		// Don't start with a combining mark that lost the character it belongs to.
+		for start < len(runes) && RuneWidth(runes[start]) == 0 {
+			start++
+		}
+
+		segment.Text = string(runes[start:])
+		if start > 0 {
+			segment.Drawing = ""
+		}
+		if segment.Text != "" {
+			taken = append([]Segment{segment}, taken...)
+		}
+		width -= used
+		if start > 0 {
+			break
+		}
+	}
+
+	return taken
+}
@@ -0,0 +1,75 @@
+// This file holds the marquee that scrolls long output through a window of a fixed width.
+
+package statusbar
+
+import (
+	"time"
+
+	"github.com/snhilde/statusbar/v5/markup"
+)
+
+// defaultMarqueeStep is how long the marquee waits before scrolling one column if it doesn't set its
+// own step.
+const defaultMarqueeStep = 250 * time.Millisecond
+
+// defaultMarqueeGap is the text between the end of the output and its start when the marquee loops
+// around.
+const defaultMarqueeGap = "   "
+
+// Marquee scrolls a routine's output through a window of a fixed width instead of cutting it short.
+// Output that fits in the window is shown as is. The output scrolls on its own between updates, so
+// routines don't need to update any more often than they already do.
+type Marquee struct {
+	// Width of the window, in columns (see WithWidth).
+	Width int
+
+	// Time between each step of one column. If this is 0, the output scrolls one column every 250
+	// milliseconds.
+	Step time.Duration
+
+	// Text shown between the end of the output and its start. If this is empty, three spaces are used.
+	Gap string
+}
+
+// WithMarquee scrolls the routine's output when it is wider than the marquee's window. This takes the
+// place of the routine's width limit (see WithWidth). A marquee with a width of 0 or less turns
+// scrolling off.
+func WithMarquee(m Marquee) RoutineOption {
+	return func(r *routine) {
+		r.setMarquee(m)
+	}
+}
+
+// scroll returns the part of the segments that the marquee shows at now.
+func (m Marquee) scroll(segments []markup.Segment, start time.Time, now time.Time) []markup.Segment {
+	offset := int(now.Sub(start) / m.Step)
+	return markup.Scroll(segments, m.Width, offset, m.Gap)
+}
+
+// scrolls returns whether or not the marquee needs to scroll to show all of the segments.
+func (m Marquee) scrolls(segments []markup.Segment) bool {
+	return m.Width > 0 && markup.Width(markup.Text(segments)) > m.Width
+}
+
+// nextScroll returns the next time that the output of any routine scrolls, or the zero time if no
+// routine is scrolling.
+func (sb *Statusbar) nextScroll() time.Time {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	var next time.Time
+	now := sb.now()
+	for _, r := range sb.routines {
+		m, start := r.marqueeSettings()
+		if !m.scrolls(r.output().segments) {
+			continue
+		}
+
+		step := now.Sub(start)/m.Step + 1
+		if t := start.Add(step * m.Step); next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+
+	return next
+}
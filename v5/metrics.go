@@ -0,0 +1,150 @@
+// This file serves the statistics of the statusbar and its routines in Prometheus's text format.
+
+package statusbar
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/snhilde/statusbar/v5/value"
+)
+
+// EnableMetrics serves the statistics of the statusbar and its routines, along with the values that
+// the routines report (see Valuer), in Prometheus's text format at /metrics. The metrics are served
+// on the REST API's port, so the REST API must also be enabled with EnableRESTAPI. Routines are
+// labeled with their module name ("routine") and their place on the bar ("index"), so that routines
+// of the same module are told apart. Values are labeled with their name ("name"), their unit
+// ("unit"), and their own labels, like the path of a disk (see value.Label).
+func (sb *Statusbar) EnableMetrics() {
+	sb.metrics = true
+}
+
+// metric describes a metric that is exported for every routine.
+type metric struct {
+	// Name of the metric.
+	name string
+
+	// Type of the metric: "gauge" or "counter".
+	kind string
+
+	// Description of the metric.
+	help string
+
+	// Function that reads the metric from a routine.
+	read func(r *routine) float64
+}
+
+// routineMetrics are the metrics that are exported for every routine, in order.
+var routineMetrics = []metric{
+	{"statusbar_routine_active", "gauge", "Whether or not the routine is running (1) or stopped (0).",
+		func(r *routine) float64 { return boolFloat(r.isActive()) }},
+	{"statusbar_routine_uptime_seconds", "gauge", "How long the routine has been running.",
+		func(r *routine) float64 { return float64(r.uptime()) }},
+	{"statusbar_routine_interval_seconds", "gauge", "Time between the routine's updates.",
+		func(r *routine) float64 { return r.intervalDuration().Seconds() }},
+	{"statusbar_routine_update_duration_seconds", "gauge", "How long the routine's latest update took.",
+		func(r *routine) float64 { _, _, d := r.updateStats(); return d.Seconds() }},
+	{"statusbar_routine_updates_total", "counter", "Number of updates that the routine has run.",
+		func(r *routine) float64 { n, _, _ := r.updateStats(); return float64(n) }},
+	{"statusbar_routine_errors_total", "counter", "Number of the routine's updates that failed.",
+		func(r *routine) float64 { _, n, _ := r.updateStats(); return float64(n) }},
+	{"statusbar_routine_failures", "gauge", "Number of the routine's updates in a row that have failed.",
+		func(r *routine) float64 { n, _ := r.retryState(); return float64(n) }},
+	{"statusbar_routine_restarts_total", "counter", "Number of times the routine has been restarted.",
+		func(r *routine) float64 { return float64(r.restartCount()) }},
+}
+
+// metricsHandler serves the metrics of a statusbar.
+type metricsHandler struct {
+	sb *Statusbar
+}
+
+// ServeHTTP writes the statusbar's metrics.
+func (h metricsHandler) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	h.sb.writeMetrics(w)
+}
+
+// writeMetrics writes the metrics of the statusbar and its routines to w in Prometheus's text format.
+func (sb *Statusbar) writeMetrics(w io.Writer) {
+	// The metrics of each routine need a unique set of labels, so routines of the same module are told
+	// apart by their place on the bar.
+	routines := sb.routineList()
+	labels := make([]string, len(routines))
+	for i, r := range routines {
+		labels[i] = fmt.Sprintf("routine=%s,index=\"%d\"", quoteLabel(r.moduleName()), i)
+	}
+
+	writeHeader(w, "statusbar_uptime_seconds", "gauge", "How long the statusbar has been running.")
+	fmt.Fprintf(w, "statusbar_uptime_seconds %d\n", sb.Uptime())
+
+	for _, m := range routineMetrics {
+		writeHeader(w, m.name, m.kind, m.help)
+		for i, r := range routines {
+			fmt.Fprintf(w, "%s{%s} %s\n", m.name, labels[i], formatSample(m.read(r)))
+		}
+	}
+
+	writeHeader(w, "statusbar_routine_value", "gauge", "Latest values reported by the routine. True is 1 and false is 0.")
+	for i, r := range routines {
+		for _, v := range r.output().values {
+			var n float64
+			switch v.Kind {
+			case value.Number:
+				n = v.Number
+			case value.Bool:
+				n = boolFloat(v.Bool)
+			default:
+				continue
+			}
+			fmt.Fprintf(w, "statusbar_routine_value{%s,name=%s,unit=%s%s} %s\n",
+				labels[i], quoteLabel(v.Name), quoteLabel(v.Unit), valueLabels(v), formatSample(n))
+		}
+	}
+}
+
+// reservedLabels are the labels that the metrics set themselves.
+var reservedLabels = map[string]bool{"routine": true, "index": true, "name": true, "unit": true}
+
+// valueLabels formats the labels of a value, each with a leading comma. Labels with reserved or
+// invalid names are left out.
+func valueLabels(v value.Value) string {
+	var b strings.Builder
+	for _, label := range v.Labels {
+		if !reservedLabels[label.Name] && isName(label.Name) {
+			b.WriteString("," + label.Name + "=" + quoteLabel(label.Value))
+		}
+	}
+
+	return b.String()
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric.
+func writeHeader(w io.Writer, name string, kind string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// labelEscaper escapes the characters that can't appear as-is in a label's value.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel returns the label's value in quotes.
+func quoteLabel(s string) string {
+	return `"` + labelEscaper.Replace(s) + `"`
+}
+
+// formatSample formats a metric's value.
+func formatSample(n float64) string {
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+// boolFloat returns 1 for true and 0 for false.
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,137 @@
+// This file holds the alert notifiers that run a command or call a webhook.
+
+package statusbar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+// notifyTimeout is the longest time that a notifier can take to send an alert.
+const notifyTimeout = 10 * time.Second
+
+// execNotifier runs a command like notify-send for every raised alert.
+type execNotifier struct {
+	command string
+}
+
+// NewExecNotifier returns an AlertNotifier that shows raised alerts as desktop notifications by
+// running command, which is "notify-send" if command is empty. The command is run with notify-send's
+// arguments: "-a statusbar -u <urgency> <summary> <message>", where the urgency is "critical" for
+// critical alerts and "normal" for the rest. Cleared alerts aren't shown.
+func NewExecNotifier(command string) AlertNotifier {
+	if command == "" {
+		command = "notify-send"
+	}
+
+	return &execNotifier{command: command}
+}
+
+// Notify runs the command for a raised alert.
+func (n *execNotifier) Notify(alert Alert) error {
+	if n == nil {
+		return fmt.Errorf("invalid notifier")
+	}
+	if !alert.Cleared.IsZero() {
+		return nil
+	}
+
+	urgency := "normal"
+	if alert.Critical {
+		urgency = "critical"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, n.command, "-a", "statusbar", "-u", urgency, alert.Summary, alert.Message)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", n.command, err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
+
+// webhookNotifier posts every raised and cleared alert to a URL.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// webhookAlert is the JSON body that is posted to a webhook.
+type webhookAlert struct {
+	// "firing" for a raised alert, or "resolved" for a cleared alert.
+	Status string `json:"status"`
+
+	// Module name of the routine that raised the alert.
+	Routine string `json:"routine"`
+
+	// Name of the rule that raised the alert.
+	Name string `json:"name"`
+
+	// Short description of the alert.
+	Summary string `json:"summary"`
+
+	// Text of the alert.
+	Message string `json:"message"`
+
+	// Whether or not the alert is critical.
+	Critical bool `json:"critical"`
+
+	// Time that the rule's condition became true, in RFC 3339 format.
+	Since string `json:"since"`
+
+	// Time that the alert was cleared, in RFC 3339 format. This is empty while the alert is raised.
+	Cleared string `json:"cleared,omitempty"`
+}
+
+// NewWebhookNotifier returns an AlertNotifier that posts every raised and cleared alert to url as a
+// JSON object, like {"status": "firing", "routine": "sbbattery", "name": "low", ...}. The status is
+// "firing" when the alert is raised and "resolved" when it is cleared. Responses other than 2xx are
+// reported as errors.
+func NewWebhookNotifier(url string) AlertNotifier {
+	return &webhookNotifier{url: url, client: &http.Client{Timeout: notifyTimeout}}
+}
+
+// Notify posts the alert to the webhook.
+func (n *webhookNotifier) Notify(alert Alert) error {
+	if n == nil || n.url == "" {
+		return fmt.Errorf("invalid notifier")
+	}
+
+	body := webhookAlert{
+		Status:   "firing",
+		Routine:  alert.Routine,
+		Name:     alert.Name,
+		Summary:  alert.Summary,
+		Message:  alert.Message,
+		Critical: alert.Critical,
+		Since:    alert.Since.Format(time.RFC3339),
+	}
+	if !alert.Cleared.IsZero() {
+		body.Status = "resolved"
+		body.Cleared = alert.Cleared.Format(time.RFC3339)
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
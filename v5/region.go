@@ -0,0 +1,147 @@
+// This file holds the named regions that the routines of the bar can be divided into.
+
+package statusbar
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snhilde/statusbar/v5/markup"
+)
+
+// Region is a named part of the bar with its own layout. Routines are placed in a region with
+// WithRegion, and each region is rendered on its own before the regions are combined by the
+// statusbar's markup (see markup.JoinRegions).
+type Region struct {
+	// Name of the region, as passed to WithRegion.
+	Name string
+
+	// Text placed between the output of each routine in the region. If this is empty, a single space
+	// is used.
+	Separator string
+
+	// Left and right delimiters around each routine in the region. If this is nil, the statusbar's
+	// markers are used (see SetMarkers). Otherwise, it must have exactly two elements.
+	Markers []string
+
+	// Where the region is placed on the bar, for markups that can align regions.
+	Align markup.Align
+}
+
+// WithRegion places the routine in the region with this name (see AddRegion). Routines that aren't
+// placed in a region, or that are placed in a region that doesn't exist, are displayed in the first
+// region.
+func WithRegion(name string) RoutineOption {
+	return func(r *routine) {
+		r.setRegion(name)
+	}
+}
+
+// AddRegion adds a region to the bar. Regions are displayed in the order that they are added. Once
+// any region is added, the split set with Split or SetSplit is no longer used.
+func (sb *Statusbar) AddRegion(region Region) error {
+	if sb == nil {
+		return fmt.Errorf("invalid statusbar")
+	}
+
+	if region.Name == "" {
+		return fmt.Errorf("missing region name")
+	}
+	if region.Markers != nil && len(region.Markers) != 2 {
+		return fmt.Errorf("region %s: expected 2 markers, got %d", region.Name, len(region.Markers))
+	}
+	if region.Separator == "" {
+		region.Separator = " "
+	}
+
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	for _, existing := range sb.regions {
+		if existing.Name == region.Name {
+			return fmt.Errorf("region %s already exists", region.Name)
+		}
+	}
+	sb.regions = append(sb.regions, region)
+	sb.requestRedraw()
+
+	return nil
+}
+
+// Regions returns the regions of the bar, in the order that they are displayed.
+func (sb *Statusbar) Regions() []Region {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	return append([]Region(nil), sb.regions...)
+}
+
+// hasRegion returns whether or not a region with this name was added.
+func (sb *Statusbar) hasRegion(name string) bool {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	for _, region := range sb.regions {
+		if region.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// layout divides the routines into the regions that they are displayed in. If no regions were added,
+// then the routines are displayed in one region, or in two if the bar is split. The statusbar's mutex
+// must be held.
+func (sb *Statusbar) layout() ([]Region, [][]*routine) {
+	if len(sb.regions) == 0 {
+		main := Region{Separator: " "}
+		if sb.split < 0 {
+			return []Region{main}, [][]*routine{sb.routines}
+		}
+		return []Region{main, main}, [][]*routine{sb.routines[:sb.split+1], sb.routines[sb.split+1:]}
+	}
+
+	indexes := make(map[string]int, len(sb.regions))
+	for i, region := range sb.regions {
+		indexes[region.Name] = i
+	}
+
+	groups := make([][]*routine, len(sb.regions))
+	for _, r := range sb.routines {
+		i := indexes[r.regionName()] // Unknown regions fall back to the first one.
+		groups[i] = append(groups[i], r)
+	}
+
+	return sb.regions, groups
+}
+
+// renderRegion renders the output of each routine in the region with the region's markers and
+// separator. It also returns the output of each routine as a separate block. The statusbar's mutex
+// must be held.
+func (sb *Statusbar) renderRegion(region Region, routines []*routine) (markup.Region, []Block) {
+	left, right := sb.leftDelim, sb.rightDelim
+	if region.Markers != nil {
+		left, right = region.Markers[0], region.Markers[1]
+	}
+
+	var parts []string
+	var blocks []Block
+	for _, r := range routines {
+		text, block, ok := sb.renderRoutine(r)
+		if !ok {
+			continue
+		}
+		block.Region = region.Name
+		blocks = append(blocks, block)
+		parts = append(parts, sb.statusCmdMarker(r)+left+text+right)
+	}
+
+	rendered := markup.Region{
+		Name:  region.Name,
+		Align: region.Align,
+		Text:  strings.Join(parts, region.Separator),
+	}
+
+	return rendered, blocks
+}
@@ -0,0 +1,290 @@
+// This file holds the decoder that fills in a module's options.
+
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OptionError is a problem with one of a module's options.
+type OptionError struct {
+	// Path of the option, like "format", "graph.width", or "paths[1]". This is empty if the problem
+	// is with the options as a whole.
+	Key string
+
+	// Description of the problem.
+	Msg string
+}
+
+// Error returns the problem and the option that it is with.
+func (e *OptionError) Error() string {
+	if e.Key == "" {
+		return e.Msg
+	}
+
+	return e.Key + ": " + e.Msg
+}
+
+// DecodeOptions fills in options, which is the value returned by a module's Options, from a JSON
+// object, with the same rules as FillOptions. Empty data leaves the defaults as they are, but still
+// checks for required options.
+func DecodeOptions(data []byte, options interface{}) error {
+	values := make(map[string]interface{})
+	if len(bytes.TrimSpace(data)) > 0 {
+		var v interface{}
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return &OptionError{Msg: err.Error()}
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return typeError("", v, "table")
+		}
+		values = m
+	}
+
+	return FillOptions(options, values)
+}
+
+// FillOptions fills in options, which is the value returned by a module's Options, from values.
+// Each key is matched to a field with the same name in the field's "config" struct tag (see
+// Module), and keys without a matching field are an error, as are missing required options. Values
+// are strings, booleans, numbers (int64, float64, or json.Number), slices of values, and maps from
+// strings to values, as decoded from a configuration file or a JSON object. Durations are either a
+// string like "1m30s" or a number of seconds.
+func FillOptions(options interface{}, values map[string]interface{}) error {
+	v := reflect.ValueOf(options)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return &OptionError{Msg: fmt.Sprintf("options must be a pointer to a struct, not %T", options)}
+	}
+
+	return fillStruct(values, v.Elem(), "")
+}
+
+// durationType is the type of options that are filled in as durations.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fill fills in v from value. key is the path of the option, used in errors.
+func fill(value interface{}, v reflect.Value, key string) error {
+	if v.Type() == durationType {
+		return fillDuration(value, v, key)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return typeError(key, value, "string")
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return typeError(key, value, "boolean")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := toInt(value)
+		if !ok {
+			return typeError(key, value, "integer")
+		}
+		if v.OverflowInt(i) {
+			return &OptionError{Key: key, Msg: fmt.Sprintf("%d is out of range", i)}
+		}
+		v.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat(value)
+		if !ok {
+			return typeError(key, value, "number")
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return typeError(key, value, "list")
+		}
+		list := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := fill(item, list.Index(i), fmt.Sprintf("%s[%d]", key, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(list)
+	case reflect.Struct:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(key, value, "table")
+		}
+		return fillStruct(m, v, key)
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return fill(value, v.Elem(), key)
+	default:
+		return &OptionError{Key: key, Msg: fmt.Sprintf("unsupported type %s", v.Type())}
+	}
+
+	return nil
+}
+
+// fillStruct fills in the fields of the struct v from values.
+func fillStruct(values map[string]interface{}, v reflect.Value, key string) error {
+	// Build a list of the keys that this struct accepts.
+	t := v.Type()
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		if name, _ := parseTag(t.Field(i)); name != "" {
+			fields[name] = i
+		}
+	}
+
+	// Make sure every key is valid, and fill in the ones that are. The keys are sorted so that the
+	// same mistake always gets the same error.
+	for _, name := range sortedKeys(values) {
+		i, ok := fields[name]
+		if !ok {
+			return &OptionError{Key: joinKey(key, name), Msg: "unknown key"}
+		}
+		if err := fill(values[name], v.Field(i), joinKey(key, name)); err != nil {
+			return err
+		}
+	}
+
+	// Make sure every required key was set.
+	for i := 0; i < t.NumField(); i++ {
+		name, required := parseTag(t.Field(i))
+		if _, ok := values[name]; required && !ok {
+			return &OptionError{Key: joinKey(key, name), Msg: "missing required key"}
+		}
+	}
+
+	return nil
+}
+
+// fillDuration fills in a duration from either a string like "1m30s" or a number of seconds.
+func fillDuration(value interface{}, v reflect.Value, key string) error {
+	if s, ok := value.(string); ok {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return &OptionError{Key: key, Msg: fmt.Sprintf("invalid duration %q", s)}
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	seconds, ok := toInt(value)
+	if !ok {
+		return typeError(key, value, "duration")
+	}
+	v.SetInt(int64(time.Duration(seconds) * time.Second))
+
+	return nil
+}
+
+// toInt returns value as an integer, if it is one.
+func toInt(value interface{}) (int64, bool) {
+	switch n := value.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	}
+
+	return 0, false
+}
+
+// toFloat returns value as a float, if it is a number.
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, !math.IsNaN(n)
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// parseTag returns the key name of a struct field and whether or not the key is required.
+func parseTag(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("config")
+	if tag == "" || tag == "-" || field.PkgPath != "" {
+		return "", false
+	}
+
+	parts := strings.Split(tag, ",")
+	required := false
+	for _, option := range parts[1:] {
+		if option == "required" {
+			required = true
+		}
+	}
+
+	return parts[0], required
+}
+
+// sortedKeys returns the keys of values in alphabetical order.
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// joinKey adds name to the end of the key path.
+func joinKey(key string, name string) string {
+	if key == "" {
+		return name
+	}
+
+	return key + "." + name
+}
+
+// typeError returns an error for a value that is the wrong type.
+func typeError(key string, value interface{}, want string) error {
+	return &OptionError{Key: key, Msg: fmt.Sprintf("expected %s, found %s", want, describe(value))}
+}
+
+// describe returns a description of the value's type, for error messages.
+func describe(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case int64, int:
+		return "integer"
+	case float64:
+		return "float"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "table"
+	case nil:
+		return "null"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,63 @@
+package registry_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/snhilde/statusbar/v5/registry"
+)
+
+type testOptions struct {
+	Path    string        `config:"path,required"`
+	Count   int           `config:"count"`
+	Ratio   float32       `config:"ratio"`
+	Paths   []string      `config:"paths"`
+	Every   time.Duration `config:"every"`
+	Width   *int          `config:"width"`
+	Nested  nestedOptions `config:"nested"`
+	Ignored string
+}
+
+type nestedOptions struct {
+	Style string `config:"style"`
+}
+
+func TestDecodeOptions(t *testing.T) {
+	t.Parallel()
+
+	o := &testOptions{Count: 3}
+	data := `{"path": "/tmp", "ratio": 1, "paths": ["/", "/home"], "every": "1m", "width": 0, "nested": {"style": "gauge"}}`
+	if err := registry.DecodeOptions([]byte(data), o); err != nil {
+		t.Fatal(err)
+	}
+	zero := 0
+	want := &testOptions{Path: "/tmp", Count: 3, Ratio: 1, Paths: []string{"/", "/home"}, Every: time.Minute, Width: &zero,
+		Nested: nestedOptions{Style: "gauge"}}
+	if !reflect.DeepEqual(o, want) {
+		t.Errorf("Expected %+v, got %+v", want, o)
+	}
+
+	tests := []struct {
+		data string
+		key  string
+	}{
+		{``, "path"},
+		{`{"path": "/", "colour": "red"}`, "colour"},
+		{`{"path": 5}`, "path"},
+		{`{"path": "/", "count": 1.5}`, "count"},
+		{`{"path": "/", "paths": ["/", 5]}`, "paths[1]"},
+		{`{"path": "/", "every": "soon"}`, "every"},
+		{`{"path": "/", "nested": {"size": 1}}`, "nested.size"},
+		{`{"path": "/", "Ignored": "x"}`, "Ignored"},
+		{`["/"]`, ""},
+	}
+	for i, test := range tests {
+		err := registry.DecodeOptions([]byte(test.data), new(testOptions))
+		var e *registry.OptionError
+		if !errors.As(err, &e) || e.Key != test.key {
+			t.Errorf("Test %d: expected an error for %q, got %v", i, test.key, err)
+		}
+	}
+}
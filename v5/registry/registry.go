@@ -0,0 +1,87 @@
+// Package registry holds the modules that can be created by name, such as from a configuration file.
+//
+// Each module registers itself from its init function with a Module, which describes the module's
+// options and how to build a routine from them. A program only needs to import a module for it to
+// be available in the registry.
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+// Routine is the set of methods implemented by every module's routine. This matches the
+// statusbar's RoutineHandler interface, so any Routine can be added to a statusbar.
+type Routine interface {
+	Update() (bool, error)
+	String() string
+	Error() string
+	Name() string
+}
+
+// Module describes how to create a module's routine.
+type Module struct {
+	// Name of the module, as used in configuration files. This is the name of the module's package,
+	// like "sbtime".
+	Name string
+
+	// Options returns a pointer to a new struct for the module's options, filled in with the
+	// default values. Fields are matched to configuration keys with the "config" struct tag, like
+	// `config:"format"`. Add ",required" to the tag for options that must be set. This is nil if the
+	// module doesn't have any options.
+	Options func() interface{}
+
+	// New creates a new routine. options is the value returned by Options, after it has been filled
+	// in from the configuration, or nil if the module doesn't have any options. t is the routine's
+	// theme.
+	New func(options interface{}, t theme.Theme) (Routine, error)
+}
+
+var (
+	// Modules that have been registered, keyed by name.
+	modules      = make(map[string]Module)
+	modulesMutex sync.RWMutex
+)
+
+// Register adds m to the registry. This is meant to be called from the module's init function. It
+// panics if m is missing its name or constructor or if a module with the same name was already
+// registered.
+func Register(m Module) {
+	if m.Name == "" || m.New == nil {
+		panic("registry: invalid module")
+	}
+
+	modulesMutex.Lock()
+	defer modulesMutex.Unlock()
+
+	if _, ok := modules[m.Name]; ok {
+		panic(fmt.Sprintf("registry: module %s registered twice", m.Name))
+	}
+	modules[m.Name] = m
+}
+
+// Lookup returns the module registered under name.
+func Lookup(name string) (Module, bool) {
+	modulesMutex.RLock()
+	defer modulesMutex.RUnlock()
+
+	m, ok := modules[name]
+	return m, ok
+}
+
+// Names returns the names of all registered modules, sorted alphabetically.
+func Names() []string {
+	modulesMutex.RLock()
+	defer modulesMutex.RUnlock()
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
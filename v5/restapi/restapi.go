@@ -119,6 +119,25 @@ func NewEngine() *Engine {
 	return e
 }
 
+// NewEngineWithLog creates a new Engine like NewEngine does, but with Gin's request log written to w
+// instead of stdout. If w is nil, requests aren't logged, and Gin is switched to release mode so that
+// it doesn't print its debug messages either. Note that Gin's mode is shared by every Gin engine in
+// the program.
+func NewEngineWithLog(w io.Writer) *Engine {
+	if w == nil {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	e := new(Engine)
+	e.engine = gin.New()
+	if w != nil {
+		e.engine.Use(gin.LoggerWithWriter(w))
+	}
+	e.engine.Use(gin.Recovery())
+
+	return e
+}
+
 // AddSpec adds the enpoints in the specification to Engine's routes.
 func (e *Engine) AddSpec(spec RestSpec, handler interface{}) error {
 	if e == nil || e.engine == nil {
@@ -173,6 +192,21 @@ func (e *Engine) AddSpecReader(r io.Reader, handler interface{}) error {
 	return e.AddSpec(spec, handler)
 }
 
+// Handle adds a route that is handled by handler directly instead of through a specification, such
+// as for an endpoint whose response isn't JSON. path is not under any specification's prefix.
+func (e *Engine) Handle(method string, path string, handler http.Handler) error {
+	if e == nil || e.engine == nil {
+		return fmt.Errorf("invalid Engine")
+	}
+	if handler == nil {
+		return fmt.Errorf("missing handler for %s", path)
+	}
+
+	e.engine.Handle(method, path, gin.WrapH(handler))
+
+	return nil
+}
+
 // Run runs the API engine in a new goroutine and listens on the designated port.
 func (e *Engine) Run(port int) {
 	if e != nil && e.engine != nil && e.server == nil {
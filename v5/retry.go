@@ -0,0 +1,90 @@
+// This file holds the retry policies that decide how long a routine waits after a failed update.
+
+package statusbar
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how long a routine waits before it tries again after a failed update. The wait grows
+// exponentially with every consecutive failure, up to a limit, and goes back to the routine's normal interval as soon
+// as an update succeeds. Updates that time out count as failures. Critical errors (see RoutineHandler) always stop the
+// routine right away, regardless of the policy.
+type RetryPolicy struct {
+	// Time to wait after the first failure.
+	Initial time.Duration
+
+	// Longest time to wait between retries. If this is 0, the wait can grow without a limit.
+	Max time.Duration
+
+	// Factor that the wait is multiplied by after each consecutive failure. If this is less than 1, the wait doesn't
+	// grow. The default policy uses 2.
+	Multiplier float64
+
+	// Fraction of the wait (between 0 and 1) that is randomly added or removed, so that routines that fail at the same
+	// time don't all retry at the same time.
+	Jitter float64
+
+	// Number of consecutive failures after which the routine gives up and stops. If this is 0, the routine keeps
+	// trying forever.
+	MaxFailures int
+}
+
+// WithRetryPolicy sets how the routine backs off after failed updates. If this isn't used, the routine gets a default
+// policy based on its interval: routines that run at least every minute start at 5 seconds and back off to 1 minute,
+// routines that run at least every 15 minutes start at 1 minute and back off to 15 minutes, and all other routines
+// start at 5 minutes and back off to 1 hour.
+func WithRetryPolicy(policy RetryPolicy) RoutineOption {
+	return func(r *routine) {
+		r.setRetryPolicy(&policy)
+	}
+}
+
+// defaultRetryPolicy returns the retry policy for a routine that runs every interval and doesn't have its own policy.
+func defaultRetryPolicy(interval time.Duration) RetryPolicy {
+	policy := RetryPolicy{Multiplier: 2, Jitter: 0.1}
+	switch {
+	case interval < time.Minute:
+		policy.Initial, policy.Max = 5*time.Second, time.Minute
+	case interval < 15*time.Minute:
+		policy.Initial, policy.Max = time.Minute, 15*time.Minute
+	default:
+		policy.Initial, policy.Max = 5*time.Minute, time.Hour
+	}
+
+	return policy
+}
+
+var (
+	// Source of randomness for jitter, seeded once per program.
+	jitterRand  = rand.New(rand.NewSource(time.Now().UnixNano()))
+	jitterMutex sync.Mutex
+)
+
+// backoff returns how long to wait after the specified number of consecutive failures (starting at 1).
+func (p RetryPolicy) backoff(failures int) time.Duration {
+	wait := float64(p.Initial)
+	if p.Multiplier > 1 && failures > 1 {
+		wait *= math.Pow(p.Multiplier, float64(failures-1))
+	}
+	if p.Max > 0 && wait > float64(p.Max) {
+		wait = float64(p.Max)
+	}
+
+	if p.Jitter > 0 {
+		jitterMutex.Lock()
+		r := jitterRand.Float64()
+		jitterMutex.Unlock()
+		wait += wait * math.Min(p.Jitter, 1) * (2*r - 1)
+	}
+
+	return time.Duration(wait)
+}
+
+// givesUp returns true if the routine should stop after the specified number of consecutive failures.
+func (p RetryPolicy) givesUp(failures int) bool {
+	return p.MaxFailures > 0 && failures >= p.MaxFailures
+}
@@ -3,10 +3,46 @@
 package statusbar
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
 )
 
+// defaultTimeout is the longest that a single update is allowed to run, unless the routine was added with WithTimeout.
+const defaultTimeout = 30 * time.Second
+
+// maxQueuedClicks is the most clicks that can wait for a routine to handle them.
+const maxQueuedClicks = 8
+
+// DefaultWidth is the most columns of a routine's output that are displayed if the routine doesn't set its own width
+// (see WithWidth).
+const DefaultWidth = 60
+
+// output holds the latest output of a routine.
+type output struct {
+	// Formatted output, either from String or Error. This is empty if the routine returned segments.
+	text string
+
+	// Output broken into segments. For routines that don't implement Segmenter, this is parsed from text.
+	segments []markup.Segment
+
+	// Whether or not the output is an error message.
+	failed bool
+
+	// Values reported by routines that implement Valuer. This is empty if the update failed.
+	values []value.Value
+}
+
+// lastID is the last ID given to a routine.
+var lastID int64
+
 // routine holds the data for an individual unit on the statusbar.
 type routine struct {
 	// Routine object that handles running the actual process
@@ -15,12 +51,95 @@ type routine struct {
 	// Name of routine
 	name string
 
+	// Identifier that is unique to this routine, even across reloads.
+	id int64
+
+	// Identifies the routine's settings across reloads, as set with WithFingerprint.
+	fingerprint string
+
+	// Guards the output, interval, timeout, retry and restart state, update counts, and active flag, which are read and
+	// written from different goroutines.
+	mutex sync.Mutex
+
+	// Latest output of the routine.
+	out output
+
+	// Function that is called whenever the routine's output changes. The statusbar uses this to redraw the bar.
+	changed func()
+
 	// Whether or not the routine is currently active and up.
 	active bool
 
 	// Time in seconds to wait between each run
 	intervalTime time.Duration
 
+	// Maximum time that a single update can take. If this is 0, updates can run indefinitely.
+	timeout time.Duration
+
+	// How to back off after failed updates, as set with WithRetryPolicy. If this is nil, a default policy based on the
+	// interval is used.
+	retry *RetryPolicy
+
+	// Number of updates in a row that have failed.
+	failures int
+
+	// Time of the next retry after a failed update. This is zero if the last update succeeded.
+	nextRetry time.Time
+
+	// Whether or not the routine is started again after it stops, as set with WithRestartPolicy.
+	restart RestartPolicy
+
+	// Time to wait before restarting the routine.
+	restartDelay time.Duration
+
+	// Number of times the routine has been restarted.
+	restarts int
+
+	// Number of updates that have run and how many of them failed, and how long the latest update took.
+	updates  int
+	errors   int
+	duration time.Duration
+
+	// Why the routine stopped the last time it stopped.
+	exit exitReason
+
+	// Most columns that the routine's output can take up on the bar, as set with WithWidth. If this is 0, there is no
+	// limit.
+	width int
+
+	// Where to put the ellipsis when the output is shortened.
+	ellipsis markup.Ellipsis
+
+	// Name of the region that the routine is displayed in, as set with WithRegion.
+	region string
+
+	// Rules that decide whether or not the routine is shown, as set with WithVisibility.
+	visibility []*visibilityRule
+
+	// Rules that raise alerts, as set with WithAlerts, and the function that sends the alerts.
+	alerts    []*alertRule
+	sendAlert func(Alert)
+
+	// Name of the slot that the routine takes turns in, as set with WithSlot.
+	slot string
+
+	// Marquee that scrolls the routine's output, as set with WithMarquee, and the time that it started
+	// scrolling.
+	marquee      Marquee
+	marqueeStart time.Time
+
+	// Numeric values that the routine reported in the past, as set with WithHistory.
+	history history
+
+	// Most recent messages that were logged about the routine.
+	logs logRing
+
+	// Channel that receives the result of an update that timed out but hasn't returned yet.
+	pending chan updateResult
+
+	// Clock that the routine reads the time from. This is the clock of the statusbar that the routine was added to.
+	clock Clock
+
 	// Timer that is started when the routine is started. This is used to measure the routine's uptime.
 	startTime time.Time
 
@@ -29,6 +148,37 @@ type routine struct {
 
 	// Channel to use for signaling stop
 	stopChan chan struct{}
+
+	// Channel that is closed when the routine's run loop returns. This is nil until the routine is launched.
+	exited chan struct{}
+
+	// Channel of mouse buttons that clicked the routine's output. Clicks are handled by the routine's own goroutine
+	// between updates so that Click never runs at the same time as an update.
+	clickChan chan int
+
+	// Channel of themes for the handler to inherit while the routine is running. Like clicks, these are passed to the
+	// handler by the routine's own goroutine between updates.
+	themeChan chan theme.Theme
+}
+
+// exitReason is the reason that a routine stopped running.
+type exitReason int
+
+const (
+	// The routine was stopped on purpose, or it hasn't stopped.
+	exitStopped exitReason = iota
+
+	// The routine was set to run only once, and it did so successfully.
+	exitDone
+
+	// The routine reported a critical error or failed too many times in a row.
+	exitFailed
+)
+
+// updateResult holds the values returned by a routine's update method.
+type updateResult struct {
+	ok  bool
+	err error
 }
 
 // newRoutine returns a new routine object that is handled by handler.
@@ -38,85 +188,274 @@ func newRoutine() *routine {
 	// Set up the update and stop channels. We'll use a buffer size of 1 so the engine doesn't block sending on them.
 	r.updateChan = make(chan struct{}, 1)
 	r.stopChan = make(chan struct{}, 1)
+	r.clickChan = make(chan int, maxQueuedClicks)
+	r.themeChan = make(chan theme.Theme, 1)
+
+	r.timeout = defaultTimeout
+	r.width = DefaultWidth
+	r.id = atomic.AddInt64(&lastID, 1)
 
 	return r
 }
 
-// run runs a routine in a non-terminating loop. The routine's output is stored in index in the string slice received
-// from outputsChan. If the routine does stop, it sends itself back on finished so the caller is aware.
-func (r *routine) run(index int, outputsChan chan []string, finished chan<- *routine) {
+// run runs a routine in a non-terminating loop. The routine's latest output is kept in the routine (see output). If the
+// routine does stop, it sends itself back on finished so the caller is aware.
+func (r *routine) run(finished chan<- *routine) {
 	if r == nil {
 		return
 	}
 
-	// Start the uptime clock.
-	r.startTime = time.Now()
+	// Start the uptime clock, and start over with the retry policy in case this is a restart.
+	r.startTime = r.now()
 	r.setActive(true)
+	r.succeed()
 
+	// Unless we find out otherwise, the routine was stopped on purpose.
+	exit := exitStopped
 	for r.isActive() {
-		// Start the clock.
+		// Start the clock, and update the routine's data.
 		start := time.Now()
-
-		// Update the routine's data.
-		ok, err := r.handler.Update()
-
-		// Get the routine's output and store it in the master output slice.
-		var output string
-		if err == nil {
-			output = r.handler.String()
-		} else {
-			output = r.handler.Error()
-			log.Printf("%v: %v", r.handler.Name(), err.Error())
+		result, stopped := r.step()
+		if stopped {
+			break
 		}
-		outputs := <-outputsChan
-		outputs[index] = output
-		outputsChan <- outputs
+		ok, err := result.ok, result.err
 
 		// If the routine reported a critical error, then we'll break out of the loop now.
 		if !ok {
+			exit = exitFailed
 			break
 		}
 
 		// If the interval was set to only run once, then we can close the routine now.
-		if r.intervalTime == 0 {
+		interval := r.intervalDuration()
+		if interval == 0 {
+			exit = exitDone
+			if err != nil {
+				exit = exitFailed
+			}
 			break
 		}
 
-		interval := r.intervalTime
-		// If the routine reported an error, then we'll give the process a little time to cool down before trying again.
+		// If the routine reported an error, then we'll back off according to the retry policy before trying again.
+		wait := interval - time.Since(start)
 		if err != nil {
-			seconds := r.intervalTime / time.Second
-			switch {
-			// For routines with intervals up to 1 minute, sleep for 5 seconds.
-			case seconds < 60:
-				interval = 5 * time.Second
-			// For routines with intervals up to 15 minutes, sleep for 1 minute.
-			case seconds < 60*15:
-				interval = 60 * time.Second
-			// For routines with intervals longer than 15 minutes, sleep for 5 minutes.
-			default:
-				interval = 60 * 5 * time.Second
+			var giveUp bool
+			if wait, giveUp = r.fail(); giveUp {
+				failures, _ := r.retryState()
+				r.log(LevelError, "Giving up after too many failures in a row", "failures", failures)
+				exit = exitFailed
+				break
 			}
+		} else {
+			r.succeed()
 		}
 
 		// Wait until either a signal is received from the engine or the time elapses for another update to run.
 		select {
 		case <-r.updateChan:
 			// Update now.
+		case button := <-r.clickChan:
+			// Handle the click, and then update now so the result shows up right away.
+			if r.runClick(button) {
+				r.setActive(false)
+			}
+		case parent := <-r.themeChan:
+			// Pass the theme down, and then update now so the new colors show up right away.
+			if r.runInheritTheme(parent) {
+				r.setActive(false)
+			}
 		case <-r.stopChan:
 			// Stop the routine.
 			r.setActive(false)
-		case <-time.After(interval - time.Since(start)):
+		case <-time.After(wait):
 			// Time elapsed. Run another update loop.
 		}
 	}
 
 	r.setActive(false)
+	r.setExit(exit)
+	if r.exited != nil {
+		close(r.exited)
+	}
 
 	// Send on the finished channel to signify that we're stopping this routine.
 	finished <- r
 }
 
+// step runs one update of the routine, stores its output, adds its values to its history, and checks its alerts. It
+// returns the values returned by the handler and whether or not the routine was stopped while waiting for the update.
+func (r *routine) step() (updateResult, bool) {
+	start := time.Now()
+	result, stopped := r.runUpdate()
+	if stopped {
+		return result, true
+	}
+	r.countUpdate(time.Since(start), result.err)
+
+	out := r.collectOutput(result.err)
+	r.setOutput(out)
+	r.checkAlerts(out)
+
+	return result, false
+}
+
+// collectOutput gets the routine's output after an update that returned err. If the update timed out, then it's still
+// running in the background, and we can't safely ask the handler for its output.
+func (r *routine) collectOutput(err error) output {
+	out := output{failed: err != nil}
+	switch {
+	case err == nil:
+		// Record the values before asking for the output, so that routines that draw their history
+		// (see Historian) draw the values from this update too.
+		if valuer, ok := r.handler.(Valuer); ok {
+			out.values = valuer.Values()
+		}
+		r.record(out.values)
+		if segmenter, ok := r.handler.(Segmenter); ok {
+			out.segments = segmenter.Segments()
+		} else {
+			out.text = r.handler.String()
+			out.segments = markup.ParseStatus2d(out.text)
+		}
+	case r.pending != nil:
+		out.segments = []markup.Segment{{Text: "timed out", State: markup.StateError}}
+		r.log(LevelWarn, "Update failed", "error", err)
+	default:
+		out.text = r.handler.Error()
+		out.segments = markup.ParseStatus2d(out.text)
+		r.log(LevelWarn, "Update failed", "error", err)
+	}
+
+	return out
+}
+
+// runUpdate runs the handler's update method in a separate goroutine and waits for it to finish, for the routine's
+// timeout to expire, or for the routine to be stopped, whichever comes first. If the handler implements
+// ContextUpdater, the context passed to it is cancelled in the latter two cases. runUpdate returns the values returned
+// by the handler and whether or not the routine was stopped while waiting.
+func (r *routine) runUpdate() (updateResult, bool) {
+	if r.waitPending() {
+		return updateResult{}, true
+	}
+
+	timeout := r.timeoutDuration()
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	}
+	defer cancel()
+
+	done := make(chan updateResult, 1)
+	go func() {
+		var result updateResult
+		if updater, ok := r.handler.(ContextUpdater); ok {
+			result.ok, result.err = updater.UpdateContext(ctx)
+		} else {
+			result.ok, result.err = r.handler.Update()
+		}
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		return result, false
+	case <-ctx.Done():
+		// The update might have finished right as the timer expired.
+		select {
+		case result := <-done:
+			return result, false
+		default:
+		}
+		r.pending = done
+		return updateResult{ok: true, err: fmt.Errorf("update timed out after %v", timeout)}, false
+	case <-r.stopChan:
+		// Don't wait for the update to finish. Cancelling the context will tell it to stop. If the routine is started
+		// again, it will wait for this update to return before running another one.
+		r.pending = done
+		return updateResult{}, true
+	}
+}
+
+// waitPending waits for an update that timed out to return, so that the handler is never called while it is still
+// updating. It returns true if the routine was stopped while waiting.
+func (r *routine) waitPending() bool {
+	if r.pending != nil {
+		select {
+		case <-r.pending:
+			r.pending = nil
+		case <-r.stopChan:
+			return true
+		}
+	}
+
+	return false
+}
+
+// runClick passes a click to the handler once any update that is still running has returned. It returns true if the
+// routine was stopped while waiting.
+func (r *routine) runClick(button int) bool {
+	if r.waitPending() {
+		return true
+	}
+
+	if clicker, ok := r.handler.(Clicker); ok {
+		if err := clicker.Click(button); err != nil {
+			r.log(LevelWarn, "Click failed", "button", button, "error", err)
+		}
+	}
+
+	return false
+}
+
+// runInheritTheme passes a theme down to the handler once any update that is still running has returned. It returns
+// true if the routine was stopped while waiting.
+func (r *routine) runInheritTheme(parent theme.Theme) bool {
+	if r.waitPending() {
+		return true
+	}
+
+	if themer, ok := r.handler.(Themer); ok {
+		themer.InheritTheme(parent)
+	}
+
+	return false
+}
+
+// inheritTheme passes the statusbar's theme down to the handler if it uses one. If the routine is running, then its
+// own goroutine passes the theme along between updates, so that the handler's theme never changes during an update.
+func (r *routine) inheritTheme(parent theme.Theme) {
+	themer, ok := r.handler.(Themer)
+	if !ok {
+		return
+	}
+	if !r.isActive() {
+		themer.InheritTheme(parent)
+		return
+	}
+
+	// Only the newest theme matters, so replace any theme that is still waiting.
+	select {
+	case <-r.themeChan:
+	default:
+	}
+	select {
+	case r.themeChan <- parent:
+	default:
+	}
+}
+
+// click queues a click on the routine's output for the routine's goroutine to handle. If too many clicks are already
+// waiting, the click is dropped.
+func (r *routine) click(button int) {
+	if r != nil && r.clickChan != nil {
+		select {
+		case r.clickChan <- button:
+		default:
+		}
+	}
+}
+
 // setHandler sets the routine's handler.
 func (r *routine) setHandler(handler RoutineHandler) {
 	if r != nil {
@@ -126,8 +465,15 @@ func (r *routine) setHandler(handler RoutineHandler) {
 
 // interval returns the routine's interval in seconds.
 func (r *routine) interval() int {
+	return int(r.intervalDuration().Seconds())
+}
+
+// intervalDuration returns the time between each run of the routine.
+func (r *routine) intervalDuration() time.Duration {
 	if r != nil {
-		return int(r.intervalTime.Seconds())
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		return r.intervalTime
 	}
 	return 0
 }
@@ -135,13 +481,514 @@ func (r *routine) interval() int {
 // setInterval sets the routine's interval in seconds.
 func (r *routine) setInterval(interval int) {
 	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
 		r.intervalTime = time.Duration(interval) * time.Second
 	}
 }
 
+// retryPolicy returns the routine's retry policy, or the default policy for its interval if it doesn't have one.
+func (r *routine) retryPolicy() RetryPolicy {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.retry != nil {
+		return *r.retry
+	}
+	return defaultRetryPolicy(r.intervalTime)
+}
+
+// setRetryPolicy sets the routine's retry policy. If policy is nil, the default policy is used.
+func (r *routine) setRetryPolicy(policy *RetryPolicy) {
+	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.retry = policy
+	}
+}
+
+// fail records a failed update. It returns how long to wait before trying again and whether or not the routine should
+// give up.
+func (r *routine) fail() (time.Duration, bool) {
+	policy := r.retryPolicy()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.failures++
+	if policy.givesUp(r.failures) {
+		r.nextRetry = time.Time{}
+		return 0, true
+	}
+
+	wait := policy.backoff(r.failures)
+	r.nextRetry = r.now().Add(wait)
+	return wait, false
+}
+
+// succeed records a successful update, which resets the backoff.
+func (r *routine) succeed() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.failures = 0
+	r.nextRetry = time.Time{}
+}
+
+// retryState returns the number of updates in a row that have failed and the time of the next retry, which is zero if
+// the last update succeeded.
+func (r *routine) retryState() (int, time.Time) {
+	if r == nil {
+		return 0, time.Time{}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.failures, r.nextRetry
+}
+
+// widthLimit returns the most columns that the routine's output can take up and where to put the ellipsis.
+func (r *routine) widthLimit() (int, markup.Ellipsis) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.width, r.ellipsis
+}
+
+// setWidthLimit sets the most columns that the routine's output can take up and where to put the ellipsis. A width of 0
+// or less means no limit.
+func (r *routine) setWidthLimit(width int, ellipsis markup.Ellipsis) {
+	if r != nil {
+		if width < 0 {
+			width = 0
+		}
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.width, r.ellipsis = width, ellipsis
+	}
+}
+
+// regionName returns the name of the region that the routine is displayed in.
+func (r *routine) regionName() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.region
+}
+
+// setRegion sets the name of the region that the routine is displayed in.
+func (r *routine) setRegion(name string) {
+	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.region = name
+	}
+}
+
+// marqueeSettings returns the routine's marquee and the time that it started scrolling.
+func (r *routine) marqueeSettings() (Marquee, time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.marquee, r.marqueeStart
+}
+
+// setMarquee sets the routine's marquee. If the marquee changed, it starts scrolling from the start.
+func (r *routine) setMarquee(m Marquee) {
+	if r != nil {
+		if m.Step <= 0 {
+			m.Step = defaultMarqueeStep
+		}
+		if m.Gap == "" {
+			m.Gap = defaultMarqueeGap
+		}
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		if m != r.marquee {
+			r.marquee, r.marqueeStart = m, r.now()
+		}
+	}
+}
+
+// setHistory sets how much of the routine's history is kept. Samples that are already recorded are kept
+// if they still fit.
+func (r *routine) setHistory(h History) {
+	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.history.resize(h)
+	}
+}
+
+// historySettings returns how much of the routine's history is kept.
+func (r *routine) historySettings() History {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.history.History
+}
+
+// record adds the routine's values to its history.
+func (r *routine) record(values []value.Value) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.history.record(r.now(), values)
+}
+
+// keepHistory makes sure that the routine's history keeps at least depth samples, no matter how much of the history
+// was asked for with WithHistory.
+func (r *routine) keepHistory(depth int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.history.min = depth
+	r.history.resize(r.history.History)
+}
+
+// historyOf returns the samples of the value with the key from the routine's history, from oldest to newest. Samples
+// without the value are skipped.
+func (r *routine) historyOf(key string) []float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var samples []float64
+	for _, s := range r.history.list() {
+		if v, ok := s.values[key]; ok {
+			samples = append(samples, v)
+		}
+	}
+
+	return samples
+}
+
+// historySamples returns the samples in the routine's history, from oldest to newest, along with the
+// unit of each value.
+func (r *routine) historySamples() ([]sample, map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	units := make(map[string]string, len(r.history.units))
+	for name, unit := range r.history.units {
+		units[name] = unit
+	}
+
+	return r.history.list(), units
+}
+
+// slotName returns the name of the slot that the routine takes turns in.
+func (r *routine) slotName() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.slot
+}
+
+// setSlot sets the name of the slot that the routine takes turns in.
+func (r *routine) setSlot(name string) {
+	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.slot = name
+	}
+}
+
+// setVisibility sets the rules that decide whether or not the routine is shown.
+func (r *routine) setVisibility(rules []*visibilityRule) {
+	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.visibility = rules
+	}
+}
+
+// visibilityRules returns the routine's visibility rules and whether or not each one is turned on.
+func (r *routine) visibilityRules() ([]VisibilityRule, []bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	rules := make([]VisibilityRule, len(r.visibility))
+	enabled := make([]bool, len(r.visibility))
+	for i, rule := range r.visibility {
+		rules[i], enabled[i] = rule.rule, rule.enabled
+	}
+
+	return rules, enabled
+}
+
+// enableRule turns the visibility rule with this name on or off.
+func (r *routine) enableRule(name string, enabled bool) error {
+	r.mutex.Lock()
+	var found bool
+	for _, rule := range r.visibility {
+		if rule.rule.Name == name {
+			rule.enabled, found = enabled, true
+		}
+	}
+	changed := r.changed
+	r.mutex.Unlock()
+
+	if !found {
+		return fmt.Errorf("invalid rule")
+	}
+	if changed != nil {
+		changed()
+	}
+
+	return nil
+}
+
+// isHidden returns whether or not any of the routine's visibility rules hide this output.
+func (r *routine) isHidden(o output) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.visibility) == 0 {
+		return false
+	}
+
+	values := outputValues(o)
+	for _, rule := range r.visibility {
+		if rule.hides(values) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setAlerts sets the routine's alert rules. Rules that are the same as before keep the state of their alerts.
+func (r *routine) setAlerts(rules []*alertRule) {
+	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		keepAlertState(rules, r.alerts)
+		r.alerts = rules
+	}
+}
+
+// checkAlerts checks the routine's alert rules against its output, and it logs and sends the alerts that were raised or
+// cleared.
+func (r *routine) checkAlerts(o output) {
+	values := outputValues(o)
+	now := r.now()
+
+	r.mutex.Lock()
+	var alerts []Alert
+	for _, rule := range r.alerts {
+		if alert := rule.check(r, now, values); alert != nil {
+			alerts = append(alerts, *alert)
+		}
+	}
+	send := r.sendAlert
+	r.mutex.Unlock()
+
+	for _, alert := range alerts {
+		level := LevelWarn
+		if alert.Critical {
+			level = LevelError
+		}
+		if alert.Cleared.IsZero() {
+			r.log(level, "Alert raised", "alert", alert.Name, "message", alert.Message)
+		} else {
+			r.log(LevelInfo, "Alert cleared", "alert", alert.Name)
+		}
+
+		if send != nil {
+			send(alert)
+		}
+	}
+}
+
+// raisedAlerts returns the routine's alerts that are raised right now.
+func (r *routine) raisedAlerts() []Alert {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var alerts []Alert
+	for _, rule := range r.alerts {
+		if rule.raised {
+			alerts = append(alerts, rule.alert(r))
+		}
+	}
+	return alerts
+}
+
+// log keeps a message about the routine in its recent logs and sends it to the logger, labeled with the routine's
+// module name.
+func (r *routine) log(level Level, msg string, keyvals ...interface{}) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	r.logs.add(logEntry{time: r.now(), level: level, message: msg, fields: keyvals})
+	r.mutex.Unlock()
+
+	logAt(level, msg, append([]interface{}{"routine", r.moduleName()}, keyvals...)...)
+}
+
+// logEntries returns the routine's recent log entries, from oldest to newest.
+func (r *routine) logEntries() []logEntry {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.logs.list()
+}
+
+// restartPolicy returns the routine's restart policy and restart delay.
+func (r *routine) restartPolicy() (RestartPolicy, time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.restart, r.restartDelay
+}
+
+// setRestartPolicy sets the routine's restart policy and restart delay.
+func (r *routine) setRestartPolicy(policy RestartPolicy, delay time.Duration) {
+	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.restart, r.restartDelay = policy, delay
+	}
+}
+
+// setExit records why the routine stopped.
+func (r *routine) setExit(exit exitReason) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.exit = exit
+}
+
+// shouldRestart returns true if the routine's restart policy says to start it again after the way it last stopped.
+func (r *routine) shouldRestart() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	switch r.restart {
+	case RestartAlways:
+		return r.exit != exitStopped
+	case RestartOnFailure:
+		return r.exit == exitFailed
+	}
+	return false
+}
+
+// countRestart records that the routine is being restarted.
+func (r *routine) countRestart() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.restarts++
+}
+
+// restartCount returns the number of times the routine has been restarted.
+func (r *routine) restartCount() int {
+	if r == nil {
+		return 0
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.restarts
+}
+
+// countUpdate records an update that took d and returned err.
+func (r *routine) countUpdate(d time.Duration, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.updates++
+	if err != nil {
+		r.errors++
+	}
+	r.duration = d
+}
+
+// updateStats returns the number of updates that have run, the number of them that failed, and how long the latest
+// update took.
+func (r *routine) updateStats() (int, int, time.Duration) {
+	if r == nil {
+		return 0, 0, 0
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.updates, r.errors, r.duration
+}
+
+// timeoutDuration returns the maximum time that a single update can take.
+func (r *routine) timeoutDuration() time.Duration {
+	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		return r.timeout
+	}
+	return 0
+}
+
+// setTimeout sets the maximum time that a single update can take. If timeout is 0, updates can run indefinitely.
+func (r *routine) setTimeout(timeout time.Duration) {
+	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.timeout = timeout
+	}
+}
+
+// output returns the routine's latest output.
+func (r *routine) output() output {
+	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		return r.out
+	}
+	return output{}
+}
+
+// setOutput stores the routine's latest output. If the output is different from before, this calls the routine's
+// changed function.
+func (r *routine) setOutput(o output) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	same := reflect.DeepEqual(r.out, o)
+	r.out = o
+	changed := r.changed
+	r.mutex.Unlock()
+
+	if !same && changed != nil {
+		changed()
+	}
+}
+
+// matches returns true if other has the same module and fingerprint as this routine, meaning that this routine can
+// stand in for other when the statusbar is reloaded. Routines without a fingerprint never match.
+func (r *routine) matches(other *routine) bool {
+	if r == nil || other == nil || r.fingerprint == "" {
+		return false
+	}
+	return r.name == other.name && r.fingerprint == other.fingerprint
+}
+
 // isActive returns whether or not the routine is currently up.
 func (r *routine) isActive() bool {
 	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
 		return r.active
 	}
 	return false
@@ -149,6 +996,8 @@ func (r *routine) isActive() bool {
 
 func (r *routine) setActive(active bool) {
 	if r != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
 		r.active = active
 	}
 }
@@ -157,7 +1006,7 @@ func (r *routine) setActive(active bool) {
 // returns 0.
 func (r *routine) uptime() int {
 	if r != nil && r.isActive() {
-		t := time.Since(r.startTime)
+		t := r.now().Sub(r.startTime)
 		return int(t.Seconds())
 	}
 	return 0
@@ -191,9 +1040,13 @@ func (r *routine) setModuleName(name string) {
 
 // update restarts the routine by calling Update.
 func (r *routine) update() {
-	// Update the routine by sending an empty struct on its update channel.
+	// Update the routine by sending an empty struct on its update channel. If an update is already
+	// pending, then we don't need to queue another one.
 	if r != nil && r.updateChan != nil {
-		r.updateChan <- struct{}{}
+		select {
+		case r.updateChan <- struct{}{}:
+		default:
+		}
 	}
 }
 
@@ -7,9 +7,11 @@ import (
 	"io/ioutil"
 	"strconv"
 	"strings"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // These are the possible charging states of the battery.
 const (
@@ -33,30 +35,21 @@ type Routine struct {
 	// Status of the battery (unknown, charging, discharging, or full).
 	status int
 
-	// The three user-provided colors for displaying the various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
-// New reads the maximum capacity of the battery and returns a Routine object. colors is an optional
-// triplet of hex color codes for colorizing the output based on these rules:
-//   1. Normal color, battery has more than 25% left.
-//   2. Warning color, battery has between 10% and 25% left.
-//   3. Error color, battery has less than 10% left.
-func New(colors ...[3]string) *Routine {
+// New reads the maximum capacity of the battery and returns a Routine object. theme is an optional
+// theme for colorizing the output based on these rules:
+//   1. Normal colors, battery has more than 25% left.
+//   2. Warning colors, battery has between 10% and 25% left.
+//   3. Error colors, battery has less than 10% left.
+func New(themes ...theme.Theme) *Routine {
 	var r Routine
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	// Error will be handled in both Update() and String().
@@ -118,13 +111,23 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
-	var c string
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats the percentage of battery left as a single segment, colored according to how
+// much capacity is left.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
+	var state markup.State
 	if r.perc > 25 {
-		c = r.colors.normal
+		state = markup.StateNormal
 	} else if r.perc > 10 {
-		c = r.colors.warning
+		state = markup.StateWarning
 	} else {
-		c = r.colors.error
+		state = markup.StateError
 	}
 
 	s := fmt.Sprintf("%v%%", r.perc)
@@ -136,7 +139,35 @@ func (r *Routine) String() string {
 		s = "Full"
 	}
 
-	return fmt.Sprintf("%s%s BAT%s", c, s, colorEnd)
+	return []markup.Segment{r.theme.Segment(s+" BAT", state)}
+}
+
+// Values returns the percentage of battery left ("perc"), the charging status ("status", which is
+// "charging", "discharging", "full", or "unknown"), and whether the battery is charging ("charging")
+// or on AC power ("ac", which is true when charging or full).
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	var status string
+	switch r.status {
+	case statusCharging:
+		status = "charging"
+	case statusDischarging:
+		status = "discharging"
+	case statusFull:
+		status = "full"
+	default:
+		status = "unknown"
+	}
+
+	return []value.Value{
+		value.NewNumber("perc", float64(r.perc), "%"),
+		value.NewText("status", status),
+		value.NewBool("charging", r.status == statusCharging),
+		value.NewBool("ac", r.status == statusCharging || r.status == statusFull),
+	}
 }
 
 // Error formats and returns an error message.
@@ -149,7 +180,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
@@ -0,0 +1,17 @@
+// This file registers the module so that it can be created by name, such as from a configuration file.
+
+package sbbattery
+
+import (
+	"github.com/snhilde/statusbar/v5/registry"
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+func init() {
+	registry.Register(registry.Module{
+		Name: "sbbattery",
+		New: func(options interface{}, t theme.Theme) (registry.Routine, error) {
+			return New(t), nil
+		},
+	})
+}
@@ -8,9 +8,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // We need to root around in this directory for the device directory for the fan.
 const baseDir = "/sys/class/hwmon/"
@@ -26,31 +28,21 @@ type Routine struct {
 	// Average temperature across all sensors, in degrees Celsius.
 	temp int
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
 // New finds the device directory, builds a list of all the temperature sensors in it, and makes a
-// new object. colors is an optional triplet of hex color codes for colorizing the output based on
-// these rules:
-//   1. Normal color, CPU temperature is cooler than 75 °C.
-//   2. Warning color, CPU temperature is between 75 °C and 100 °C.
-//   3. Error color, CPU temperature is hotter than 100 °C.
-func New(colors ...[3]string) *Routine {
+// new object. theme is an optional theme for colorizing the output based on these rules:
+//   1. Normal colors, CPU temperature is cooler than 75 °C.
+//   2. Warning colors, CPU temperature is between 75 °C and 100 °C.
+//   3. Error colors, CPU temperature is hotter than 100 °C.
+func New(themes ...theme.Theme) *Routine {
 	var r Routine
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	path, err := findDir()
@@ -115,16 +107,35 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
-	var c string
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats the temperature average as a single segment, colored according to how hot the
+// CPU is.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
+	var state markup.State
 	if r.temp < 75 {
-		c = r.colors.normal
+		state = markup.StateNormal
 	} else if r.temp < 100 {
-		c = r.colors.warning
+		state = markup.StateWarning
 	} else {
-		c = r.colors.error
+		state = markup.StateError
 	}
 
-	return fmt.Sprintf("%s%v °C%s", c, r.temp, colorEnd)
+	return []markup.Segment{r.theme.Segment(fmt.Sprintf("%v °C", r.temp), state)}
+}
+
+// Values returns the average temperature of the CPU ("temp").
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	return []value.Value{value.NewNumber("temp", float64(r.temp), "°C")}
 }
 
 // Error formats and returns an error message.
@@ -137,7 +148,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
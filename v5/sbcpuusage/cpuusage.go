@@ -3,21 +3,25 @@ package sbcpuusage
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/graph"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // Routine is the main object for this package.
 type Routine struct {
 	// Error encountered along the way, if any.
 	err error
 
-	// Number of threads per CPU core.
+	// Number of threads per CPU core. This is 0 until the first update finds it.
 	threads int
 
 	// CPU stats from last read.
@@ -26,12 +30,14 @@ type Routine struct {
 	// Percentage of CPU currently being used.
 	perc int
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Graph to show instead of the percentage, as set with SetGraph.
+	graph graph.Graph
+
+	// Function that returns the routine's history, for the graph (see SetHistory).
+	history func(key string) []float64
+
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
 // stats holds values of different CPU stats.
@@ -42,31 +48,17 @@ type stats struct {
 	idle int
 }
 
-// New gets current CPU stats and makes a new routine object. colors is an optional triplet of hex
-// color codes for colorizing the output based on these rules:
-//   1. Normal color, CPU is running at less than 75% of its capacity.
-//   2. Warning color, CPU is running at between 75% and 90% of its capacity.
-//   3. Error color, CPU is running at more than 90% of its capacity.
-func New(colors ...[3]string) *Routine {
+// New gets current CPU stats and makes a new routine object. theme is an optional theme for
+// colorizing the output based on these rules:
+//   1. Normal colors, CPU is running at less than 75% of its capacity.
+//   2. Warning colors, CPU is running at between 75% and 90% of its capacity.
+//   3. Error colors, CPU is running at more than 90% of its capacity.
+func New(themes ...theme.Theme) *Routine {
 	var r Routine
 
-	// Set this now so we can key off it in Update to determine whether or not New was successful.
-	r.threads = -1
-
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
-	}
-
-	// Find out how many threads the CPU has.
-	r.threads, r.err = numThreads()
-	if r.err != nil {
-		return &r
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	err := readStats(&(r.oldStats))
@@ -80,13 +72,25 @@ func New(colors ...[3]string) *Routine {
 // Update gets the current CPU stats, compares them to the last-read stats, and calculates the
 // percentage of CPU currently being used.
 func (r *Routine) Update() (bool, error) {
+	return r.UpdateContext(context.Background())
+}
+
+// UpdateContext is like Update. On the first run, it also finds out how many threads the CPU has,
+// and the 'lscpu' command used for that is killed if ctx is cancelled before it finishes.
+func (r *Routine) UpdateContext(ctx context.Context) (bool, error) {
 	if r == nil {
 		return false, fmt.Errorf("bad routine")
 	}
 
-	// Handle error in New.
-	if r.threads < 0 {
-		return false, r.err
+	// Find out how many threads the CPU has, if we don't know yet. If the command was cancelled, then
+	// we'll try again next time. Otherwise, we can't calculate anything without this.
+	if r.threads <= 0 {
+		threads, err := numThreads(ctx)
+		if err != nil {
+			r.err = fmt.Errorf("error finding threads")
+			return ctx.Err() != nil, err
+		}
+		r.threads = threads
 	}
 
 	var newStats stats
@@ -120,23 +124,83 @@ func (r *Routine) Update() (bool, error) {
 	return true, nil
 }
 
+// SetGraph shows a graph of the percentage of CPU being used instead of the percentage itself.
+func (r *Routine) SetGraph(g graph.Graph) {
+	if r != nil {
+		r.graph = g
+	}
+}
+
 // String prints the formatted CPU percentage.
 func (r *Routine) String() string {
 	if r == nil {
 		return "bad routine"
 	}
 
-	var c string
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats the CPU percentage as a single segment, colored according to how much of the CPU
+// is being used.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
 
+	var state markup.State
 	if r.perc < 75 {
-		c = r.colors.normal
+		state = markup.StateNormal
 	} else if r.perc < 90 {
-		c = r.colors.warning
+		state = markup.StateWarning
 	} else {
-		c = r.colors.error
+		state = markup.StateError
+	}
+
+	if r.graph.Style != graph.None {
+		text, drawing := r.graph.Draw(r.samples(), 0, 100)
+		segment := r.theme.Segment(text, state)
+		segment.Drawing = drawing
+		return []markup.Segment{segment, r.theme.Segment(" CPU", state)}
 	}
 
-	return fmt.Sprintf("%s%2d%% CPU%s", c, r.perc, colorEnd)
+	return []markup.Segment{r.theme.Segment(fmt.Sprintf("%2d%% CPU", r.perc), state)}
+}
+
+// Values returns the percentage of CPU being used ("perc").
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	return []value.Value{value.NewNumber("perc", float64(r.perc), "%")}
+}
+
+// HistoryDepth returns the number of percentages that the graph draws, or 0 if there isn't a graph.
+func (r *Routine) HistoryDepth() int {
+	if r == nil || r.graph.Style == graph.None {
+		return 0
+	}
+
+	return r.graph.Samples()
+}
+
+// SetHistory sets the function that returns the recent percentages of CPU being used for the graph.
+func (r *Routine) SetHistory(samples func(key string) []float64) {
+	if r != nil {
+		r.history = samples
+	}
+}
+
+// samples returns the recent percentages of CPU being used for the graph. Without a history, only
+// the current percentage is drawn.
+func (r *Routine) samples() []float64 {
+	if r.history != nil {
+		if samples := r.history("perc"); len(samples) > 0 {
+			return samples
+		}
+	}
+
+	return []float64{float64(r.perc)}
 }
 
 // Error formats and returns an error message.
@@ -149,7 +213,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
@@ -161,8 +233,8 @@ func (r *Routine) Name() string {
 // per CPU core. We don't care about the number of cores, because we're already reading in the
 // averaged total. We only want to know if we need to be changing its range. To get this number,
 // we're going to loop through each line of the output until we find "Thread(s) per socket".
-func numThreads() (int, error) {
-	proc := exec.Command("lscpu")
+func numThreads(ctx context.Context) (int, error) {
+	proc := exec.CommandContext(ctx, "lscpu")
 	out, err := proc.Output()
 	if err != nil {
 		return -1, err
@@ -3,11 +3,12 @@ package sbdisk
 
 import (
 	"fmt"
-	"strings"
 	"syscall"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // Routine is the main object for this package.
 type Routine struct {
@@ -17,12 +18,8 @@ type Routine struct {
 	// Slice of provided filesystems to stat.
 	disks []fs
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
 // fs holds information about a single filesystem.
@@ -42,18 +39,22 @@ type fs struct {
 	// Unit for the total bytes.
 	totalUnit rune
 
+	// Used and total bytes for this filesystem, before they are made human-readable.
+	usedBytes  uint64
+	totalBytes uint64
+
 	// Percentage of total disk space used.
 	// Note: Bavail is the amount of blocks that can actually be used, while Bfree is the total
 	//       amount of unused blocks.
 	perc uint64
 }
 
-// New copies over the provided filesystem paths and makes a new routine object. colors is an
-// optional triplet of hex color codes for colorizing the output based on these rules:
-//   1. Normal color, disk is less than 75% full.
-//   2. Warning color, disk is between 75% and 90% full.
-//   3. Error color, disk is over 90% full.
-func New(paths []string, colors ...[3]string) *Routine {
+// New copies over the provided filesystem paths and makes a new routine object. theme is an
+// optional theme for colorizing the output based on these rules:
+//   1. Normal colors, disk is less than 75% full.
+//   2. Warning colors, disk is between 75% and 90% full.
+//   3. Error colors, disk is over 90% full.
+func New(paths []string, themes ...theme.Theme) *Routine {
 	var r Routine
 
 	if len(paths) == 0 {
@@ -61,17 +62,12 @@ func New(paths []string, colors ...[3]string) *Routine {
 		return &r
 	}
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
-	// We want to do this after checking the colors so we can know in Update if New was successful
+	// We want to do this after storing the theme so we can know in Update if New was successful
 	// or not.
 	for _, path := range paths {
 		r.disks = append(r.disks, fs{path: path})
@@ -103,6 +99,7 @@ func (r *Routine) Update() (bool, error) {
 		total := b.Blocks * uint64(b.Bsize)
 		used := total - (b.Bavail * uint64(b.Bsize))
 		r.disks[i].perc = (used * 100) / total
+		r.disks[i].usedBytes, r.disks[i].totalBytes = used, total
 
 		r.disks[i].used, r.disks[i].usedUnit = shrink(used)
 		r.disks[i].total, r.disks[i].totalUnit = shrink(total)
@@ -117,26 +114,54 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
-	c := ""
-	b := new(strings.Builder)
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats the amounts of disk space with one segment for each provided filesystem, colored
+// according to how full it is.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
+	segments := make([]markup.Segment, 0, len(r.disks)*2)
 	for i, disk := range r.disks {
+		var state markup.State
 		if disk.perc > 90 {
-			c = r.colors.error
+			state = markup.StateError
 		} else if disk.perc > 75 {
-			c = r.colors.warning
+			state = markup.StateWarning
 		} else {
-			c = r.colors.normal
+			state = markup.StateNormal
 		}
 
 		if i > 0 {
-			b.WriteString(", ")
+			segments = append(segments, markup.Segment{Text: ", "})
 		}
-		b.WriteString(c)
-		fmt.Fprintf(b, "%s: %v%c/%v%c", disk.path, disk.used, disk.usedUnit, disk.total, disk.totalUnit)
-		b.WriteString(colorEnd)
+		text := fmt.Sprintf("%s: %v%c/%v%c", disk.path, disk.used, disk.usedUnit, disk.total, disk.totalUnit)
+		segments = append(segments, r.theme.Segment(text, state))
 	}
 
-	return b.String()
+	return segments
+}
+
+// Values returns the percentage of disk space used ("perc") and the used ("used") and total
+// ("total") bytes of each filesystem. Each value is labeled with the filesystem's path ("path").
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	values := make([]value.Value, 0, len(r.disks)*3)
+	for _, disk := range r.disks {
+		values = append(values,
+			value.NewNumber("perc", float64(disk.perc), "%").WithLabel("path", disk.path),
+			value.NewNumber("used", float64(disk.usedBytes), "B").WithLabel("path", disk.path),
+			value.NewNumber("total", float64(disk.totalBytes), "B").WithLabel("path", disk.path),
+		)
+	}
+
+	return values
 }
 
 // Error formats and returns an error message.
@@ -149,7 +174,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
@@ -0,0 +1,27 @@
+// This file registers the module so that it can be created by name, such as from a configuration file.
+
+package sbdisk
+
+import (
+	"github.com/snhilde/statusbar/v5/registry"
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+// Options are the settings used to create a routine by name. See New for details on each one.
+type Options struct {
+	// Filesystem paths to monitor.
+	Paths []string `config:"paths"`
+}
+
+func init() {
+	registry.Register(registry.Module{
+		Name: "sbdisk",
+		Options: func() interface{} {
+			return &Options{Paths: []string{"/"}}
+		},
+		New: func(options interface{}, t theme.Theme) (registry.Routine, error) {
+			o := options.(*Options)
+			return New(o.Paths, t), nil
+		},
+	})
+}
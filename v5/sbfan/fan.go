@@ -7,9 +7,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // We need to root around in this directory for the device directory for the fan.
 const baseDir = "/sys/class/hwmon/"
@@ -28,31 +30,21 @@ type Routine struct {
 	// Current speed of the fan, in RPM.
 	speed int
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
 // New searches around in the base directory for a pair of max and current files and makes a new
-// routine object. colors is an optional triplet of hex color codes for colorizing the output based
-// on these rules:
-//   1. Normal color, fan is running at less than 75% of the maximum RPM.
-//   2. Warning color, fan is running at between 75% and 90% of the maximum RPM.
-//   3. Error color, fan is running at more than 90% of the maximum RPM.
-func New(colors ...[3]string) *Routine {
+// routine object. theme is an optional theme for colorizing the output based on these rules:
+//   1. Normal colors, fan is running at less than 75% of the maximum RPM.
+//   2. Warning colors, fan is running at between 75% and 90% of the maximum RPM.
+//   3. Error colors, fan is running at more than 90% of the maximum RPM.
+func New(themes ...theme.Theme) *Routine {
 	var r Routine
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	// Find the files holding the values for the maximum fan speed and the current fan speed.
@@ -96,22 +88,49 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
-	var c string
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats the current speed as a single segment, colored according to how close the fan is
+// to its maximum speed.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
 
 	perc := (r.speed * 100) / r.max
 	if perc > 100 {
 		perc = 100
 	}
 
+	var state markup.State
 	if perc < 75 {
-		c = r.colors.normal
+		state = markup.StateNormal
 	} else if perc < 90 {
-		c = r.colors.warning
+		state = markup.StateWarning
 	} else {
-		c = r.colors.error
+		state = markup.StateError
 	}
 
-	return fmt.Sprintf("%s%v RPM%s", c, r.speed, colorEnd)
+	return []markup.Segment{r.theme.Segment(fmt.Sprintf("%v RPM", r.speed), state)}
+}
+
+// Values returns the current speed of the fan ("rpm") and the percentage of its maximum speed
+// ("perc").
+func (r *Routine) Values() []value.Value {
+	if r == nil || r.max == 0 {
+		return nil
+	}
+
+	perc := (r.speed * 100) / r.max
+	if perc > 100 {
+		perc = 100
+	}
+
+	return []value.Value{
+		value.NewNumber("rpm", float64(r.speed), "RPM"),
+		value.NewNumber("perc", float64(perc), "%"),
+	}
 }
 
 // Error formats and returns an error message.
@@ -124,7 +143,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
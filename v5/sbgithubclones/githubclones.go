@@ -2,15 +2,19 @@
 package sbgithubclones
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // Routine is the main object for this package. It contains the objects needed to query the current
 // clone count for the day and week.
@@ -32,22 +36,18 @@ type Routine struct {
 	dayCount  string
 	weekCount string
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
 // New makes a new routine object. owner is the username of the repository's owner. repo is the name
 // of the repository. authUser is the username for authentication (must have push permissions to
-// repo). authToken is the token for authentication. colors is an optional triplet of hex color
-// codes for colorizing the output based on these rules:
-//   1. Normal color, used for normal printing.
-//   2. Warning color, currently unused.
-//   3. Error color, used for printing error messages.
-func New(owner, repo, authUser, authToken string, colors ...[3]string) *Routine {
+// repo). authToken is the token for authentication. theme is an optional theme for colorizing the
+// output based on these rules:
+//   1. Normal colors, used for normal printing.
+//   2. Warning colors, currently unused.
+//   3. Error colors, used for printing error messages.
+func New(owner, repo, authUser, authToken string, themes ...theme.Theme) *Routine {
 	var r Routine
 
 	r.repo = repo
@@ -70,14 +70,9 @@ func New(owner, repo, authUser, authToken string, colors ...[3]string) *Routine
 	r.reqDay = day
 	r.reqWeek = week
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	return &r
@@ -85,18 +80,29 @@ func New(owner, repo, authUser, authToken string, colors ...[3]string) *Routine
 
 // Update gets the current clone count.
 func (r *Routine) Update() (bool, error) {
+	return r.UpdateContext(context.Background())
+}
+
+// UpdateContext is like Update, but the requests to Github are cancelled if ctx is cancelled
+// before they finish.
+func (r *Routine) UpdateContext(ctx context.Context) (bool, error) {
 	if r == nil {
 		return false, fmt.Errorf("bad routine")
 	}
 
-	day, err := getCount(r.client, r.reqDay, true)
+	// Handle error in New.
+	if r.reqDay == nil || r.reqWeek == nil {
+		return false, r.err
+	}
+
+	day, err := getCount(r.client, r.reqDay.WithContext(ctx), true)
 	if err != nil {
 		r.err = fmt.Errorf("error getting today's count")
 		return true, err
 	}
 	r.dayCount = day
 
-	week, err := getCount(r.client, r.reqWeek, false)
+	week, err := getCount(r.client, r.reqWeek.WithContext(ctx), false)
 	if err != nil {
 		r.err = fmt.Errorf("error getting this week's count")
 		return true, err
@@ -113,6 +119,15 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats the current clone count for the day and week as a single segment.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
 	if r.dayCount == "" {
 		r.dayCount = "-"
 	}
@@ -120,7 +135,26 @@ func (r *Routine) String() string {
 		r.weekCount = "-"
 	}
 
-	return fmt.Sprintf("%s%s: %s/%s Clones%s", r.colors.normal, r.repo, r.dayCount, r.weekCount, colorEnd)
+	text := fmt.Sprintf("%s: %s/%s Clones", r.repo, r.dayCount, r.weekCount)
+	return []markup.Segment{r.theme.Segment(text, markup.StateNormal)}
+}
+
+// Values returns the number of clones today ("day") and this week ("week"). A count that Github
+// didn't return is left out.
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	var values []value.Value
+	if day, err := strconv.Atoi(r.dayCount); err == nil {
+		values = append(values, value.NewNumber("day", float64(day), ""))
+	}
+	if week, err := strconv.Atoi(r.weekCount); err == nil {
+		values = append(values, value.NewNumber("week", float64(week), ""))
+	}
+
+	return values
 }
 
 // Error formats and returns an error message.
@@ -133,7 +167,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
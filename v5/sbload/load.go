@@ -4,9 +4,11 @@ package sbload
 import (
 	"fmt"
 	"syscall"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // Routine is the main object in the package.
 type Routine struct {
@@ -22,30 +24,21 @@ type Routine struct {
 	// Load average over the last   15 seconds.
 	load15 float64
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
-// New makes a new rountine object. colors is an optional triplet of hex color codes for colorizing
-// the output based on these rules:
-//   1. Normal color, all load averages are below 1.
-//   2. Warning color, one or more load averages is greater than 1, but all are less than 2.
-//   3. Error color, one or more load averages is greater than 2.
-func New(colors ...[3]string) *Routine {
+// New makes a new rountine object. theme is an optional theme for colorizing the output based on
+// these rules:
+//   1. Normal colors, all load averages are below 1.
+//   2. Warning colors, one or more load averages is greater than 1, but all are less than 2.
+//   3. Error colors, one or more load averages is greater than 2.
+func New(themes ...theme.Theme) *Routine {
 	var r Routine
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	return &r
@@ -83,17 +76,40 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
-	var c string
+	return markup.Status2d.Render(r.Segments())
+}
 
+// Segments formats the 3 load averages as a single segment, colored according to the highest load.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
+	var state markup.State
 	if r.load1 >= 2 || r.load5 >= 2 || r.load15 >= 2 {
-		c = r.colors.error
+		state = markup.StateError
 	} else if r.load1 >= 1 || r.load5 >= 1 || r.load15 >= 1 {
-		c = r.colors.warning
+		state = markup.StateWarning
 	} else {
-		c = r.colors.normal
+		state = markup.StateNormal
 	}
 
-	return fmt.Sprintf("%s%.2f %.2f %.2f%s", c, r.load1, r.load5, r.load15, colorEnd)
+	text := fmt.Sprintf("%.2f %.2f %.2f", r.load1, r.load5, r.load15)
+	return []markup.Segment{r.theme.Segment(text, state)}
+}
+
+// Values returns the load averages over the last one, five, and fifteen minutes ("load1", "load5",
+// and "load15").
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	return []value.Value{
+		value.NewNumber("load1", r.load1, ""),
+		value.NewNumber("load5", r.load5, ""),
+		value.NewNumber("load15", r.load15, ""),
+	}
 }
 
 // Error formats and returns an error message.
@@ -106,12 +122,20 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	s := r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	s := markup.Status2d.Render([]markup.Segment{segment})
 	r.err = nil
 
 	return s
 }
 
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
+}
+
 // Name returns the display name of this module.
 func (r *Routine) Name() string {
 	return "Load"
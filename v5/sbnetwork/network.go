@@ -8,9 +8,12 @@ import (
 	"net"
 	"strconv"
 	"strings"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/graph"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // Routine is the main object for this package.
 type Routine struct {
@@ -27,12 +30,11 @@ type Routine struct {
 	// Cache of data for every interface monitored.
 	cache map[string]sbiface
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Graph to show instead of the number of bytes, as set with SetGraph.
+	graph graph.Graph
+
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
 // sbiface groups different pieces of information for a single interface.
@@ -51,25 +53,24 @@ type sbiface struct {
 
 	// Current reading of tx_bytes file.
 	newUp int
+
+	// Recent numbers of bytes received and sent, for the graph.
+	downs *graph.Series
+	ups   *graph.Series
 }
 
 // New returns a new routine object populated with either the given interfaces or the active ones if
-// no interfaces are specified. colors is an optional triplet of hex color codes for colorizing the
-// output based on these rules:
-//   1. Normal color, all interfaces are running at Kpbs speeds or less.
-//   2. Warning color, one of more interface is running at Mbps speeds.
-//   3. Error color, one of more interface is running at greater than Mbps speeds.
-func New(inames []string, colors ...[3]string) *Routine {
+// no interfaces are specified. theme is an optional theme for colorizing the output based on these
+// rules:
+//   1. Normal colors, all interfaces are running at Kpbs speeds or less.
+//   2. Warning colors, one of more interface is running at Mbps speeds.
+//   3. Error colors, one of more interface is running at greater than Mbps speeds.
+func New(inames []string, themes ...theme.Theme) *Routine {
 	var r Routine
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	r.givenNames = inames
@@ -126,6 +127,15 @@ func (r *Routine) Update() (bool, error) {
 		}
 		iface.newUp = up
 
+		// The first reading doesn't have anything to compare to, so it isn't graphed.
+		if r.graph.Style != graph.None && iface.downs == nil {
+			iface.downs, iface.ups = graph.NewSeries(r.graph.Samples()), graph.NewSeries(r.graph.Samples())
+		}
+		if iface.oldDown > 0 || iface.oldUp > 0 {
+			iface.downs.Add(float64(iface.newDown - iface.oldDown))
+			iface.ups.Add(float64(iface.newUp - iface.oldUp))
+		}
+
 		iface.enabled = true
 		r.cache[iname] = iface
 	}
@@ -133,47 +143,122 @@ func (r *Routine) Update() (bool, error) {
 	return true, nil
 }
 
+// SetGraph shows graphs of the bytes received and sent by each interface instead of the numbers of
+// bytes. Each graph is scaled to the most bytes in its recent samples.
+func (r *Routine) SetGraph(g graph.Graph) {
+	if r != nil {
+		r.graph = g
+		for iname, iface := range r.cache {
+			iface.downs, iface.ups = nil, nil
+			r.cache[iname] = iface
+		}
+	}
+}
+
 // String calculates the byte difference for each interface, and formats and prints it.
 func (r *Routine) String() string {
 	if r == nil {
 		return "bad routine"
 	}
 
-	var c string
-	var b strings.Builder
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments calculates the byte difference for each interface and formats it with one segment per
+// interface, colored according to how fast the interface is running.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
+	segments := make([]markup.Segment, 0, len(r.printNames)*2)
 	for _, iname := range r.printNames {
 		iface, ok := r.cache[iname]
 		if !ok {
 			continue
 		}
 
-		if b.Len() > 0 {
-			b.WriteString(", ")
+		if len(segments) > 0 {
+			segments = append(segments, markup.Segment{Text: ", "})
 		}
 
 		if iface.enabled {
 			down, downUnit := shrink(iface.newDown - iface.oldDown)
 			up, upUnit := shrink(iface.newUp - iface.oldUp)
 
+			var state markup.State
 			if downUnit == 'B' || upUnit == 'B' || downUnit == 'K' || upUnit == 'K' {
-				c = r.colors.normal
+				state = markup.StateNormal
 			} else if downUnit == 'M' || upUnit == 'M' {
-				c = r.colors.warning
+				state = markup.StateWarning
 			} else {
-				c = r.colors.error
+				state = markup.StateError
 			}
 
-			b.WriteString(c)
-			fmt.Fprintf(&b, "%v: %4v%c↓|%4v%c↑", iname, down, downUnit, up, upUnit)
-			b.WriteString(colorEnd)
+			if r.graph.Style != graph.None {
+				segments = append(segments, r.graphSegments(iname, iface, state)...)
+				continue
+			}
+
+			text := fmt.Sprintf("%v: %4v%c↓|%4v%c↑", iname, down, downUnit, up, upUnit)
+			segments = append(segments, r.theme.Segment(text, state))
 		} else {
-			b.WriteString(r.colors.error)
-			fmt.Fprintf(&b, "%v: Down", iname)
-			b.WriteString(colorEnd)
+			text := fmt.Sprintf("%v: Down", iname)
+			segments = append(segments, r.theme.Segment(text, markup.StateError))
+		}
+	}
+
+	return segments
+}
+
+// graphSegments formats the graphs of the bytes received and sent by the interface.
+func (r *Routine) graphSegments(iname string, iface sbiface, state markup.State) []markup.Segment {
+	downText, downDrawing := r.graph.Draw(iface.downs.Samples(), 0, 0)
+	upText, upDrawing := r.graph.Draw(iface.ups.Samples(), 0, 0)
+
+	down := r.theme.Segment(downText, state)
+	down.Drawing = downDrawing
+	up := r.theme.Segment(upText, state)
+	up.Drawing = upDrawing
+
+	return []markup.Segment{
+		r.theme.Segment(iname+": ", state),
+		down,
+		r.theme.Segment("↓|", state),
+		up,
+		r.theme.Segment("↑", state),
+	}
+}
+
+// Values returns the bytes received ("down") and sent ("up") since the last update across all
+// interfaces that are up, the number of interfaces that are up ("interfaces"), and the total bytes
+// received ("received") and sent ("sent") through each interface that is up, labeled with the
+// interface's name ("interface").
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	var down, up, enabled int
+	var totals []value.Value
+	for _, iname := range r.printNames {
+		if iface, ok := r.cache[iname]; ok && iface.enabled {
+			down += iface.newDown - iface.oldDown
+			up += iface.newUp - iface.oldUp
+			enabled++
+			totals = append(totals,
+				value.NewNumber("received", float64(iface.newDown), "B").WithLabel("interface", iname),
+				value.NewNumber("sent", float64(iface.newUp), "B").WithLabel("interface", iname),
+			)
 		}
 	}
 
-	return b.String()
+	values := []value.Value{
+		value.NewNumber("down", float64(down), "B"),
+		value.NewNumber("up", float64(up), "B"),
+		value.NewNumber("interfaces", float64(enabled), ""),
+	}
+	return append(values, totals...)
 }
 
 // Error formats and returns an error message.
@@ -186,7 +271,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
@@ -0,0 +1,38 @@
+// This file registers the module so that it can be created by name, such as from a configuration file.
+
+package sbnetwork
+
+import (
+	"github.com/snhilde/statusbar/v5/graph"
+	"github.com/snhilde/statusbar/v5/registry"
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+// Options are the settings used to create a routine by name. See New for details on each one.
+type Options struct {
+	// Network interfaces to monitor. All active interfaces are used if this is empty.
+	Interfaces []string `config:"interfaces"`
+
+	// Graphs of the bytes received and sent to show instead of the numbers (see SetGraph).
+	Graph graph.Options `config:"graph"`
+}
+
+func init() {
+	registry.Register(registry.Module{
+		Name: "sbnetwork",
+		Options: func() interface{} {
+			return &Options{}
+		},
+		New: func(options interface{}, t theme.Theme) (registry.Routine, error) {
+			o := options.(*Options)
+			g, err := o.Graph.Graph()
+			if err != nil {
+				return nil, err
+			}
+
+			r := New(o.Interfaces, t)
+			r.SetGraph(g)
+			return r, nil
+		},
+	})
+}
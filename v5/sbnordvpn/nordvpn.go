@@ -3,12 +3,15 @@
 package sbnordvpn
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // Routine is the main object in the package.
 type Routine struct {
@@ -21,33 +24,30 @@ type Routine struct {
 	// Buffer to hold connnection string.
 	blink bool
 
-	// Current color of the 3 provided.
-	color string
+	// Current state of the connection.
+	state markup.State
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Status of the connection as reported by the command, in lowercase, like "connected".
+	status string
+
+	// City of the server, if connected.
+	city string
+
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
-// New makes a new routine object. colors is an optional triplet of hex color codes for colorizing
-// the output based on these rules:
-//   1. Normal color, VPN is connected.
-//   2. Warning color, VPN is disconnected or is in the process of connecting.
-//   3. Error color, error determining status, or network is down.
-func New(colors ...[3]string) *Routine {
+// New makes a new routine object. theme is an optional theme for colorizing the output based on
+// these rules:
+//   1. Normal colors, VPN is connected.
+//   2. Warning colors, VPN is disconnected or is in the process of connecting.
+//   3. Error colors, error determining status, or network is down.
+func New(themes ...theme.Theme) *Routine {
 	var r Routine
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	return &r
@@ -55,6 +55,11 @@ func New(colors ...[3]string) *Routine {
 
 // Update runs the command and captures the output.
 func (r *Routine) Update() (bool, error) {
+	return r.UpdateContext(context.Background())
+}
+
+// UpdateContext is like Update, but the command is killed if ctx is cancelled before it finishes.
+func (r *Routine) UpdateContext(ctx context.Context) (bool, error) {
 	if r == nil {
 		return false, fmt.Errorf("bad routine")
 	}
@@ -62,8 +67,12 @@ func (r *Routine) Update() (bool, error) {
 	// If the command is successful but there's an error with nordvpn (like if the internet is
 	// down), this will return an error code. We still want to capture and parse the error message,
 	// so we're going to ignore any returned error.
-	cmd := exec.Command("nordvpn", "status")
+	cmd := exec.CommandContext(ctx, "nordvpn", "status")
 	output, _ := cmd.Output()
+	if err := ctx.Err(); err != nil {
+		r.err = fmt.Errorf("status timed out")
+		return true, err
+	}
 
 	if err := r.parseOutput(string(output)); err != nil {
 		r.err = err
@@ -79,7 +88,31 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
-	return r.color + r.parsed + colorEnd
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats the current connection status as a single segment.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
+	return []markup.Segment{r.theme.Segment(r.parsed, r.state)}
+}
+
+// Values returns the status of the connection ("status", like "connected" or "disconnected"), whether
+// or not the VPN is connected ("connected"), and the city of the server ("city", empty if not
+// connected).
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	return []value.Value{
+		value.NewText("status", r.status),
+		value.NewBool("connected", r.status == "connected"),
+		value.NewText("city", r.city),
+	}
 }
 
 // Error formats and returns an error message.
@@ -92,7 +125,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
@@ -143,6 +184,8 @@ func (r *Routine) parseOutput(output string) error {
 		return fmt.Errorf("bad response")
 	}
 
+	r.status = strings.ToLower(fields[field+1])
+	r.city = ""
 	if fields[field+1] == "Connected" {
 		for _, line := range lines {
 			if strings.HasPrefix(line, "City") {
@@ -151,15 +194,16 @@ func (r *Routine) parseOutput(output string) error {
 					return fmt.Errorf("error parsing City")
 				}
 
+				r.city = strings.TrimSpace(city[1])
 				r.parsed = "Connected"
 				r.parsed += r.getBlink()
-				r.parsed += strings.TrimSpace(city[1])
-				r.color = r.colors.normal
+				r.parsed += r.city
+				r.state = markup.StateNormal
 			}
 		}
 	} else {
 		r.parsed = fields[field+1]
-		r.color = r.colors.warning
+		r.state = markup.StateWarning
 	}
 
 	return nil
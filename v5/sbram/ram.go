@@ -6,9 +6,12 @@ import (
 	"io/ioutil"
 	"strconv"
 	"strings"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/graph"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // Routine is the main object for this package.
 type Routine struct {
@@ -30,30 +33,31 @@ type Routine struct {
 	// Unit of used memory.
 	usedUnit rune
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Amounts of memory in use and in total, in bytes.
+	usedBytes  int
+	totalBytes int
+
+	// Graph to show instead of the amounts of memory, as set with SetGraph.
+	graph graph.Graph
+
+	// Function that returns the routine's history, for the graph (see SetHistory).
+	history func(key string) []float64
+
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
-// New makes a new routine object. colors is an optional triplet of hex color codes for colorizing
-// the output based on these rules:
-//   1. Normal color, less than 75% of available RAM is being used.
-//   2. Warning color, between 75% and 90% of available RAM is being used.
-//   3. Error color, more than 90% of available RAM is being used.
-func New(colors ...[3]string) *Routine {
+// New makes a new routine object. theme is an optional theme for colorizing the output based on
+// these rules:
+//   1. Normal colors, less than 75% of available RAM is being used.
+//   2. Warning colors, between 75% and 90% of available RAM is being used.
+//   3. Error colors, more than 90% of available RAM is being used.
+func New(themes ...theme.Theme) *Routine {
 	var r Routine
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	return &r
@@ -89,27 +93,94 @@ func (r *Routine) Update() (bool, error) {
 	r.perc = (total - avail) * 100 / total
 	r.total, r.totalUnit = shrink(total)
 	r.used, r.usedUnit = shrink(total - avail)
+	r.usedBytes, r.totalBytes = (total-avail)*1024, total*1024
 
 	return true, nil
 }
 
+// SetGraph shows a graph of the percentage of memory in use instead of the amounts of memory.
+func (r *Routine) SetGraph(g graph.Graph) {
+	if r != nil {
+		r.graph = g
+	}
+}
+
 // String formats and prints the used and total system memory.
 func (r *Routine) String() string {
 	if r == nil {
 		return "bad routine"
 	}
 
-	var color string
+	return markup.Status2d.Render(r.Segments())
+}
 
+// Segments formats the used and total system memory as a single segment, colored according to how
+// much memory is in use.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
+	var state markup.State
 	if r.perc < 75 {
-		color = r.colors.normal
+		state = markup.StateNormal
 	} else if r.perc < 90 {
-		color = r.colors.warning
+		state = markup.StateWarning
 	} else {
-		color = r.colors.error
+		state = markup.StateError
 	}
 
-	return fmt.Sprintf("%s%.1f%c/%.1f%c%s", color, r.used, r.usedUnit, r.total, r.totalUnit, colorEnd)
+	if r.graph.Style != graph.None {
+		text, drawing := r.graph.Draw(r.samples(), 0, 100)
+		segment := r.theme.Segment(text, state)
+		segment.Drawing = drawing
+		return []markup.Segment{segment, r.theme.Segment(" RAM", state)}
+	}
+
+	text := fmt.Sprintf("%.1f%c/%.1f%c", r.used, r.usedUnit, r.total, r.totalUnit)
+	return []markup.Segment{r.theme.Segment(text, state)}
+}
+
+// Values returns the percentage of memory in use ("perc") and the amounts of memory in use ("used")
+// and in total ("total"), in bytes.
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	return []value.Value{
+		value.NewNumber("perc", float64(r.perc), "%"),
+		value.NewNumber("used", float64(r.usedBytes), "B"),
+		value.NewNumber("total", float64(r.totalBytes), "B"),
+	}
+}
+
+// HistoryDepth returns the number of percentages that the graph draws, or 0 if there isn't a graph.
+func (r *Routine) HistoryDepth() int {
+	if r == nil || r.graph.Style == graph.None {
+		return 0
+	}
+
+	return r.graph.Samples()
+}
+
+// SetHistory sets the function that returns the recent percentages of memory in use for the graph.
+func (r *Routine) SetHistory(samples func(key string) []float64) {
+	if r != nil {
+		r.history = samples
+	}
+}
+
+// samples returns the recent percentages of memory in use for the graph. Without a history, only
+// the current percentage is drawn.
+func (r *Routine) samples() []float64 {
+	if r.history != nil {
+		if samples := r.history("perc"); len(samples) > 0 {
+			return samples
+		}
+	}
+
+	return []float64{float64(r.perc)}
 }
 
 // Error formats and returns an error message.
@@ -122,7 +193,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
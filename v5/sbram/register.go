@@ -0,0 +1,35 @@
+// This file registers the module so that it can be created by name, such as from a configuration file.
+
+package sbram
+
+import (
+	"github.com/snhilde/statusbar/v5/graph"
+	"github.com/snhilde/statusbar/v5/registry"
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+// Options are the settings used to create a routine by name.
+type Options struct {
+	// Graph of the percentage of memory in use to show instead of the number (see SetGraph).
+	Graph graph.Options `config:"graph"`
+}
+
+func init() {
+	registry.Register(registry.Module{
+		Name: "sbram",
+		Options: func() interface{} {
+			return &Options{}
+		},
+		New: func(options interface{}, t theme.Theme) (registry.Routine, error) {
+			o := options.(*Options)
+			g, err := o.Graph.Graph()
+			if err != nil {
+				return nil, err
+			}
+
+			r := New(t)
+			r.SetGraph(g)
+			return r, nil
+		},
+	})
+}
@@ -0,0 +1,27 @@
+// This file registers the module so that it can be created by name, such as from a configuration file.
+
+package sbtime
+
+import (
+	"github.com/snhilde/statusbar/v5/registry"
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+// Options are the settings used to create a routine by name. See New for details on each one.
+type Options struct {
+	// Format to use when printing the time.
+	Format string `config:"format"`
+}
+
+func init() {
+	registry.Register(registry.Module{
+		Name: "sbtime",
+		Options: func() interface{} {
+			return &Options{Format: "Jan 2 - 03:04"}
+		},
+		New: func(options interface{}, t theme.Theme) (registry.Routine, error) {
+			o := options.(*Options)
+			return New(o.Format, t), nil
+		},
+	})
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"strings"
 	"time"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // Routine is the main object for the sbtime package.
 type Routine struct {
@@ -23,32 +25,23 @@ type Routine struct {
 	// Format for displaying time, when colons are blinked out (every other second).
 	formatB string
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
 // New creates a new routine object with the current time. format is the format to use when printing
 // the time, as per the go standard used in the time package. If the format includes colons, they
-// will blink every other second. colors is an optional triplet of hex color codes for colorizing
-// the output based on these rules:
-//   1. Normal color, used for normal printing.
-//   2. Warning color, currently unused.
-//   3. Error color, used for printing error messages.
-func New(format string, colors ...[3]string) *Routine {
+// will blink every other second. theme is an optional theme for colorizing the output based on
+// these rules:
+//   1. Normal colors, used for normal printing.
+//   2. Warning colors, currently unused.
+//   3. Error colors, used for printing error messages.
+func New(format string, themes ...theme.Theme) *Routine {
 	var r Routine
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	// Replace all colons in the format string with spaces, to get the blinking effect later.
@@ -84,12 +77,35 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats the time in the provided format as a single segment.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
 	format := r.formatA
 	if r.time.Second()%2 != 0 {
 		format = r.formatB
 	}
 
-	return r.colors.normal + r.time.Format(format) + colorEnd
+	return []markup.Segment{r.theme.Segment(r.time.Format(format), markup.StateNormal)}
+}
+
+// Values returns the time as seconds since the Unix epoch ("unix"), along with the hour of the day
+// ("hour", from 0 to 23) and the minute of the hour ("minute").
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	return []value.Value{
+		value.NewNumber("unix", float64(r.time.Unix()), "s"),
+		value.NewNumber("hour", float64(r.time.Hour()), ""),
+		value.NewNumber("minute", float64(r.time.Minute()), ""),
+	}
 }
 
 // Error formats and returns an error message.
@@ -102,7 +118,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
@@ -0,0 +1,27 @@
+// This file registers the module so that it can be created by name, such as from a configuration file.
+
+package sbtodo
+
+import (
+	"github.com/snhilde/statusbar/v5/registry"
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+// Options are the settings used to create a routine by name. See New for details on each one.
+type Options struct {
+	// Absolute path to the TODO file.
+	Path string `config:"path,required"`
+}
+
+func init() {
+	registry.Register(registry.Module{
+		Name: "sbtodo",
+		Options: func() interface{} {
+			return &Options{}
+		},
+		New: func(options interface{}, t theme.Theme) (registry.Routine, error) {
+			o := options.(*Options)
+			return New(o.Path, t), nil
+		},
+	})
+}
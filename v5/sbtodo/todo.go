@@ -1,4 +1,5 @@
-// Package sbtodo displays the first two lines of a TODO list.
+// Package sbtodo displays the first two lines of a TODO list. Clicking the list moves on to the next
+// line.
 package sbtodo
 
 import (
@@ -6,9 +7,11 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // Routine is the main object for this package. It contains the data obtained from the specified
 // TODO file, including file info and a copy of the first 2 lines.
@@ -28,32 +31,29 @@ type Routine struct {
 	// Second line of the TODO file.
 	line2 string
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Lines of the TODO file that have content.
+	lines []string
+
+	// Index of the line that is displayed first. This moves on to the next line with every click.
+	first int
+
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
-// New makes a new routine object. path is the absolute path to the TODO file. colors is an optional
-// triplet of hex color codes for colorizing the output based on these rules:
-//   1. Normal color, used for normal printing.
-//   2. Warning color, currently unused.
-//   3. Error color, used for printing error messages.
-func New(path string, colors ...[3]string) *Routine {
+// New makes a new routine object. path is the absolute path to the TODO file. theme is an optional
+// theme for colorizing the output based on these rules:
+//   1. Normal colors, used for normal printing.
+//   2. Warning colors, currently unused.
+//   3. Error colors, used for printing error messages.
+func New(path string, themes ...theme.Theme) *Routine {
 	var r Routine
 
 	r.path = path
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	// Grab the base details of the TODO file.
@@ -118,6 +118,16 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats the first two lines of the file as a single segment, following the same rules as
+// String.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
 	// First, let's figure out what joiner (if any) we need.
 	joiner := ""
 	if r.line1 != "" && r.line2 != "" {
@@ -136,7 +146,35 @@ func (r *Routine) String() string {
 		output = "Finished"
 	}
 
-	return r.colors.normal + output + colorEnd
+	return []markup.Segment{r.theme.Segment(output, markup.StateNormal)}
+}
+
+// Click moves on to the next line of the TODO file when the list is clicked with the left mouse
+// button. After the last line, the list starts over from the top.
+func (r *Routine) Click(button int) error {
+	if r == nil {
+		return fmt.Errorf("bad routine")
+	}
+
+	if button == 1 {
+		r.first++
+		r.pick()
+	}
+
+	return nil
+}
+
+// Values returns the number of lines in the file that have content ("lines") and the line that is
+// displayed first ("line"), which is empty if the list is finished.
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	return []value.Value{
+		value.NewNumber("lines", float64(len(r.lines)), ""),
+		value.NewText("line", strings.TrimSpace(r.line1)),
+	}
 }
 
 // Error formats and returns an error message.
@@ -149,7 +187,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
@@ -157,27 +203,40 @@ func (r *Routine) Name() string {
 	return "TODO"
 }
 
-// readFile grabs the first two lines of the TODO file that are not blank.
+// readFile grabs the lines of the TODO file that are not blank.
 func (r *Routine) readFile() error {
-	r.line1 = ""
-	r.line2 = ""
+	r.lines = nil
 
 	contents, err := ioutil.ReadFile(r.path)
 	if err != nil {
+		r.pick()
 		return err
 	}
 
 	lines := strings.Split(string(contents), "\n")
 	for _, line := range lines {
 		if strings.TrimSpace(line) != "" {
-			if r.line1 == "" {
-				r.line1 = line
-			} else {
-				r.line2 = line
-				break
-			}
+			r.lines = append(r.lines, line)
 		}
 	}
+	r.pick()
 
 	return nil
 }
+
+// pick sets the two lines to display, starting with the line that the list was clicked to. If the
+// list doesn't have that many lines, it starts over from the top.
+func (r *Routine) pick() {
+	r.line1 = ""
+	r.line2 = ""
+
+	if r.first >= len(r.lines) {
+		r.first = 0
+	}
+	if len(r.lines) > r.first {
+		r.line1 = r.lines[r.first]
+	}
+	if len(r.lines) > r.first+1 {
+		r.line2 = r.lines[r.first+1]
+	}
+}
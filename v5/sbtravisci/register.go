@@ -0,0 +1,30 @@
+// This file registers the module so that it can be created by name, such as from a configuration file.
+
+package sbtravisci
+
+import (
+	"github.com/snhilde/statusbar/v5/registry"
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+// Options are the settings used to create a routine by name. See New for details on each one.
+type Options struct {
+	// Username of the repository's owner.
+	Owner string `config:"owner,required"`
+
+	// Name of the repository.
+	Repo string `config:"repo,required"`
+}
+
+func init() {
+	registry.Register(registry.Module{
+		Name: "sbtravisci",
+		Options: func() interface{} {
+			return &Options{}
+		},
+		New: func(options interface{}, t theme.Theme) (registry.Routine, error) {
+			o := options.(*Options)
+			return New(o.Owner, o.Repo, t), nil
+		},
+	})
+}
@@ -2,6 +2,7 @@
 package sbtravisci
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -9,9 +10,11 @@ import (
 	"net/url"
 	"strings"
 	"time"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // Routine is the main object for this package. It contains the information needed to query the
 // build status.
@@ -28,12 +31,8 @@ type Routine struct {
 	// Latest build.
 	build build
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
 // build holds the information that Travis returns for the latest build.
@@ -45,12 +44,11 @@ type build struct {
 }
 
 // New makes a new routine object. owner is the username of the repository's owner. repo is the name
-// of the repository. colors is an optional triplet of hex color codes for colorizing the output
-// based on these rules:
-//   1. Normal color, used for enqueued/passing builds.
-//   2. Warning color, used for canceled/failed builds.
-//   3. Error color, used for error messages.
-func New(owner, repo string, colors ...[3]string) *Routine {
+// of the repository. theme is an optional theme for colorizing the output based on these rules:
+//   1. Normal colors, used for enqueued/passing builds.
+//   2. Warning colors, used for canceled/failed builds.
+//   3. Error colors, used for error messages.
+func New(owner, repo string, themes ...theme.Theme) *Routine {
 	r := new(Routine)
 
 	// Set up our client with a timeout of 30 seconds (the default client does not have a timeout).
@@ -83,26 +81,27 @@ func New(owner, repo string, colors ...[3]string) *Routine {
 	r.request, _ = http.NewRequest("GET", u.String(), nil)
 	r.request.Header.Add("Travis-API-Version", "3")
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	return r
 }
 
-// Update gets the current clone count.
+// Update gets the current build status.
 func (r *Routine) Update() (bool, error) {
+	return r.UpdateContext(context.Background())
+}
+
+// UpdateContext is like Update, but the request to Travis is cancelled if ctx is cancelled before
+// it finishes.
+func (r *Routine) UpdateContext(ctx context.Context) (bool, error) {
 	if r == nil {
 		return false, fmt.Errorf("bad routine")
 	}
 
-	build, err := r.getBuild()
+	build, err := r.getBuild(ctx)
 	if err != nil {
 		r.err = fmt.Errorf("error getting build status")
 		return true, err
@@ -119,24 +118,49 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
-	// Figure out which color and timestamp we need to use for this state.
-	var color string
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats the latest build status as a single segment, colored according to the state of
+// the build.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
+	// Figure out which color we need to use for this state.
+	var state markup.State
 	switch r.build.State {
 	case "created":
-		color = r.colors.normal
+		state = markup.StateNormal
 	case "started":
-		color = r.colors.normal
+		state = markup.StateNormal
 	case "passed":
-		color = r.colors.normal
+		state = markup.StateNormal
 	case "failed":
-		color = r.colors.warning
+		state = markup.StateWarning
 	case "canceled":
-		color = r.colors.warning
+		state = markup.StateWarning
 	default:
-		color = r.colors.error
+		state = markup.StateError
 	}
 
-	return fmt.Sprintf("%s%s: %s%s", color, r.build.Repo.Name, strings.Title(r.build.State), colorEnd)
+	text := fmt.Sprintf("%s: %s", r.build.Repo.Name, strings.Title(r.build.State))
+	return []markup.Segment{r.theme.Segment(text, state)}
+}
+
+// Values returns the name of the repository ("repo"), the state of the latest build ("state", like
+// "started", "passed", or "failed"), and whether or not the build passed ("passed").
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	return []value.Value{
+		value.NewText("repo", r.build.Repo.Name),
+		value.NewText("state", r.build.State),
+		value.NewBool("passed", r.build.State == "passed"),
+	}
 }
 
 // Error formats and returns an error message.
@@ -149,7 +173,15 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
@@ -158,13 +190,13 @@ func (r *Routine) Name() string {
 }
 
 // getBuild gets the latest build.
-func (r *Routine) getBuild() (build, error) {
+func (r *Routine) getBuild(ctx context.Context) (build, error) {
 	type Response struct {
 		Error  string  `json:"error_message"`
 		Builds []build `json:"builds"`
 	}
 
-	resp, err := r.client.Do(r.request)
+	resp, err := r.client.Do(r.request.WithContext(ctx))
 	if err != nil {
 		return build{}, err
 	}
@@ -0,0 +1,27 @@
+// This file registers the module so that it can be created by name, such as from a configuration file.
+
+package sbvolume
+
+import (
+	"github.com/snhilde/statusbar/v5/registry"
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+// Options are the settings used to create a routine by name. See New for details on each one.
+type Options struct {
+	// Mixer control to monitor.
+	Control string `config:"control"`
+}
+
+func init() {
+	registry.Register(registry.Module{
+		Name: "sbvolume",
+		Options: func() interface{} {
+			return &Options{Control: "Master"}
+		},
+		New: func(options interface{}, t theme.Theme) (registry.Routine, error) {
+			o := options.(*Options)
+			return New(o.Control, t), nil
+		},
+	})
+}
@@ -2,13 +2,20 @@
 package sbvolume
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
 )
 
-var colorEnd = "^d^"
+// clickTimeout is the longest time that toggling mute can take.
+const clickTimeout = 2 * time.Second
 
 // Routine is the main object for this package.
 type Routine struct {
@@ -24,31 +31,22 @@ type Routine struct {
 	// True if volume is muted.
 	muted bool
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
 // New stores the provided control value and makes a new routine object. control is the mixer
-// control to monitor. See the man pages for amixer for more information on that. colors is an
-// optional triplet of hex color codes for colorizing the output based on these rules:
-//   1. Normal color, for normal printing.
-//   2. Warning color, for when the volume is muted.
-//   3. Error color, for error messages.
-func New(control string, colors ...[3]string) *Routine {
+// control to monitor. See the man pages for amixer for more information on that. theme is an
+// optional theme for colorizing the output based on these rules:
+//   1. Normal colors, for normal printing.
+//   2. Warning colors, for when the volume is muted.
+//   3. Error colors, for error messages.
+func New(control string, themes ...theme.Theme) *Routine {
 	var r Routine
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	r.control = control
@@ -57,6 +55,12 @@ func New(control string, colors ...[3]string) *Routine {
 
 // Update runs the 'amixer' command and parses the output for mute status and volume percentage.
 func (r *Routine) Update() (bool, error) {
+	return r.UpdateContext(context.Background())
+}
+
+// UpdateContext is like Update, but the 'amixer' command is killed if ctx is cancelled before it
+// finishes.
+func (r *Routine) UpdateContext(ctx context.Context) (bool, error) {
 	if r == nil {
 		return false, fmt.Errorf("bad routine")
 	}
@@ -72,7 +76,7 @@ func (r *Routine) Update() (bool, error) {
 	r.muted = false
 	r.vol = -1
 
-	cmd := exec.Command("amixer", "get", r.control)
+	cmd := exec.CommandContext(ctx, "amixer", "get", r.control)
 	out, err := cmd.Output()
 	if err != nil {
 		r.err = fmt.Errorf("error getting volume")
@@ -117,11 +121,33 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats either the mute status or the volume percentage as a single segment.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
 	if r.muted {
-		return r.colors.warning + "Vol mute" + colorEnd
+		return []markup.Segment{r.theme.Segment("Vol mute", markup.StateWarning)}
+	}
+
+	return []markup.Segment{r.theme.Segment(fmt.Sprintf("Vol %v%%", r.vol), markup.StateNormal)}
+}
+
+// Values returns the volume as a percentage of the maximum, rounded to the nearest ten ("perc"), and
+// whether or not the control is muted ("muted").
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
 	}
 
-	return fmt.Sprintf("%sVol %v%%%s", r.colors.normal, r.vol, colorEnd)
+	return []value.Value{
+		value.NewNumber("perc", float64(r.vol), "%"),
+		value.NewBool("muted", r.muted),
+	}
 }
 
 // Error formats and returns an error message.
@@ -134,7 +160,36 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// Click toggles mute when the volume is clicked with the left mouse button. The 'amixer' command is
+// killed if it takes longer than two seconds.
+func (r *Routine) Click(button int) error {
+	if r == nil {
+		return fmt.Errorf("bad routine")
+	}
+
+	if button != 1 || r.control == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clickTimeout)
+	defer cancel()
+
+	if err := exec.CommandContext(ctx, "amixer", "set", r.control, "toggle").Run(); err != nil {
+		return fmt.Errorf("error toggling mute: %w", err)
+	}
+
+	return nil
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
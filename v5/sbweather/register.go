@@ -0,0 +1,36 @@
+// This file registers the module so that it can be created by name, such as from a configuration file.
+
+package sbweather
+
+import (
+	"github.com/snhilde/statusbar/v5/registry"
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+// Options are the settings used to create a routine by name. See New for details on each one.
+type Options struct {
+	// Latitude of the location.
+	Lat float32 `config:"lat,required"`
+
+	// Longitude of the location.
+	Lon float32 `config:"lon,required"`
+
+	// API key provided by OpenWeather.
+	Key string `config:"key,required"`
+
+	// Whether or not to display the temperature in celsius.
+	Metric bool `config:"metric"`
+}
+
+func init() {
+	registry.Register(registry.Module{
+		Name: "sbweather",
+		Options: func() interface{} {
+			return &Options{}
+		},
+		New: func(options interface{}, t theme.Theme) (registry.Routine, error) {
+			o := options.(*Options)
+			return New(o.Lat, o.Lon, o.Key, o.Metric, t), nil
+		},
+	})
+}
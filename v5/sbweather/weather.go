@@ -2,15 +2,18 @@
 package sbweather
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"time"
-)
 
-var colorEnd = "^d^"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
+)
 
 // noData is used to reset floats so we can tell whether or not they contain useful data.
 const noData = -1234.5678
@@ -38,12 +41,8 @@ type Routine struct {
 	// Forecast low.
 	lowTemp float32
 
-	// Trio of user-provided colors for displaying various states.
-	colors struct {
-		normal  string
-		warning string
-		error   string
-	}
+	// Theme for displaying the various states.
+	theme theme.Theme
 }
 
 // weather holds the weather data for today and the 7-day forecast.
@@ -68,12 +67,12 @@ type forecast struct {
 // New makes a new routine object with the specified latitude/longitude and formatting. key is the
 // API key provided by OpenWeather. You can get a free key here:
 // https://home.openweathermap.org/users/sign_up. The metric boolean denotes whether or not you want
-// the temperature displayed in celsius. colors is an optional triplet of hex color codes for
-// colorizing the output based on these rules:
-//   1. Normal color, used for printing the current temperature and forecast.
-//   2. Warning color, currently unused.
-//   3. Error color, used for error messages.
-func New(lat, lon float32, key string, metric bool, colors ...[3]string) *Routine {
+// the temperature displayed in celsius. theme is an optional theme for colorizing the output based
+// on these rules:
+//   1. Normal colors, used for printing the current temperature and forecast.
+//   2. Warning colors, currently unused.
+//   3. Error colors, used for error messages.
+func New(lat, lon float32, key string, metric bool, themes ...theme.Theme) *Routine {
 	r := new(Routine)
 
 	// Set up our client with a timeout of 30 seconds (the default client does not have a timeout).
@@ -109,14 +108,9 @@ func New(lat, lon float32, key string, metric bool, colors ...[3]string) *Routin
 	// Set up the request.
 	r.request, _ = http.NewRequest("GET", u.String(), nil)
 
-	// Store the color codes. Don't do any validation.
-	if len(colors) > 0 {
-		r.colors.normal = "^c" + colors[0][0] + "^"
-		r.colors.warning = "^c" + colors[0][1] + "^"
-		r.colors.error = "^c" + colors[0][2] + "^"
-	} else {
-		// If a color array wasn't passed in, then we don't want to print this.
-		colorEnd = ""
+	// Store the theme. Don't do any validation.
+	if len(themes) > 0 {
+		r.theme = themes[0]
 	}
 
 	return r
@@ -124,12 +118,18 @@ func New(lat, lon float32, key string, metric bool, colors ...[3]string) *Routin
 
 // Update gets the current hourly temperature.
 func (r *Routine) Update() (bool, error) {
+	return r.UpdateContext(context.Background())
+}
+
+// UpdateContext is like Update, but the request to OpenWeather is cancelled if ctx is cancelled
+// before it finishes.
+func (r *Routine) UpdateContext(ctx context.Context) (bool, error) {
 	if r == nil {
 		return false, fmt.Errorf("bad routine")
 	}
 
 	// Get weather data.
-	weather, err := getWeather(r.client, r.request)
+	weather, err := getWeather(r.client, r.request.WithContext(ctx))
 	if err != nil {
 		r.err = fmt.Errorf("error getting weather data")
 		return true, err
@@ -168,6 +168,15 @@ func (r *Routine) String() string {
 		return "bad routine"
 	}
 
+	return markup.Status2d.Render(r.Segments())
+}
+
+// Segments formats the current temperature and forecast as a single segment.
+func (r *Routine) Segments() []markup.Segment {
+	if r == nil {
+		return []markup.Segment{{Text: "bad routine", State: markup.StateError}}
+	}
+
 	// Grab some info on which day's forecast we're reporting.
 	day := ""
 	if onToday() {
@@ -206,8 +215,30 @@ func (r *Routine) String() string {
 		s += " now"
 	}
 
-	// Add the colors and return the entire string.
-	return r.colors.normal + s + colorEnd
+	return []markup.Segment{r.theme.Segment(s, markup.StateNormal)}
+}
+
+// Values returns the current temperature ("temp") and the forecast high ("high") and low ("low"), in
+// degrees Celsius or Fahrenheit. A high or low that wasn't in the forecast is left out.
+func (r *Routine) Values() []value.Value {
+	if r == nil {
+		return nil
+	}
+
+	unit := "°F"
+	if r.metric {
+		unit = "°C"
+	}
+
+	values := []value.Value{value.NewNumber("temp", float64(r.currTemp), unit)}
+	if r.highTemp != noData {
+		values = append(values, value.NewNumber("high", float64(r.highTemp), unit))
+	}
+	if r.lowTemp != noData {
+		values = append(values, value.NewNumber("low", float64(r.lowTemp), unit))
+	}
+
+	return values
 }
 
 // Error formats and returns an error message.
@@ -220,7 +251,25 @@ func (r *Routine) Error() string {
 		r.err = fmt.Errorf("unknown error")
 	}
 
-	return r.colors.error + r.err.Error() + colorEnd
+	segment := r.theme.Segment(r.err.Error(), markup.StateError)
+	return markup.Status2d.Render([]markup.Segment{segment})
+}
+
+// Click refreshes the weather right away. The engine runs an update after every click, so there is
+// nothing else to do here.
+func (r *Routine) Click(button int) error {
+	if r == nil {
+		return fmt.Errorf("bad routine")
+	}
+
+	return nil
+}
+
+// InheritTheme fills in any colors missing from the routine's theme with the colors from parent.
+func (r *Routine) InheritTheme(parent theme.Theme) {
+	if r != nil {
+		r.theme = r.theme.Inherit(parent)
+	}
 }
 
 // Name returns the display name of this module.
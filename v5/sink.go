@@ -0,0 +1,271 @@
+// This file holds the output sinks that the engine writes the composed statusbar to.
+
+package statusbar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Sink is a destination for the statusbar's output. Every time the engine composes a new bar, it
+// writes the complete string to each of its sinks.
+type Sink interface {
+	// Write outputs one complete frame of the statusbar. The frame does not contain a trailing
+	// newline. The returned error will be logged by the engine.
+	Write(bar string) error
+
+	// Close releases any resources held by the sink. It is called once when the statusbar stops.
+	Close() error
+}
+
+// writerSink writes each frame as a separate line to an io.Writer.
+type writerSink struct {
+	w io.Writer
+
+	// Whether or not w is stdout or stderr, which are never closed.
+	std bool
+}
+
+// NewWriterSink returns a Sink that writes each frame to w, followed by a newline.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{w: w, std: w == os.Stdout || w == os.Stderr}
+}
+
+// NewStdoutSink returns a Sink that writes each frame to stdout on its own line. This can be piped
+// into programs that read a line-based status, like lemonbar, somebar, or dwl.
+func NewStdoutSink() Sink {
+	return NewWriterSink(os.Stdout)
+}
+
+// Write writes bar to the underlying writer, followed by a newline.
+func (s *writerSink) Write(bar string) error {
+	if s == nil || s.w == nil {
+		return fmt.Errorf("invalid sink")
+	}
+
+	_, err := io.WriteString(s.w, bar+"\n")
+	return err
+}
+
+// Close closes the underlying writer if it is an io.Closer (other than stdout or stderr).
+func (s *writerSink) Close() error {
+	if s == nil || s.w == nil {
+		return nil
+	}
+
+	if s.std {
+		return nil
+	}
+
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// fifoWriteTimeout is the longest time that writing a frame to a FIFO can take before the frame is
+// dropped.
+const fifoWriteTimeout = 100 * time.Millisecond
+
+// fifoSink writes each frame to a named pipe.
+type fifoSink struct {
+	path string
+	file *os.File
+}
+
+// NewFIFOSink returns a Sink that writes each frame as a separate line to the named pipe (FIFO) at
+// path. If nothing exists at path, the FIFO is created. If no process is currently reading from
+// the FIFO, frames are discarded until a reader connects.
+func NewFIFOSink(path string) (Sink, error) {
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		if err := syscall.Mkfifo(path, 0o600); err != nil {
+			return nil, fmt.Errorf("error creating FIFO: %w", err)
+		}
+	case err != nil:
+		return nil, err
+	case info.Mode()&os.ModeNamedPipe == 0:
+		return nil, fmt.Errorf("%s is not a FIFO", path)
+	}
+
+	return &fifoSink{path: path}, nil
+}
+
+// Write writes bar to the FIFO, followed by a newline. If the reader is too slow or goes away, the
+// frame is dropped instead of blocking the engine.
+func (s *fifoSink) Write(bar string) error {
+	if s == nil {
+		return fmt.Errorf("invalid sink")
+	}
+
+	if s.file == nil {
+		// Opening a FIFO write-only in non-blocking mode fails with ENXIO if there isn't a reader
+		// on the other end. That's not an error for us; we'll just try again on the next frame.
+		f, err := os.OpenFile(s.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			if errors.Is(err, syscall.ENXIO) {
+				return nil
+			}
+			return err
+		}
+		s.file = f
+	}
+
+	// Don't let a stalled reader hold up the rest of the bar.
+	if err := s.file.SetWriteDeadline(time.Now().Add(fifoWriteTimeout)); err != nil {
+		return err
+	}
+	if _, err := s.file.WriteString(bar + "\n"); err != nil {
+		if errors.Is(err, syscall.EPIPE) {
+			// The reader closed its end. Reopen the FIFO on the next frame.
+			s.file.Close()
+			s.file = nil
+			return nil
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the FIFO if it is open. The FIFO itself is not removed.
+func (s *fifoSink) Close() error {
+	if s == nil || s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// fileSink writes each frame to a regular file.
+type fileSink struct {
+	path string
+}
+
+// NewFileSink returns a Sink that replaces the contents of the file at path with each new frame.
+// The file is replaced atomically, so readers (like a tmux status line calling cat) never see a
+// partially written frame.
+func NewFileSink(path string) Sink {
+	return &fileSink{path: path}
+}
+
+// Write replaces the file's contents with bar, followed by a newline.
+func (s *fileSink) Write(bar string) error {
+	if s == nil || s.path == "" {
+		return fmt.Errorf("invalid sink")
+	}
+
+	// Write to a temporary file in the same directory and then move it into place.
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), "."+filepath.Base(s.path)+".")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.WriteString(bar + "\n"); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Close does nothing for a file sink. The last frame is left in the file.
+func (s *fileSink) Close() error {
+	return nil
+}
+
+// sinkList holds the sinks that the engine writes to and tracks the last error from each so that a
+// failing sink doesn't flood the logs.
+type sinkList struct {
+	mutex  sync.Mutex
+	sinks  []Sink
+	errors []string
+}
+
+// add adds a sink to the list.
+func (l *sinkList) add(s Sink) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.sinks = append(l.sinks, s)
+	l.errors = append(l.errors, "")
+}
+
+// len returns the number of sinks in the list.
+func (l *sinkList) len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return len(l.sinks)
+}
+
+// write writes one frame to every sink. Sinks that implement BlockSink receive blocks, unless blocks
+// is nil, and all other sinks receive bar. Errors are logged once per failure streak.
+func (l *sinkList) write(bar string, blocks []Block) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i, s := range l.sinks {
+		var err error
+		if bs, ok := s.(BlockSink); ok && blocks != nil {
+			err = bs.WriteBlocks(blocks)
+		} else {
+			err = s.Write(bar)
+		}
+
+		switch {
+		case err == nil:
+			l.errors[i] = ""
+		case err.Error() != l.errors[i]:
+			l.errors[i] = err.Error()
+			logAt(LevelError, "Error writing to sink", "error", err)
+		}
+	}
+}
+
+// onClick sets handler as the click handler for every sink that implements ClickSink.
+func (l *sinkList) onClick(handler func(instance string, button int)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, s := range l.sinks {
+		if cs, ok := s.(ClickSink); ok {
+			cs.OnClick(handler)
+		}
+	}
+}
+
+// close closes every sink and removes them from the list.
+func (l *sinkList) close() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, s := range l.sinks {
+		if err := s.Close(); err != nil {
+			logAt(LevelError, "Error closing sink", "error", err)
+		}
+	}
+
+	l.sinks = nil
+	l.errors = nil
+}
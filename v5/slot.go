@@ -0,0 +1,240 @@
+// This file holds the slots that let several routines take turns in one place on the bar.
+
+package statusbar
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/snhilde/statusbar/v5/markup"
+)
+
+// defaultSlotPeriod is how long each member of a slot is shown if the slot doesn't set its own period.
+const defaultSlotPeriod = 5 * time.Second
+
+// Slot is a place on the bar that several routines share. Routines join a slot with WithSlot, and the
+// slot is displayed where its first member would be. Only one member is shown at a time, and the slot
+// moves on to the next member every period. Members keep updating on their own intervals while they
+// aren't shown, and members without any output to display (including members hidden by their
+// visibility rules) are skipped.
+type Slot struct {
+	// Name of the slot, as passed to WithSlot.
+	Name string
+
+	// How long each member is shown before the slot moves on to the next one. If this is 0, each
+	// member is shown for 5 seconds.
+	Period time.Duration
+
+	// Whether or not members with output in the warning or error state stay shown until their
+	// output goes back to normal. If more than one member needs attention, the first member in the
+	// error state is shown, or else the first member in the warning state.
+	Pin bool
+}
+
+// slot is a Slot with the member that it is showing.
+type slot struct {
+	Slot
+
+	// The slot moves on while the bar is drawn, which only holds the statusbar's read lock, so the
+	// fields below have their own mutex.
+	mutex sync.Mutex
+
+	// Member that is shown, or nil if the slot hasn't shown anything yet.
+	member *routine
+
+	// Time that the member's turn started.
+	start time.Time
+}
+
+// WithSlot places the routine in the slot with this name (see AddSlot). If no slot has this name, the
+// routine is displayed on its own.
+func WithSlot(name string) RoutineOption {
+	return func(r *routine) {
+		r.setSlot(name)
+	}
+}
+
+// AddSlot adds a slot that routines can share (see WithSlot).
+func (sb *Statusbar) AddSlot(s Slot) error {
+	if sb == nil {
+		return fmt.Errorf("invalid statusbar")
+	}
+
+	if s.Name == "" {
+		return fmt.Errorf("missing slot name")
+	}
+	if s.Period < 0 {
+		return fmt.Errorf("slot %s: period cannot be negative", s.Name)
+	}
+	if s.Period == 0 {
+		s.Period = defaultSlotPeriod
+	}
+
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	if sb.findSlot(s.Name) != nil {
+		return fmt.Errorf("slot %s already exists", s.Name)
+	}
+	sb.slots = append(sb.slots, &slot{Slot: s, start: sb.now()})
+	sb.requestRedraw()
+
+	return nil
+}
+
+// findSlot returns the slot with this name, or nil if there isn't one. The statusbar's mutex must be
+// held.
+func (sb *Statusbar) findSlot(name string) *slot {
+	for _, s := range sb.slots {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// members returns the routines in the slot, in the order that they were added to the bar. The
+// statusbar's mutex must be held.
+func (sb *Statusbar) members(s *slot) []*routine {
+	var members []*routine
+	for _, r := range sb.routines {
+		if r.slotName() == s.Name {
+			members = append(members, r)
+		}
+	}
+
+	return members
+}
+
+// current returns the member of the slot that is shown right now and whether or not it is pinned. If
+// no member has anything to display, this returns nil. The statusbar's mutex must be held.
+func (sb *Statusbar) current(s *slot, now time.Time) (*routine, bool) {
+	var shown []*routine
+	var warning, failing *routine
+	for _, r := range sb.members(s) {
+		o := r.output()
+		if len(markup.Text(o.segments)) == 0 || r.isHidden(o) {
+			continue
+		}
+		shown = append(shown, r)
+
+		switch {
+		case o.failed || markup.WorstState(o.segments) == markup.StateError:
+			if failing == nil {
+				failing = r
+			}
+		case markup.WorstState(o.segments) == markup.StateWarning:
+			if warning == nil {
+				warning = r
+			}
+		}
+	}
+
+	switch {
+	case len(shown) == 0:
+		return nil, false
+	case s.Pin && failing != nil:
+		return failing, true
+	case s.Pin && warning != nil:
+		return warning, true
+	}
+
+	return s.turn(sb.members(s), shown, now), false
+}
+
+// turn returns the member whose turn it is, out of the members in shown. The slot stays on its
+// member until the period ends, and then moves on to the next member after it that has something to
+// display, so members that appear or disappear elsewhere in the slot don't change what is shown. If
+// the member itself has nothing to display anymore, the next member takes its turn right away.
+func (s *slot) turn(members []*routine, shown []*routine, now time.Time) *routine {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Count the members with something to display that come before the current member.
+	i, found := 0, false
+	for _, r := range members {
+		if r == s.member {
+			found = true
+			break
+		}
+		if i < len(shown) && shown[i] == r {
+			i++
+		}
+	}
+
+	switch {
+	case found && i < len(shown) && shown[i] == s.member:
+		// The member is still shown.
+	case found:
+		// The member has nothing to display, so the next one starts its turn now.
+		s.start = now
+	case s.member != nil:
+		// The member left the slot, so start over from the beginning.
+		i, s.start = 0, now
+	default:
+		// The slot hasn't shown anything yet, so its first turn started when it was added.
+		i = 0
+	}
+
+	if turns := now.Sub(s.start) / s.Period; turns > 0 {
+		i += int(turns)
+		s.start = s.start.Add(turns * s.Period)
+	}
+	s.member = shown[i%len(shown)]
+
+	return s.member
+}
+
+// rotate replaces the members of each slot with the member that is shown right now, in the place of
+// the slot's first member. The statusbar's mutex must be held.
+func (sb *Statusbar) rotate(groups [][]*routine) [][]*routine {
+	if len(sb.slots) == 0 {
+		return groups
+	}
+
+	now := sb.now()
+	placed := make(map[*slot]bool)
+	rotated := make([][]*routine, len(groups))
+	for i, group := range groups {
+		for _, r := range group {
+			s := sb.findSlot(r.slotName())
+			if s == nil {
+				rotated[i] = append(rotated[i], r)
+				continue
+			}
+			if placed[s] {
+				continue
+			}
+			placed[s] = true
+
+			if member, _ := sb.current(s, now); member != nil {
+				rotated[i] = append(rotated[i], member)
+			}
+		}
+	}
+
+	return rotated
+}
+
+// nextRotation returns the next time that any slot moves on to its next member, or the zero time if
+// there aren't any slots.
+func (sb *Statusbar) nextRotation() time.Time {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	var next time.Time
+	now := sb.now()
+	for _, s := range sb.slots {
+		s.mutex.Lock()
+		turn := now.Sub(s.start)/s.Period + 1
+		t := s.start.Add(turn * s.Period)
+		s.mutex.Unlock()
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+
+	return next
+}
@@ -1,24 +1,24 @@
 // Package statusbar formats and displays information on the dwm statusbar by managing modular data routines.
 package statusbar
 
-// #cgo pkg-config: x11
-// #cgo LDFLAGS: -lX11
-// #include <stdlib.h>
-// #include <X11/Xlib.h>
-import "C"
-
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
-	"unsafe"
 
 	"github.com/snhilde/statusbar/v5/apispecs"
+	"github.com/snhilde/statusbar/v5/markup"
 	"github.com/snhilde/statusbar/v5/restapi"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
 )
 
 // RoutineHandler allows information monitors (commonly called routines) to be linked in.
@@ -47,6 +47,78 @@ type RoutineHandler interface {
 	Name() string
 }
 
+// ContextUpdater is an optional interface for routines whose updates can block, for example by running a command or
+// making a network request. If a routine implements this interface, the engine calls UpdateContext instead of Update.
+// The context is cancelled when the update takes longer than the routine's timeout (see WithTimeout) or when the
+// routine is stopped. UpdateContext returns the same values as Update.
+type ContextUpdater interface {
+	UpdateContext(ctx context.Context) (bool, error)
+}
+
+// Segmenter is an optional interface for routines that return their output as structured segments
+// instead of a preformatted string. If a routine implements this interface, the engine calls
+// Segments instead of String after a successful update and renders the segments with the
+// statusbar's markup (see SetMarkup). String is still used by anything that needs a plain string.
+type Segmenter interface {
+	// Segments returns the routine's output, broken into segments of text with their own colors
+	// and states.
+	Segments() []markup.Segment
+}
+
+// Clicker is an optional interface for routines that respond to mouse clicks on their output. Clicks
+// come from sinks that implement ClickSink or, with dwm's statuscmd patch, from signals (see
+// EnableStatusCmd).
+type Clicker interface {
+	// Click handles a click on the routine's output. button is the mouse button that was pressed
+	// (1 for left, 2 for middle, 3 for right, 4 and 5 for scrolling up and down). After Click
+	// returns, the engine runs the routine's Update method so the new state is displayed right
+	// away.
+	Click(button int) error
+}
+
+// Themer is an optional interface for routines that color their output with a theme. Before the
+// routines are started, the engine calls InheritTheme with the statusbar's theme (see SetTheme) so
+// that the routine can use it for any colors that it wasn't given itself.
+type Themer interface {
+	// InheritTheme fills in any colors missing from the routine's theme with the colors from
+	// parent.
+	InheritTheme(parent theme.Theme)
+}
+
+// Notifier is an optional interface for routines that learn about changes on their own instead of (or
+// in addition to) polling for them on an interval, for example by watching for events. Before the
+// routine is started, the engine calls SetNotify with a function that the routine can call from any
+// goroutine whenever something changes. Calling it runs the routine's Update method right away, and
+// the bar is redrawn as soon as the new output is ready.
+type Notifier interface {
+	SetNotify(notify func())
+}
+
+// Valuer is an optional interface for routines that report their readings as typed values, such as
+// a percentage or a temperature, in addition to their formatted output. The engine collects the
+// values after every successful update, and visibility rules are checked against them (see
+// WithVisibility). The values are also recorded in the routine's history (see WithHistory), exported
+// as metrics (see EnableMetrics), and served through the REST API.
+type Valuer interface {
+	// Values returns the routine's current values. Each value should have a different name.
+	Values() []value.Value
+}
+
+// Historian is an optional interface for Valuer routines that draw their own recent values, such as
+// with the sparklines in the graph package, so that they don't need to keep a copy of the history
+// that the engine already records. Before the routine is started, the engine calls SetHistory with a
+// function that returns the samples of one of the routine's values from its history (see
+// WithHistory). The history keeps at least HistoryDepth samples, even if WithHistory asked for fewer.
+type Historian interface {
+	// HistoryDepth returns the number of samples that the routine draws.
+	HistoryDepth() int
+
+	// SetHistory sets the function that returns the samples of the value with the key (see
+	// value.Value.Key) from the routine's history, from oldest to newest. After an update, the
+	// samples already include the values from that update. Samples without the value are skipped.
+	SetHistory(samples func(key string) []float64)
+}
+
 // Statusbar is the main type for this package. It holds information about the bar as a whole.
 type Statusbar struct {
 	// List of routines, in the order they were added.
@@ -61,6 +133,12 @@ type Statusbar struct {
 	// Index of the routine after which the routines are split, as set with Split.
 	split int
 
+	// Named regions that the routines are divided into, as added with AddRegion.
+	regions []Region
+
+	// Slots that routines take turns in, as added with AddSlot.
+	slots []*slot
+
 	// Timer that is started when the statusbar is started. This is used to measure the statusbar's uptime.
 	startTime time.Time
 
@@ -70,27 +148,256 @@ type Statusbar struct {
 	// REST API engine.
 	restEngine *restapi.Engine
 
+	// Clock that the statusbar and its routines read the time from, as set with SetClock.
+	clock Clock
+
+	// Where the REST API logs its requests, as set with SetRequestLog. If this is nil, requests aren't logged.
+	requestLog io.Writer
+
+	// Whether or not metrics are served on the REST API's port, as set with EnableMetrics.
+	metrics bool
+
+	// Whether or not clicks are read from the signals sent by dwm's statuscmd patch, as set with EnableStatusCmd.
+	statusCmd bool
+
 	// Whether or not the engine is currently running. This is toggled on and off by calls to Run and Stop.
 	running bool
+
+	// Sinks that the composed bar is written to, as added with AddSink.
+	sinks *sinkList
+
+	// Notifiers that alerts are sent to, as added with AddAlertNotifier.
+	notifiers []AlertNotifier
+
+	// Markup used to render each routine's output, as set with SetMarkup.
+	markup markup.Markup
+
+	// Default theme for all routines, as set with SetTheme.
+	theme theme.Theme
+
+	// Guards the routines and the settings above, which can be swapped out by Reload while the bar is running.
+	mutex sync.RWMutex
+
+	// Function that builds the statusbar to reload into when the program receives SIGHUP, as set with SetReloader.
+	reloader func() (*Statusbar, error)
+
+	// Channel that every routine sends itself on when it stops.
+	finished chan *routine
+
+	// Number of routines that have been started and have not stopped yet.
+	live int
+
+	// Number of routines that have stopped and are waiting to be restarted by the supervisor.
+	restarting int
+
+	// Channel that wakes up Run to check whether or not it still has any routines to wait for.
+	wake chan struct{}
+
+	// Channel that is signaled whenever the bar needs to be redrawn. Signals that arrive while a redraw is
+	// already pending are merged into that redraw.
+	redraw chan struct{}
+
+	// Channel that is closed when the statusbar stops.
+	done chan struct{}
 }
 
-var (
-	// These are used for printing onto dwm's statusbar.
-	dpy  = C.XOpenDisplay(nil)
-	root = C.XDefaultRootWindow(dpy)
-)
+// redrawDelay is how long the engine waits after a redraw is requested before it redraws the bar. Any
+// other changes that come in during this time, such as from other routines that update at the same
+// moment, are drawn together in one frame.
+const redrawDelay = 20 * time.Millisecond
 
 // New creates a new statusbar. The default delimiters around each routine are square brackets ('['
 // and ']'), which can be changed with SetMarkers.
 func New() Statusbar {
-	return Statusbar{leftDelim: "[", rightDelim: "]", split: -1}
+	return Statusbar{
+		leftDelim:  "[",
+		rightDelim: "]",
+		split:      -1,
+		sinks:      new(sinkList),
+		requestLog: os.Stdout,
+		clock:      systemClock{},
+		markup:     markup.Status2d,
+		redraw:     make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// RoutineOption configures a single routine. Options are passed to Append.
+type RoutineOption func(*routine)
+
+// WithTimeout sets the longest that a single update of the routine is allowed to run. If an update
+// takes longer than this, it is reported as an error, and the routine tries again after a cool-down
+// period. Routines that implement ContextUpdater have their context cancelled at that point. A
+// timeout of 0 lets updates run indefinitely. The default timeout is 30 seconds.
+func WithTimeout(timeout time.Duration) RoutineOption {
+	return func(r *routine) {
+		r.setTimeout(timeout)
+	}
+}
+
+// WithWidth sets the most columns that the routine's output can take up on the bar. Longer output is
+// shortened, and part of it is replaced with "..." at the position set by ellipsis. Columns are
+// counted the way a terminal would display them (wide characters take up two), and any markup is
+// left intact. A width of 0 or less lets the output be any length. By default, routines are limited
+// to DefaultWidth columns, with the ellipsis at the end.
+func WithWidth(columns int, ellipsis markup.Ellipsis) RoutineOption {
+	return func(r *routine) {
+		r.setWidthLimit(columns, ellipsis)
+	}
+}
+
+// WithFingerprint identifies the routine's settings, for example by joining together the arguments that the routine
+// was created with. When the statusbar is reloaded (see Reload), a routine that has the same module and fingerprint
+// as a routine that is already running is not started. Instead, the running routine is kept, along with all of its
+// state. Routines without a fingerprint are always replaced on a reload.
+func WithFingerprint(fingerprint string) RoutineOption {
+	return func(r *routine) {
+		r.fingerprint = fingerprint
+	}
 }
 
 // Append adds a routine to the statusbar's internal list of routines. Routines are displayed in
 // the order they are added. handler is the RoutineHandler module. seconds is the amount of time
-// between each run of the routine.
-func (sb *Statusbar) Append(handler RoutineHandler, seconds int) {
+// between each run of the routine. options are any additional settings for this routine. If the
+// statusbar is already running, the routine is started right away.
+func (sb *Statusbar) Append(handler RoutineHandler, seconds int, options ...RoutineOption) {
+	r := sb.buildRoutine(handler, seconds, options...)
+
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	sb.insert(len(sb.routines), r)
+}
+
+// Insert adds a routine at index in the list of routines, moving the routine that was at index and
+// every routine after it one place to the right. An index equal to the number of routines adds the
+// routine to the end, like Append. The arguments are otherwise the same as for Append. If the
+// statusbar is already running, the routine is started right away.
+func (sb *Statusbar) Insert(index int, handler RoutineHandler, seconds int, options ...RoutineOption) error {
+	r := sb.buildRoutine(handler, seconds, options...)
+
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	if index < 0 || index > len(sb.routines) {
+		return fmt.Errorf("invalid index %d", index)
+	}
+	sb.insert(index, r)
+
+	return nil
+}
+
+// Remove stops the routine at index and removes it from the statusbar.
+func (sb *Statusbar) Remove(index int) error {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	if index < 0 || index >= len(sb.routines) {
+		return fmt.Errorf("invalid index %d", index)
+	}
+
+	r := sb.routines[index]
+	sb.routines = append(sb.routines[:index:index], sb.routines[index+1:]...)
+	if index <= sb.split {
+		sb.split--
+	}
+	go func() {
+		if !r.stop(5) {
+			r.log(LevelError, "Failed to stop routine")
+		}
+	}()
+	sb.requestRedraw()
+
+	return nil
+}
+
+// Move moves the routine at index from to index to. The routines in between shift over by one
+// place to make room. The split (see Split) stays between the same two places on the bar, so a
+// routine that is moved across it changes sides.
+func (sb *Statusbar) Move(from int, to int) error {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	if from < 0 || from >= len(sb.routines) {
+		return fmt.Errorf("invalid index %d", from)
+	}
+	if to < 0 || to >= len(sb.routines) {
+		return fmt.Errorf("invalid index %d", to)
+	}
+
+	r := sb.routines[from]
+	routines := append(sb.routines[:from:from], sb.routines[from+1:]...)
+	routines = append(routines[:to:to], append([]*routine{r}, routines[to:]...)...)
+	sb.routines = routines
+	sb.requestRedraw()
+
+	return nil
+}
+
+// Routines returns the module name of each routine, in the order that they are displayed. The
+// index of a routine in this list is the index used by Insert, Remove, Move, and SetSplit.
+func (sb *Statusbar) Routines() []string {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	names := make([]string, len(sb.routines))
+	for i, r := range sb.routines {
+		names[i] = r.moduleName()
+	}
+
+	return names
+}
+
+// UpdateRoutine runs one update of the routine at index and waits for it to finish. The routine's
+// output is stored, its values are added to its history, and its alert rules are checked, just like
+// they are after each update of a running routine. It returns the error from the update. This is for
+// driving a statusbar by hand, such as in tests (see the statusbartest package), so it can't be used
+// while the statusbar is running.
+func (sb *Statusbar) UpdateRoutine(index int) error {
+	if sb == nil {
+		return fmt.Errorf("invalid statusbar")
+	}
+
+	sb.mutex.Lock()
+	if sb.running {
+		sb.mutex.Unlock()
+		return fmt.Errorf("statusbar is running")
+	}
+	if index < 0 || index >= len(sb.routines) {
+		sb.mutex.Unlock()
+		return fmt.Errorf("invalid index %d", index)
+	}
+	r := sb.routines[index]
+	sb.attach(r)
+	sb.mutex.Unlock()
+
+	result, _ := r.step()
+	return result.err
+}
+
+// Draw composes the bar from the latest output of every routine, writes it to every sink, and
+// returns it. A running statusbar draws itself whenever its output changes, so this is only needed
+// when driving a statusbar by hand with UpdateRoutine.
+func (sb *Statusbar) Draw() string {
+	if sb == nil {
+		return ""
+	}
+
+	s, blocks := sb.compose()
+	sb.sinks.write(s, blocks)
+
+	return s
+}
+
+// buildRoutine creates a routine for handler with the settings passed to Append.
+func (sb *Statusbar) buildRoutine(handler RoutineHandler, seconds int, options ...RoutineOption) *routine {
+	sb.mutex.RLock()
+	clock := sb.clock
+	sb.mutex.RUnlock()
+
 	r := newRoutine()
+	r.clock = clock
 	r.setHandler(handler)
 	r.setInterval(seconds)
 
@@ -105,55 +412,90 @@ func (sb *Statusbar) Append(handler RoutineHandler, seconds int) {
 		if refType == "" {
 			refType = "unknown"
 		}
-		log.Printf("Failed to determine package name (%s)", refType)
+		logAt(LevelWarn, "Failed to determine package name", "type", refType)
 	}
 
-	sb.routines = append(sb.routines, r)
+	// Apply the options once the routine has its name so that anything they log is labeled.
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// insert adds r at index in the list of routines and starts it if the statusbar is running. The
+// statusbar's mutex must be held.
+func (sb *Statusbar) insert(index int, r *routine) {
+	sb.routines = append(sb.routines[:index:index], append([]*routine{r}, sb.routines[index:]...)...)
+	if index <= sb.split {
+		sb.split++
+	}
+
+	// If we're not running yet, then Run will start the routine.
+	if sb.finished != nil {
+		sb.startRoutine(r)
+	}
+	sb.requestRedraw()
 }
 
 // Run spins up all the routines and displays them on the statusbar. If the APIs are enabled, this
 // also runs the API engines.
 func (sb *Statusbar) Run() {
 	// Start the uptime clock.
-	sb.startTime = time.Now()
+	sb.startTime = sb.now()
+
+	// If no sinks were added, then we'll print to the X root window like dwm expects (or to stdout
+	// if there isn't a display available).
+	if sb.sinks.len() == 0 {
+		sb.AddSink(defaultSink())
+	}
 
 	// Add a signal handler so we can clear the statusbar if the program goes down.
 	go sb.handleSignal()
 
-	// Slice of strings to hold the output from each routine
-	outputs := make([]string, len(sb.routines))
-
-	// Shared channel used to pass the slice of outputs
-	outputsChan := make(chan []string, 1)
-	outputsChan <- outputs
-
-	// Set up a channel used to indicate everything is done. This must have a buffer large enough
-	// for every channel to send on without blocking.
-	finished := make(chan *routine, len(sb.routines))
+	// Route clicks from any sinks that report them back to the routines.
+	sb.sinks.onClick(sb.handleClick)
 
-	// Run each routine.
-	for i, v := range sb.routines {
-		go v.run(i, outputsChan, finished)
+	// Route clicks from dwm's statuscmd patch to the routines.
+	if sb.statusCmd {
+		if err := listenStatusCmd(maxStatusCmd, sb.handleStatusCmd); err != nil {
+			logAt(LevelError, "Failed to listen for click signals", "error", err)
+		}
 	}
 
-	// Flag that we're running now.
+	// Run each routine, and flag that we're running now.
+	sb.mutex.Lock()
+	sb.finished = make(chan *routine)
+	for _, r := range sb.routines {
+		sb.startRoutine(r)
+	}
 	sb.running = true
+	done := sb.done
+	sb.mutex.Unlock()
 
-	// Launch a goroutine to build and print the master string.
-	go sb.buildBar(outputsChan)
+	// Launch a goroutine to build and print the master string, and draw the first frame.
+	go sb.buildBar()
+	sb.requestRedraw()
 
 	// If enabled, build and run the APIs in their own goroutine.
 	go sb.runAPIs()
 
-	// Keep running until every routine stops.
-	for range sb.routines {
-		r := <-finished
-		log.Printf("%v: Routine stopped", r.displayName())
+	// Keep running until every routine stops for good, including any routines started by a reload.
+	// If the REST API is running, then we'll keep going so that routines can be started from there.
+	for sb.waiting() {
+		select {
+		case r := <-sb.finished:
+			sb.supervise(r)
+		case <-sb.wake:
+		case <-done:
+			// Don't wake up here again while the routines are stopping.
+			done = nil
+		}
 	}
-	log.Printf("All routines have stopped")
+	logAt(LevelInfo, "All routines have stopped")
 
 	// Exit cleanly.
-	if sb.running {
+	if sb.isRunning() {
 		sb.Stop()
 	}
 }
@@ -164,17 +506,24 @@ func (sb *Statusbar) Stop() {
 		return
 	}
 
+	// Stop redrawing the bar.
+	sb.mutex.Lock()
 	sb.running = false
+	if sb.done != nil {
+		close(sb.done)
+		sb.done = nil
+	}
+	sb.mutex.Unlock()
 
 	// Shut down the API engine(s) (if running).
 	sb.stopAPIs()
 
 	// Stop all running routines.
-	for _, r := range sb.routines {
+	for _, r := range sb.routineList() {
 		// Make sure the anonymous function closes over the correct routine.
 		go func(r *routine) {
 			if !r.stop(5) {
-				log.Printf("Failed to stop %s", r.moduleName())
+				r.log(LevelError, "Failed to stop routine")
 			}
 		}(r)
 	}
@@ -182,22 +531,115 @@ func (sb *Statusbar) Stop() {
 	// Give each routine up to 5 seconds to shut down. If they can't close down in that time, then
 	// we'll forcibly quit the program.
 	time.AfterFunc(5*time.Second, func() {
-		log.Printf("Forcibly exiting statusbar")
+		logAt(LevelWarn, "Forcibly exiting statusbar")
 		pid := os.Getpid()
 		p, err := os.FindProcess(pid)
 		if err == nil {
 			p.Kill()
 		} else {
-			log.Printf("Failed to exit")
+			logAt(LevelError, "Failed to exit")
 		}
 	})
 
-	setBar("Statusbar stopped")
+	sb.setBar("Statusbar stopped")
+	sb.sinks.close()
+}
+
+// Reload switches a running statusbar over to the routines and settings of next, which should be a new statusbar that
+// has not been run. Every routine in next that matches a running routine (see WithFingerprint) is replaced by the
+// running routine, which keeps its state and only takes on the new interval, timeout, retry policy, restart policy,
+// width, marquee, history, region, visibility rules, alert rules, and slot. The other routines in next are started, and
+// any running routines that aren't kept are stopped. The markers, split, regions, slots, markup, and theme are also
+// taken from next. The bar switches over all at once, so no frame ever shows a mix of the old and new settings. Sinks,
+// alert notifiers, click signals, and the REST API are set up once by Run and are not changed by a reload.
+func (sb *Statusbar) Reload(next *Statusbar) {
+	if sb == nil || next == nil {
+		return
+	}
+
+	next.mutex.Lock()
+	defer next.mutex.Unlock()
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	// Match up the new routines with the old ones. Each old routine can only stand in for one new routine.
+	kept := make(map[*routine]bool)
+	routines := make([]*routine, len(next.routines))
+	for i, r := range next.routines {
+		routines[i] = r
+		for _, old := range sb.routines {
+			if !kept[old] && old.isActive() && old.matches(r) {
+				old.setInterval(r.interval())
+				old.setTimeout(r.timeoutDuration())
+				old.setRetryPolicy(r.retry)
+				old.setRestartPolicy(r.restartPolicy())
+				old.setWidthLimit(r.widthLimit())
+				old.setRegion(r.regionName())
+				old.setVisibility(r.visibility)
+				old.setAlerts(r.alerts)
+				old.setSlot(r.slotName())
+				old.setMarquee(r.marquee)
+				old.setHistory(r.historySettings())
+				routines[i] = old
+				kept[old] = true
+				break
+			}
+		}
+	}
+
+	// Stop the routines that are going away.
+	for _, old := range sb.routines {
+		if !kept[old] {
+			go func(r *routine) {
+				if !r.stop(5) {
+					r.log(LevelError, "Failed to stop routine")
+				}
+			}(old)
+		}
+	}
+
+	sb.routines = routines
+	sb.leftDelim, sb.rightDelim = next.leftDelim, next.rightDelim
+	sb.split = next.split
+	sb.regions = next.regions
+	sb.slots = next.slots
+	sb.markup = next.markup
+	sb.theme = next.theme
+
+	// Pass the new theme down to the routines that we kept. If we're not running yet, then Run will
+	// attach every routine anyway.
+	for old := range kept {
+		old.inheritTheme(sb.theme)
+	}
+
+	// Start the new routines. If we're not running yet, then Run will start them.
+	if sb.finished != nil {
+		for _, r := range routines {
+			if !kept[r] {
+				sb.startRoutine(r)
+			}
+		}
+	}
+
+	sb.requestRedraw()
+	logAt(LevelInfo, "Reloaded statusbar", "kept", len(kept), "started", len(routines)-len(kept))
+}
+
+// SetReloader sets the function that is called to build a new statusbar when the program receives SIGHUP. The new
+// statusbar is then loaded with Reload. If the function returns an error, the error is logged, and the statusbar
+// keeps running as is. This must be called before Run. If no reloader is set, SIGHUP is not handled.
+func (sb *Statusbar) SetReloader(reloader func() (*Statusbar, error)) {
+	if sb != nil {
+		sb.reloader = reloader
+	}
 }
 
 // SetMarkers sets the left and right delimiters around each routine. If not set, they default to
 // '[' and ']'.
 func (sb *Statusbar) SetMarkers(left string, right string) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
 	sb.leftDelim = left
 	sb.rightDelim = right
 }
@@ -206,14 +648,68 @@ func (sb *Statusbar) SetMarkers(left string, right string) {
 // semicolon (';') is inserted at this point in the routine list, which signals to dualstatus to
 // split the statusbar at this point. Before this is called, the routines already added are
 // displayed on the main bar. After this is called, all subsequently added routines are displayed on
-// the secondary bar.
+// the secondary bar. The split is not used if any regions were added (see AddRegion).
 func (sb *Statusbar) Split() {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
 	sb.split = len(sb.routines) - 1
+	sb.requestRedraw()
+}
+
+// SetSplit moves the split (see Split) to just after the routine at index. The routines up to and
+// including index are displayed on the main bar, and the rest are displayed on the secondary bar.
+// An index of -1 removes the split.
+func (sb *Statusbar) SetSplit(index int) error {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	if index < -1 || index >= len(sb.routines) {
+		return fmt.Errorf("invalid index %d", index)
+	}
+	sb.split = index
+	sb.requestRedraw()
+
+	return nil
+}
+
+// SetMarkup sets the markup used to render the output of every routine. This should match the
+// program that displays the bar, for example markup.Pango for somebar or markup.Lemonbar for
+// lemonbar. The default is markup.Status2d, as used by dwm's status2d patch. Routines that don't
+// implement Segmenter are assumed to format their output for status2d, and their colors are
+// converted to the new markup.
+func (sb *Statusbar) SetMarkup(m markup.Markup) {
+	if sb != nil && m != nil {
+		sb.mutex.Lock()
+		defer sb.mutex.Unlock()
+		sb.markup = m
+	}
+}
+
+// SetTheme sets the default theme for every routine. Routines that implement Themer use these colors
+// for any colors that are missing from their own theme. The theme is also used to color any segments
+// of output that don't have colors of their own, like the message shown when a routine times out.
+// This must be called before Run.
+func (sb *Statusbar) SetTheme(t theme.Theme) {
+	if sb != nil {
+		sb.mutex.Lock()
+		defer sb.mutex.Unlock()
+		sb.theme = t
+	}
+}
+
+// AddSink adds a destination for the statusbar's output. Every composed bar is written to all
+// sinks. If no sinks are added before Run is called, the bar is printed to the X root window for
+// dwm (see NewXSink), or to stdout if no display is available.
+func (sb *Statusbar) AddSink(s Sink) {
+	if sb != nil && s != nil {
+		sb.sinks.add(s)
+	}
 }
 
 // Uptime returns the time in seconds denoting how long the statusbar has been running.
 func (sb *Statusbar) Uptime() int {
-	t := time.Since(sb.startTime)
+	t := sb.now().Sub(sb.startTime)
 	return int(t.Seconds())
 }
 
@@ -223,92 +719,312 @@ func (sb *Statusbar) EnableRESTAPI(port int) {
 	sb.restPort = port
 }
 
-// buildBar builds the master output and prints it to the statusbar. This runs a loop twice a second
-// to catch any changes that run every second (the minimum time).
-func (sb *Statusbar) buildBar(outputsChan chan []string) {
-	for sb.running {
-		// Start the clock.
-		start := time.Now()
-		b := new(strings.Builder)
-
-		// Receive the outputs slice and build the individual outputs into a master output.
-		outputs := <-outputsChan
-		for i, s := range outputs {
-			if len(s) > 0 {
-				b.WriteString(sb.leftDelim)
-
-				// Shorten outputs that are longer than 60 characters.
-				if len(s) > 60 {
-					// If the output ends with the color terminator, then we need to make sure to
-					// keep that so the color doesn't bleed onto the delimiter and beyond.
-					hasColor := strings.HasSuffix(s, "^d^")
-					s = s[:56] + "..."
-					if hasColor {
-						s += "^d^"
-					}
-				}
-				b.WriteString(s)
+// SetRequestLog sets where the REST API logs the requests that it handles. By default, requests are
+// logged to stdout, which gets in the way of sinks that also write to stdout, like NewStdoutSink. A
+// nil writer turns request logging off. This must be called before Run.
+func (sb *Statusbar) SetRequestLog(w io.Writer) {
+	if sb != nil {
+		sb.requestLog = w
+	}
+}
 
-				b.WriteString(sb.rightDelim)
-				b.WriteByte(' ')
-			}
+// buildBar builds the master output and prints it to the statusbar whenever a redraw is requested,
+// which happens when a routine's output changes, and whenever a slot moves on to its next member.
+// Requests that come in close together are drawn once, and nothing is written if the bar looks the
+// same as the last time it was drawn.
+func (sb *Statusbar) buildBar() {
+	sb.mutex.RLock()
+	done := sb.done
+	sb.mutex.RUnlock()
 
-			if i == sb.split {
-				// Insert the breaking delimiter here.
-				b.WriteByte(';')
-			}
+	var lastBar string
+	var lastBlocks []Block
+	for {
+		// Wake up when the bar changes on its own, like when a slot rotates or output scrolls. The next
+		// frame is on the statusbar's clock, so the wait is measured against that clock too.
+		var rotate <-chan time.Time
+		var timer *time.Timer
+		if next := sb.nextFrame(); !next.IsZero() {
+			timer = time.NewTimer(next.Sub(sb.now()))
+			rotate = timer.C
+		}
+
+		select {
+		case <-sb.redraw:
+		case <-rotate:
+		case <-done:
+			return
+		}
+		if timer != nil {
+			timer.Stop()
 		}
-		outputsChan <- outputs
 
-		s := "No output" // Default if nothing else is available
-		if b.Len() > 0 {
-			s = b.String()
-			s = s[:b.Len()-1] // Remove last space.
+		// Wait a moment for any other changes to come in, and then merge them into this redraw.
+		time.Sleep(redrawDelay)
+		select {
+		case <-sb.redraw:
+		default:
 		}
 
 		// Send the master output to the statusbar.
-		setBar(s)
+		s, blocks := sb.compose()
+		if s == lastBar && reflect.DeepEqual(blocks, lastBlocks) {
+			continue
+		}
+		sb.sinks.write(s, blocks)
+		lastBar, lastBlocks = s, blocks
+	}
+}
+
+// nextFrame returns the next time that the bar changes without any new output from the routines, or
+// the zero time if it doesn't.
+func (sb *Statusbar) nextFrame() time.Time {
+	next := sb.nextRotation()
+	if scroll := sb.nextScroll(); !scroll.IsZero() && (next.IsZero() || scroll.Before(next)) {
+		next = scroll
+	}
+
+	return next
+}
+
+// requestRedraw asks for the bar to be redrawn. This never blocks.
+func (sb *Statusbar) requestRedraw() {
+	select {
+	case sb.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// compose builds the master output from the latest output of every routine, region by region. It also
+// returns the output of each routine as a separate block, for sinks that display them separately.
+func (sb *Statusbar) compose() (string, []Block) {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	regions, groups := sb.layout()
+	groups = sb.rotate(groups)
+	rendered := make([]markup.Region, len(regions))
+	blocks := make([]Block, 0, len(sb.routines))
+	for i, region := range regions {
+		var regionBlocks []Block
+		rendered[i], regionBlocks = sb.renderRegion(region, groups[i])
+		blocks = append(blocks, regionBlocks...)
+	}
+
+	if len(blocks) == 0 {
+		return "No output", blocks
+	}
+
+	return markup.JoinRegions(sb.markup, rendered), blocks
+}
 
-		// Put the routine to sleep for the rest of the half second.
-		time.Sleep((time.Second / 2) - time.Since(start))
+// renderRoutine renders the latest output of r with the statusbar's markup, without any markers. It
+// also returns the output as a block. If the routine has nothing to display, ok is false. The
+// statusbar's mutex must be held.
+func (sb *Statusbar) renderRoutine(r *routine) (text string, block Block, ok bool) {
+	output := r.output()
+	if r.isHidden(output) {
+		return "", Block{}, false
 	}
+
+	// Color any segments that the routine didn't color itself.
+	if output.text == "" {
+		output.segments = sb.theme.Apply(output.segments)
+	}
+
+	// Scroll the output through the routine's marquee, or shorten it to the routine's width.
+	var segments []markup.Segment
+	if m, start := r.marqueeSettings(); m.Width > 0 {
+		segments = m.scroll(output.segments, start, sb.now())
+	} else {
+		width, ellipsis := r.widthLimit()
+		segments = markup.TruncateWidth(output.segments, width, ellipsis)
+	}
+
+	plain := markup.Text(segments)
+	if len(plain) == 0 {
+		return "", Block{}, false
+	}
+
+	// Sinks that display each routine separately get the output without markers.
+	block = Block{
+		Name:     r.moduleName(),
+		Instance: strconv.FormatInt(r.id, 10),
+		FullText: plain,
+		Urgent:   output.failed || markup.WorstState(output.segments) == markup.StateError,
+	}
+	for _, segment := range segments {
+		if block.Color == "" {
+			block.Color = segment.Foreground
+		}
+		if block.Background == "" {
+			block.Background = segment.Background
+		}
+	}
+
+	return sb.render(output, segments), block, true
 }
 
-// setBar prints s to the statusbar.
-func setBar(s string) {
-	c := C.CString(s)
-	defer C.free(unsafe.Pointer(c))
+// render formats a routine's output with the statusbar's markup. segments are the routine's segments
+// after they were shortened to fit the routine's width.
+func (sb *Statusbar) render(o output, segments []markup.Segment) string {
+	truncated := markup.Text(segments) != markup.Text(o.segments)
+
+	// Keep the original string if we can, so that any escapes we don't parse still make it through.
+	if o.text != "" && !truncated && sb.markup == markup.Status2d {
+		return o.text
+	}
+
+	return sb.markup.Render(segments)
+}
+
+// setBar writes s to every sink as a status message that doesn't belong to any routine.
+func (sb *Statusbar) setBar(s string) {
+	sb.sinks.write(s, nil)
+}
+
+// handleClick passes a click from a sink to the routine that owns the clicked block. instance is the
+// routine's unique ID, as set in Block.Instance.
+func (sb *Statusbar) handleClick(instance string, button int) {
+	id, err := strconv.ParseInt(instance, 10, 64)
+	if err != nil {
+		return
+	}
+
+	var r *routine
+	for _, candidate := range sb.routineList() {
+		if candidate.id == id {
+			r = candidate
+			break
+		}
+	}
+
+	sb.clickRoutine(r, button)
+}
+
+// clickRoutine passes a click to r if it is running and responds to clicks. The click is handled by the routine's own
+// goroutine between updates, which then runs an update so the result of the click shows up right away.
+func (sb *Statusbar) clickRoutine(r *routine, button int) {
+	if r == nil {
+		return
+	}
 
-	C.XStoreName(dpy, root, c)
-	C.XSync(dpy, 1)
+	if _, ok := r.handler.(Clicker); ok && r.isActive() {
+		r.click(button)
+	}
 }
 
-// handleSignal clears the statusbar if the program receives an interrupt signal.
+// handleSignal clears the statusbar if the program receives an interrupt signal. If a reloader is
+// set, this also reloads the statusbar every time the program receives SIGHUP.
 func (sb *Statusbar) handleSignal() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	if sb.reloader != nil {
+		signal.Notify(c, syscall.SIGHUP)
+	}
 
 	// Wait until we receive an interrupt signal.
-	<-c
-	log.Printf("Received interrupt")
+	for sig := range c {
+		if sig != syscall.SIGHUP {
+			break
+		}
+
+		logAt(LevelInfo, "Received SIGHUP, reloading")
+		next, err := sb.reloader()
+		if err != nil {
+			logAt(LevelError, "Failed to reload", "error", err)
+			continue
+		}
+		sb.Reload(next)
+	}
+	logAt(LevelInfo, "Received interrupt")
 
 	sb.Stop()
 }
 
+// startRoutine runs r in a new goroutine. The statusbar's mutex must be held.
+func (sb *Statusbar) startRoutine(r *routine) {
+	sb.attach(r)
+	sb.launch(r)
+}
+
+// attach connects r to the statusbar before it runs. The statusbar's mutex must be held.
+func (sb *Statusbar) attach(r *routine) {
+	// Pass the statusbar's theme down to the routine if it uses one.
+	r.inheritTheme(sb.theme)
+
+	// Redraw the bar whenever the routine's output changes, send its alerts to our notifiers, and let
+	// routines that watch for changes themselves ask for an update.
+	r.mutex.Lock()
+	r.changed = sb.requestRedraw
+	r.sendAlert = sb.sendAlert
+	r.mutex.Unlock()
+	if notifier, ok := r.handler.(Notifier); ok {
+		notifier.SetNotify(r.update)
+	}
+
+	// Let routines that draw their recent values read them from the routine's history.
+	if historian, ok := r.handler.(Historian); ok {
+		r.keepHistory(historian.HistoryDepth())
+		historian.SetHistory(r.historyOf)
+	}
+}
+
+// launch runs r in a new goroutine. The statusbar's mutex must be held.
+func (sb *Statusbar) launch(r *routine) {
+	// A stop or update request can be left over from the routine's last run if it came in while the
+	// routine was exiting on its own. Drop it so that the new run doesn't act on it.
+	for _, c := range []chan struct{}{r.stopChan, r.updateChan} {
+		select {
+		case <-c:
+		default:
+		}
+	}
+
+	// Mark the routine as active right away so that it can't be launched twice.
+	r.setActive(true)
+	r.exited = make(chan struct{})
+	sb.live++
+	go r.run(sb.finished)
+}
+
+// isRunning returns whether or not the statusbar is running.
+func (sb *Statusbar) isRunning() bool {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	return sb.running
+}
+
+// routineList returns the current list of routines.
+func (sb *Statusbar) routineList() []*routine {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	return sb.routines
+}
+
 // runAPIs runs the various APIs and their versions using the callback methods implemented by
 // handler. New APIs/versions should be added here.
 func (sb *Statusbar) runAPIs() {
 	if sb.restPort > 0 {
 		// Begin with the REST API.
-		r := restapi.NewEngine()
+		r := restapi.NewEngineWithLog(sb.requestLog)
 
 		// Spin up REST API v1. Use an apiHandler to wrap the statusbar object for convenience (see
 		// type definition).
 		s := strings.NewReader(apispecs.RESTV1)
 		if err := r.AddSpecReader(s, apiHandler{sb}); err != nil {
-			log.Printf("Error building REST API v1: %s", err.Error())
+			logAt(LevelError, "Error building REST API v1", "error", err)
 			sb.restEngine = nil
 		} else {
+			// Serve the metrics alongside the REST API, if they're enabled.
+			if sb.metrics {
+				if err := r.Handle("GET", "/metrics", metricsHandler{sb}); err != nil {
+					logAt(LevelError, "Error serving metrics", "error", err)
+				}
+			}
+
 			// Now that everything looks good, we can save this engine and start it up.
 			sb.restEngine = r
 			sb.restEngine.Run(sb.restPort)
@@ -321,9 +1037,9 @@ func (sb *Statusbar) stopAPIs() {
 	// Begin with the REST API. Give it 5 seconds to shut down.
 	if sb.restEngine != nil {
 		if err := sb.restEngine.Stop(5); err == nil {
-			log.Printf("Stopped REST API engine")
+			logAt(LevelInfo, "Stopped REST API engine")
 		} else {
-			log.Printf("Error stopping REST API engine: %s", err.Error())
+			logAt(LevelError, "Error stopping REST API engine", "error", err)
 		}
 	}
 }
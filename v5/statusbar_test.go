@@ -1,9 +1,30 @@
 package statusbar
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/registry"
+	"github.com/snhilde/statusbar/v5/restapi"
 	"github.com/snhilde/statusbar/v5/sbbattery"
 	"github.com/snhilde/statusbar/v5/sbcputemp"
 	"github.com/snhilde/statusbar/v5/sbcpuusage"
@@ -16,27 +37,30 @@ import (
 	"github.com/snhilde/statusbar/v5/sbtodo"
 	"github.com/snhilde/statusbar/v5/sbvolume"
 	"github.com/snhilde/statusbar/v5/sbweather"
+	"github.com/snhilde/statusbar/v5/theme"
+	"github.com/snhilde/statusbar/v5/value"
 )
 
 func TestStatusbar(t *testing.T) {
 	// Build and run a new statusbar to make sure everything builds as expected.
 	bar := New()
+	bar.SetTheme(theme.Theme{Error: theme.Colors{Background: "#000000"}})
 
-	bar.Append(sbbattery.New([3]string{"#17A130", "#BB4F2E", "#A1273E"}), 30)
-	bar.Append(sbcputemp.New([3]string{"#8FFFFF", "#BB4F2E", "#A1273E"}), 1)
-	bar.Append(sbcpuusage.New([3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 1)
-	bar.Append(sbdisk.New([]string{"/"}, [3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 5)
-	bar.Append(sbfan.New([3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 1)
-	bar.Append(sbload.New([3]string{"#434852", "#BB4F2E", "#A1273E"}), 1)
+	bar.Append(sbbattery.New(theme.FromColors("#17A130", "#BB4F2E", "#A1273E")), 30)
+	bar.Append(sbcputemp.New(theme.FromColors("#8FFFFF", "#BB4F2E", "#A1273E")), 1)
+	bar.Append(sbcpuusage.New(theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 1)
+	bar.Append(sbdisk.New([]string{"/"}, theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 5)
+	bar.Append(sbfan.New(theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 1)
+	bar.Append(sbload.New(theme.FromColors("#434852", "#BB4F2E", "#A1273E")), 1)
 
 	bar.Split()
 
-	bar.Append(sbnetwork.New([]string{"interface"}, [3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 1)
-	bar.Append(sbram.New([3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 5)
-	bar.Append(sbtime.New("Jan 2 - 03:04", [3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 1)
-	bar.Append(sbtodo.New("/home/user/.TODO", [3]string{"#F1EA6B", "#BB4F2E", "#A1273E"}), 5)
-	bar.Append(sbvolume.New("Master", [3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 1)
-	bar.Append(sbweather.New(123.45, 123.45, "ABCD", true, [3]string{"#FFFFFF", "#BB4F2E", "#A1273E"}), 30*60)
+	bar.Append(sbnetwork.New([]string{"interface"}, theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 1)
+	bar.Append(sbram.New(theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 5)
+	bar.Append(sbtime.New("Jan 2 - 03:04", theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 1)
+	bar.Append(sbtodo.New("/home/user/.TODO", theme.FromColors("#F1EA6B", "#BB4F2E", "#A1273E")), 5)
+	bar.Append(sbvolume.New("Master", theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 1)
+	bar.Append(sbweather.New(123.45, 123.45, "ABCD", true, theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")), 30*60)
 
 	bar.EnableRESTAPI(1234)
 
@@ -49,3 +73,1631 @@ func TestStatusbar(t *testing.T) {
 
 	t.Log("Statusbar stopped successfully")
 }
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status")
+	sink := NewFileSink(path)
+
+	for _, frame := range []string{"first frame", "second frame"} {
+		if err := sink.Write(frame); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(b) != frame+"\n" {
+			t.Errorf("Expected %q, got %q", frame+"\n", string(b))
+		}
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	buf := new(bytes.Buffer)
+	sink := NewWriterSink(buf)
+	for _, frame := range []string{"first frame", "second frame"} {
+		if err := sink.Write(frame); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+	}
+	if want := "first frame\nsecond frame\n"; buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	// The stdout sink writes to stdout, but it doesn't close it.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	stdout := os.Stdout
+	os.Stdout = w
+	sink = NewStdoutSink()
+	if err := sink.Write("frame"); err != nil {
+		t.Errorf("Failed to write frame: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("Failed to close sink: %v", err)
+	}
+	os.Stdout = stdout
+	if _, err := w.WriteString("still open\n"); err != nil {
+		t.Errorf("Expected stdout to stay open: %v", err)
+	}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil || line != "frame\n" {
+		t.Errorf("Expected %q, got %q (%v)", "frame\n", line, err)
+	}
+}
+
+func TestFIFOSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status")
+	sink, err := NewFIFOSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if info, err := os.Stat(path); err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("Expected a FIFO at %s (%v)", path, err)
+	}
+
+	// Without a reader, frames are dropped.
+	if err := sink.Write("dropped"); err != nil {
+		t.Errorf("Expected frame to be dropped without a reader, got %v", err)
+	}
+
+	reader, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write("first frame"); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+	if err := reader.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 64)
+	if n, err := reader.Read(b); err != nil || string(b[:n]) != "first frame\n" {
+		t.Errorf("Expected %q, got %q (%v)", "first frame\n", b[:n], err)
+	}
+
+	// A reader that stops reading doesn't hold up the engine for longer than the write timeout once the
+	// pipe is full.
+	frame := strings.Repeat("x", 1<<16)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := sink.Write(frame); err != nil {
+			t.Errorf("Expected frame to be dropped for a slow reader, got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 4*fifoWriteTimeout+time.Second {
+		t.Errorf("Writing to a slow reader took %v", elapsed)
+	}
+
+	// When the reader goes away, the FIFO is opened again for the next reader.
+	reader.Close()
+	if err := sink.Write("lost"); err != nil {
+		t.Errorf("Expected frame to be dropped after the reader left, got %v", err)
+	}
+	reader, err = os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if err := sink.Write("second frame"); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+	if err := reader.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := reader.Read(b); err != nil || string(b[:n]) != "second frame\n" {
+		t.Errorf("Expected %q, got %q (%v)", "second frame\n", b[:n], err)
+	}
+
+	// Only FIFOs can be used.
+	file := filepath.Join(filepath.Dir(path), "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFIFOSink(file); err == nil {
+		t.Error("Expected error for a regular file")
+	}
+}
+
+func TestI3barSink(t *testing.T) {
+	out := new(bytes.Buffer)
+	in := strings.NewReader("[\n{\"name\":\"sbtime\",\"instance\":\"1\",\"button\":3}\n")
+	sink := NewI3barSinkIO(out, in)
+
+	clicks := make(chan string, 1)
+	sink.(ClickSink).OnClick(func(instance string, button int) {
+		clicks <- fmt.Sprintf("%s/%d", instance, button)
+	})
+
+	blocks := []Block{{Name: "sbtime", Instance: "1", FullText: "Jan 2 - 03:04", Color: "#FFFFFF"}}
+	if err := sink.(BlockSink).WriteBlocks(blocks); err != nil {
+		t.Fatalf("Failed to write blocks: %v", err)
+	}
+
+	want := "{\"version\":1,\"click_events\":true}\n[\n" +
+		"[{\"name\":\"sbtime\",\"instance\":\"1\",\"full_text\":\"Jan 2 - 03:04\",\"color\":\"#FFFFFF\"}],\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+
+	select {
+	case click := <-clicks:
+		if click != "1/3" {
+			t.Errorf("Expected click on 1/3, got %s", click)
+		}
+	case <-time.After(time.Second):
+		t.Error("Click was not received")
+	}
+}
+
+func TestI3barClicks(t *testing.T) {
+	sb := New()
+	clicker := &clickingRoutine{countingRoutine: countingRoutine{name: "volume"}}
+	sb.Append(&countingRoutine{name: "time"}, 1)
+	sb.Append(clicker, 1)
+	for _, r := range sb.routines {
+		r.setActive(true)
+	}
+	timeID, volumeID := sb.routines[0].id, sb.routines[1].id
+
+	// The events come as an infinite array: an opening bracket, then one event per line, every one after the first
+	// with a leading comma. Malformed lines, blank lines, and clicks on unknown instances are ignored.
+	in := fmt.Sprintf("[\n{\"name\":\"volume\",\"instance\":\"%d\",\"button\":1,\"x\":1800,\"y\":5}\n"+
+		",{\"name\":\"time\",\"instance\":\"%d\",\"button\":3}\n"+
+		",{\"name\":\"volume\",\"instance\":\n"+
+		"\n"+
+		",{\"name\":\"other\",\"instance\":\"bogus\",\"button\":2}\n"+
+		", {\"name\":\"volume\",\"instance\":\"%d\",\"button\":5}\n", volumeID, timeID, volumeID)
+
+	done := make(chan struct{})
+	sink := NewI3barSinkIO(new(bytes.Buffer), strings.NewReader(in))
+	sink.(ClickSink).OnClick(func(instance string, button int) {
+		sb.handleClick(instance, button)
+		if button == 5 {
+			close(done)
+		}
+	})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Clicks were not received")
+	}
+
+	// Only the volume routine implements Clicker, so only its clicks are queued.
+	if n := len(sb.routines[0].clickChan); n != 0 {
+		t.Errorf("Expected no clicks for the time routine, got %d", n)
+	}
+	r := sb.routines[1]
+	for len(r.clickChan) > 0 {
+		r.runClick(<-r.clickChan)
+	}
+	if len(clicker.clicks) != 2 || clicker.clicks[0] != 1 || clicker.clicks[1] != 5 {
+		t.Errorf("Expected clicks [1 5], got %v", clicker.clicks)
+	}
+}
+
+// hangingRoutine is a routine whose update blocks until its context is cancelled.
+type hangingRoutine struct {
+	cancelled chan struct{}
+}
+
+func (h *hangingRoutine) Update() (bool, error) { return h.UpdateContext(context.Background()) }
+func (h *hangingRoutine) String() string        { return "hanging" }
+func (h *hangingRoutine) Error() string         { return "error" }
+func (h *hangingRoutine) Name() string          { return "Hanging" }
+
+func (h *hangingRoutine) UpdateContext(ctx context.Context) (bool, error) {
+	<-ctx.Done()
+	close(h.cancelled)
+	return true, ctx.Err()
+}
+
+func TestUpdateTimeout(t *testing.T) {
+	h := &hangingRoutine{cancelled: make(chan struct{})}
+
+	r := newRoutine()
+	r.setHandler(h)
+	WithTimeout(10 * time.Millisecond)(r)
+
+	result, stopped := r.runUpdate()
+	if stopped {
+		t.Fatal("Routine reported being stopped")
+	}
+	if !result.ok || result.err == nil {
+		t.Errorf("Expected a non-critical timeout error, got (%v, %v)", result.ok, result.err)
+	}
+
+	select {
+	case <-h.cancelled:
+	case <-time.After(time.Second):
+		t.Error("Context was not cancelled")
+	}
+}
+
+// countingRoutine is a routine that counts how many times it has been updated.
+type countingRoutine struct {
+	name    string
+	updates int64
+}
+
+func (c *countingRoutine) Update() (bool, error) { atomic.AddInt64(&c.updates, 1); return true, nil }
+func (c *countingRoutine) String() string        { return c.name }
+func (c *countingRoutine) Error() string         { return "error" }
+func (c *countingRoutine) Name() string          { return c.name }
+
+// themedRoutine is a routine that keeps the last theme that it inherited.
+type themedRoutine struct {
+	countingRoutine
+	mutex  sync.Mutex
+	parent theme.Theme
+}
+
+func (t *themedRoutine) InheritTheme(parent theme.Theme) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.parent = parent
+}
+
+func (t *themedRoutine) inherited() theme.Theme {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.parent
+}
+
+func TestReload(t *testing.T) {
+	kept, removed := &themedRoutine{countingRoutine: countingRoutine{name: "kept"}}, &countingRoutine{name: "removed"}
+
+	sb := New()
+	sb.Append(kept, 1, WithFingerprint("kept"))
+	sb.Append(removed, 1, WithFingerprint("removed"))
+
+	// Start the routines the same way that Run does.
+	sb.finished = make(chan *routine, 3)
+	for _, r := range sb.routines {
+		sb.startRoutine(r)
+	}
+	old := sb.routineList()
+	for deadline := time.Now().Add(time.Second); !old[0].isActive() || !old[1].isActive(); {
+		if time.Now().After(deadline) {
+			t.Fatal("Routines did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	added := &countingRoutine{name: "added"}
+	next := New()
+	next.Append(added, 1)
+	next.Append(&countingRoutine{name: "kept"}, 2, WithFingerprint("kept"))
+	next.SetMarkers("<", ">")
+	nord, _ := theme.Palette("nord")
+	next.SetTheme(nord)
+	sb.Reload(&next)
+
+	routines := sb.routineList()
+	if len(routines) != 2 || routines[0].handler != added || routines[1] != old[0] {
+		t.Fatal("Routines were not swapped correctly")
+	}
+	if routines[1].interval() != 2 {
+		t.Errorf("Expected kept routine to have new interval of 2, got %d", routines[1].interval())
+	}
+	if sb.leftDelim != "<" || sb.rightDelim != ">" {
+		t.Errorf("Expected new markers, got %s and %s", sb.leftDelim, sb.rightDelim)
+	}
+	// The kept routine is running, so it picks up the new theme between updates.
+	for deadline := time.Now().Add(time.Second); kept.inherited() != nord; {
+		if time.Now().After(deadline) {
+			t.Errorf("Expected kept routine to inherit the new theme, got %v", kept.inherited())
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case r := <-sb.finished:
+		if r != old[1] {
+			t.Errorf("Expected removed routine to stop, got %s", r.displayName())
+		}
+	case <-time.After(time.Second):
+		t.Error("Removed routine did not stop")
+	}
+
+	for _, r := range routines {
+		r.stop(1)
+	}
+}
+
+// captureSink is a sink that keeps every frame written to it.
+type captureSink struct {
+	mutex  sync.Mutex
+	frames []string
+}
+
+func (c *captureSink) Write(bar string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.frames = append(c.frames, bar)
+	return nil
+}
+
+func (c *captureSink) Close() error { return nil }
+
+func (c *captureSink) written() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string(nil), c.frames...)
+}
+
+// textOutput returns the output of a routine whose String method returned s.
+func textOutput(s string) output {
+	return output{text: s, segments: markup.ParseStatus2d(s)}
+}
+
+func TestRedraw(t *testing.T) {
+	sink := new(captureSink)
+
+	sb := New()
+	sb.AddSink(sink)
+	sb.Append(&countingRoutine{name: "first"}, 1)
+	sb.Append(&countingRoutine{name: "second"}, 1)
+	for _, r := range sb.routines {
+		r.changed = sb.requestRedraw
+	}
+
+	go sb.buildBar()
+	defer close(sb.done)
+
+	// Both changes should be drawn in a single frame.
+	sb.routines[0].setOutput(textOutput("first"))
+	sb.routines[1].setOutput(textOutput("second"))
+	time.Sleep(10 * redrawDelay)
+	if frames := sink.written(); len(frames) != 1 || frames[0] != "[first] [second]" {
+		t.Fatalf("Expected one frame with both outputs, got %q", frames)
+	}
+
+	// Output that didn't change shouldn't cause a redraw.
+	sb.routines[0].setOutput(textOutput("first"))
+	time.Sleep(10 * redrawDelay)
+	if frames := sink.written(); len(frames) != 1 {
+		t.Errorf("Expected no new frames, got %q", frames[1:])
+	}
+
+	sb.routines[1].setOutput(textOutput("changed"))
+	time.Sleep(10 * redrawDelay)
+	if frames := sink.written(); len(frames) != 2 || frames[1] != "[first] [changed]" {
+		t.Errorf("Expected new frame with changed output, got %q", frames)
+	}
+}
+
+// failingRoutine is a routine whose updates always fail with a non-critical error.
+type failingRoutine struct {
+	updates int64
+}
+
+func (f *failingRoutine) Update() (bool, error) {
+	atomic.AddInt64(&f.updates, 1)
+	return true, fmt.Errorf("failed")
+}
+func (f *failingRoutine) String() string { return "" }
+func (f *failingRoutine) Error() string  { return "error" }
+func (f *failingRoutine) Name() string   { return "Failing" }
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2}
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		if wait := policy.backoff(i + 1); wait != want {
+			t.Errorf("Expected wait of %v after %d failures, got %v", want, i+1, wait)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if wait := policy.backoff(1); wait < time.Second/2 || wait > 3*time.Second/2 {
+			t.Fatalf("Wait of %v is outside of jitter range", wait)
+		}
+	}
+
+	// The routine should stop once it has failed too many times in a row.
+	f := new(failingRoutine)
+	r := newRoutine()
+	r.setHandler(f)
+	r.setInterval(1)
+	WithRetryPolicy(RetryPolicy{Initial: time.Millisecond, MaxFailures: 3})(r)
+
+	finished := make(chan *routine, 1)
+	go r.run(finished)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("Routine did not give up")
+	}
+
+	if updates := atomic.LoadInt64(&f.updates); updates != 3 {
+		t.Errorf("Expected 3 updates, got %d", updates)
+	}
+	if failures, next := r.retryState(); failures != 3 || !next.IsZero() {
+		t.Errorf("Expected 3 failures and no retry, got %d and %v", failures, next)
+	}
+}
+
+// blockingRoutine is a routine whose update ignores cancellation and blocks until it is released. It notes whether two
+// of its updates ever ran at the same time.
+type blockingRoutine struct {
+	countingRoutine
+	started chan struct{}
+	release chan struct{}
+	running int32
+	overlap int32
+}
+
+func (b *blockingRoutine) Update() (bool, error) {
+	if atomic.AddInt32(&b.running, 1) > 1 {
+		atomic.StoreInt32(&b.overlap, 1)
+	}
+	defer atomic.AddInt32(&b.running, -1)
+
+	b.started <- struct{}{}
+	<-b.release
+	return true, nil
+}
+
+func TestRestartDuringUpdate(t *testing.T) {
+	b := &blockingRoutine{started: make(chan struct{}, 2), release: make(chan struct{})}
+	sb := New()
+	sb.Append(b, 60)
+	r := sb.routines[0]
+
+	sb.mutex.Lock()
+	sb.finished = make(chan *routine, 2)
+	sb.running = true
+	sb.startRoutine(r)
+	sb.mutex.Unlock()
+
+	// Stop the routine in the middle of an update, and then start it right away.
+	<-b.started
+	if !r.stop(1) {
+		t.Fatal("Failed to stop routine")
+	}
+	if err := sb.start(r); err != nil {
+		t.Fatalf("Failed to start routine: %v", err)
+	}
+
+	// The new run loop must wait for the old update to return before updating again.
+	select {
+	case <-b.started:
+		t.Error("Routine was updated while its old update was still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(b.release)
+	select {
+	case <-b.started:
+	case <-time.After(time.Second):
+		t.Error("Routine was not updated after its old update returned")
+	}
+	if atomic.LoadInt32(&b.overlap) != 0 {
+		t.Error("Updates overlapped")
+	}
+
+	if !r.stop(1) {
+		t.Error("Failed to stop routine")
+	}
+}
+
+func TestRestartAfterStaleStop(t *testing.T) {
+	c := &countingRoutine{name: "counter"}
+	sb := New()
+	sb.Append(c, 60)
+	r := sb.routines[0]
+
+	sb.mutex.Lock()
+	sb.finished = make(chan *routine, 2)
+	sb.running = true
+	sb.startRoutine(r)
+	sb.mutex.Unlock()
+	if !r.stop(1) {
+		t.Fatal("Failed to stop routine")
+	}
+	<-sb.finished
+
+	// Leave a stop request behind, the way it would be if the routine had exited on its own just as it
+	// was stopped. The restarted routine must not read it.
+	r.stopChan <- struct{}{}
+	if err := sb.start(r); err != nil {
+		t.Fatalf("Failed to start routine: %v", err)
+	}
+	select {
+	case <-sb.finished:
+		t.Error("Restarted routine stopped right away")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if !r.isActive() || atomic.LoadInt64(&c.updates) != 2 {
+		t.Errorf("Expected restarted routine to run, got %d updates", atomic.LoadInt64(&c.updates))
+	}
+
+	if !r.stop(1) {
+		t.Error("Failed to stop routine")
+	}
+}
+
+func TestSupervisor(t *testing.T) {
+	always, never := &countingRoutine{name: "always"}, &countingRoutine{name: "never"}
+
+	sb := New()
+	sb.Append(always, 0, WithRestartPolicy(RestartAlways, time.Millisecond))
+	sb.Append(never, 0)
+
+	// Start the routines the same way that Run does.
+	sb.mutex.Lock()
+	sb.finished = make(chan *routine)
+	sb.running = true
+	for _, r := range sb.routines {
+		sb.startRoutine(r)
+	}
+	sb.mutex.Unlock()
+	supervised := func() *routine {
+		for {
+			select {
+			case r := <-sb.finished:
+				sb.supervise(r)
+				return r
+			case <-sb.wake:
+				if !sb.waiting() {
+					return nil
+				}
+			case <-time.After(time.Second):
+				t.Fatal("No routine stopped")
+			}
+		}
+	}
+
+	// The routine that only runs once should keep getting restarted, while the other routine stays stopped
+	// until it's started by hand.
+	var stopped *routine
+	for i := 0; i < 4; i++ {
+		if r := supervised(); r != nil && r.handler == never {
+			stopped = r
+		}
+	}
+	if stopped == nil || stopped.isActive() {
+		t.Fatal("Routine without a restart policy was restarted")
+	}
+	if restarts := sb.routines[0].restartCount(); restarts < 2 {
+		t.Errorf("Expected at least 2 restarts, got %d", restarts)
+	}
+
+	if err := sb.start(stopped); err != nil {
+		t.Errorf("Failed to start stopped routine: %v", err)
+	}
+	if err := sb.start(stopped); err == nil {
+		t.Error("Started a routine that was already running")
+	}
+
+	sb.mutex.Lock()
+	sb.running = false
+	sb.mutex.Unlock()
+	for sb.waiting() {
+		supervised()
+	}
+}
+
+func TestLayout(t *testing.T) {
+	sb := New()
+	for _, name := range []string{"a", "b", "c"} {
+		sb.Append(&countingRoutine{name: name}, 1)
+	}
+	sb.Split() // After "c".
+
+	names := func() string {
+		var b strings.Builder
+		for i, r := range sb.routineList() {
+			b.WriteString(r.handler.Name())
+			if i == sb.split {
+				b.WriteByte(';')
+			}
+		}
+		return b.String()
+	}
+
+	steps := []struct {
+		change func() error
+		want   string
+	}{
+		{func() error { return sb.Insert(0, &countingRoutine{name: "d"}, 1) }, "dabc;"},
+		{func() error { return sb.SetSplit(1) }, "da;bc"},
+		{func() error { return sb.Move(3, 0) }, "cd;ab"},
+		{func() error { return sb.Remove(1) }, "c;ab"},
+		{func() error { return sb.Insert(4, &countingRoutine{name: "e"}, 1) }, ""},
+		{func() error { return sb.Move(0, 3) }, ""},
+		{func() error { return sb.SetSplit(-1) }, "cab"},
+	}
+	for i, step := range steps {
+		err := step.change()
+		switch {
+		case step.want == "" && err == nil:
+			t.Errorf("Step %d: expected error", i)
+		case step.want != "" && err != nil:
+			t.Errorf("Step %d: unexpected error: %v", i, err)
+		case step.want != "" && names() != step.want:
+			t.Errorf("Step %d: expected %q, got %q", i, step.want, names())
+		}
+	}
+}
+
+func TestWidth(t *testing.T) {
+	sb := New()
+	sb.Append(&countingRoutine{name: "default"}, 1)
+	sb.Append(&countingRoutine{name: "middle"}, 1, WithWidth(9, markup.EllipsisMiddle))
+	sb.Append(&countingRoutine{name: "unlimited"}, 1, WithWidth(0, markup.EllipsisEnd))
+
+	long := strings.Repeat("°", DefaultWidth+10)
+	sb.routines[0].setOutput(textOutput(long))
+	sb.routines[1].setOutput(textOutput("↓ 1.5 MB/s ↑ 20 kB/s"))
+	sb.routines[2].setOutput(textOutput(long))
+
+	want := "[" + strings.Repeat("°", DefaultWidth-3) + "...] [↓ 1...B/s] [" + long + "]"
+	if s, _ := sb.compose(); s != want {
+		t.Errorf("Expected %q, got %q", want, s)
+	}
+}
+
+func TestRegions(t *testing.T) {
+	sb := New()
+	for _, name := range []string{"a", "b", "c"} {
+		sb.Append(&countingRoutine{name: name}, 1)
+	}
+	sb.Split() // After "c".
+	for _, r := range sb.routines {
+		r.setOutput(textOutput(r.handler.Name()))
+	}
+	if s, _ := sb.compose(); s != "[a] [b] [c];" {
+		t.Errorf("Expected split bar, got %q", s)
+	}
+
+	if err := sb.AddRegion(Region{Name: "main"}); err != nil {
+		t.Fatal(err)
+	}
+	status := Region{Name: "status", Separator: " | ", Markers: []string{"", ""}, Align: markup.AlignRight}
+	if err := sb.AddRegion(status); err != nil {
+		t.Fatal(err)
+	}
+	if err := sb.AddRegion(Region{Name: "main"}); err == nil {
+		t.Errorf("Expected error for duplicate region")
+	}
+	sb.routines[0].setRegion("status")
+	sb.routines[2].setRegion("status")
+
+	s, blocks := sb.compose()
+	if want := "[b];a | c"; s != want {
+		t.Errorf("Expected %q, got %q", want, s)
+	}
+	if len(blocks) != 3 || blocks[0].Region != "main" || blocks[1].Region != "status" {
+		t.Errorf("Bad blocks: %+v", blocks)
+	}
+
+	sb.SetMarkup(markup.Lemonbar)
+	if s, _ := sb.compose(); s != "%{l}[b]%{r}a | c" {
+		t.Errorf("Expected lemonbar alignment, got %q", s)
+	}
+}
+
+// valuedRoutine reports a fixed set of values.
+type valuedRoutine struct {
+	countingRoutine
+	values []value.Value
+}
+
+func (v *valuedRoutine) Values() []value.Value { return v.values }
+
+func TestVisibility(t *testing.T) {
+	battery := []value.Value{value.NewNumber("perc", 100, "%"), value.NewBool("ac", true)}
+	disks := []value.Value{
+		value.NewNumber("perc", 40, "%").WithLabel("path", "/"),
+		value.NewNumber("perc", 95, "%").WithLabel("path", "/home").WithLabel("device", "sda2"),
+	}
+	tests := []struct {
+		rule   VisibilityRule
+		values []value.Value
+		hidden bool
+	}{
+		{VisibilityRule{Name: "full", HideWhen: "ac && perc >= 100"}, battery, true},
+		{VisibilityRule{Name: "full", HideWhen: "!ac && perc >= 100"}, battery, false},
+		{VisibilityRule{Name: "fast", ShowWhen: "rpm > 3000"}, []value.Value{value.NewNumber("rpm", 2500, "RPM")}, true},
+		{VisibilityRule{Name: "fast", ShowWhen: "rpm > 3000"}, []value.Value{value.NewNumber("rpm", 3500, "RPM")}, false},
+		{VisibilityRule{Name: "down", HideWhen: `status == "disconnected" || state == error`}, nil, false},
+		{VisibilityRule{Name: "down", HideWhen: `status != connected`}, []value.Value{value.NewText("status", "x")}, true},
+		{VisibilityRule{Name: "normal", HideWhen: "state==normal"}, nil, true},
+		{VisibilityRule{Name: "full", HideWhen: `perc{path="/home"} > 90`}, disks, true},
+		{VisibilityRule{Name: "full", HideWhen: `perc{path="/"}>90`}, disks, false},
+		{VisibilityRule{Name: "full", HideWhen: `perc{device="sda2", path="/home"} > 90`}, disks, true},
+		{VisibilityRule{Name: "full", HideWhen: `perc{device="sda1"} > 90`}, disks, false},
+		{VisibilityRule{Name: "full", HideWhen: `perc > 90`}, disks, false},
+		{VisibilityRule{Name: "tmp", ShowWhen: `perc{path="/tmp"} >= 0`}, disks, true},
+	}
+
+	for i, test := range tests {
+		sb := New()
+		sb.Append(&valuedRoutine{countingRoutine{name: "a"}, test.values}, 1, WithVisibility(test.rule))
+		r := sb.routines[0]
+		r.setOutput(r.collectOutput(nil))
+		if _, blocks := sb.compose(); (len(blocks) == 0) != test.hidden {
+			t.Errorf("Test %d: expected hidden to be %v", i, test.hidden)
+		}
+
+		// Turning the rule off always shows the routine.
+		if err := r.enableRule(test.rule.Name, false); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+		if _, blocks := sb.compose(); len(blocks) == 0 {
+			t.Errorf("Test %d: expected routine to be shown with the rule turned off", i)
+		}
+	}
+
+	for _, s := range []string{
+		"", "perc >", "perc = 1", "perc && ", "state < normal", `text == "open`, "Perc > 1", `perc{path="/" > 1`,
+		`perc{path=/} > 1`, `perc{path="/"device="sda"} > 1`, `perc{path="/",} > 1`, `{path="/"} > 1`,
+	} {
+		if err := (VisibilityRule{Name: "bad", HideWhen: s}).Validate(); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
+
+func TestSlots(t *testing.T) {
+	sb := New()
+	sb.Append(&countingRoutine{name: "a"}, 1)
+	for _, name := range []string{"b", "c", "d"} {
+		sb.Append(&countingRoutine{name: name}, 1, WithSlot("online"))
+	}
+	sb.Append(&countingRoutine{name: "e"}, 1)
+	for _, r := range sb.routines {
+		r.setOutput(textOutput(r.handler.Name()))
+	}
+	sb.routines[2].setOutput(output{}) // "c" has nothing to show, so it is skipped.
+
+	if err := sb.AddSlot(Slot{Name: "online", Period: time.Minute, Pin: true}); err != nil {
+		t.Fatal(err)
+	}
+	s := sb.slots[0]
+
+	start := time.Now().Add(-time.Second)
+	s.start = start
+	if bar, _ := sb.compose(); bar != "[a] [b] [e]" {
+		t.Errorf("Expected first member, got %q", bar)
+	}
+	if next := sb.nextRotation(); time.Until(next) <= 0 || time.Until(next) > time.Minute {
+		t.Errorf("Bad next rotation: %v", next)
+	}
+
+	// The slot stays on its member when another member starts or stops showing something, and moves
+	// on from it to the next member with something to show. A member that has nothing to show
+	// anymore hands its turn to the next member right away.
+	steps := []struct {
+		change  func()
+		elapsed time.Duration
+		want    string
+	}{
+		{nil, time.Minute + time.Second, "d"},
+		{nil, 2*time.Minute + time.Second, "b"},
+		{func() { sb.routines[2].setOutput(textOutput("c")) }, 2*time.Minute + 30*time.Second, "b"},
+		{nil, 3*time.Minute + time.Second, "c"},
+		{func() { sb.routines[2].setOutput(output{}) }, 3*time.Minute + 30*time.Second, "d"},
+		{nil, 4*time.Minute + 29*time.Second, "d"},
+		{nil, 4*time.Minute + 31*time.Second, "b"},
+	}
+	for i, step := range steps {
+		if step.change != nil {
+			step.change()
+		}
+		if member, _ := sb.current(s, start.Add(step.elapsed)); member == nil || member.handler.Name() != step.want {
+			t.Errorf("Step %d: expected %s to be shown", i, step.want)
+		}
+	}
+
+	// A member in the warning state stays shown.
+	sb.routines[3].setOutput(output{segments: []markup.Segment{{Text: "d", State: markup.StateWarning}}})
+	s.start = time.Now()
+	if member, pinned := sb.current(s, time.Now()); member != sb.routines[3] || !pinned {
+		t.Errorf("Expected warning member to be pinned")
+	}
+}
+
+func TestMarquee(t *testing.T) {
+	sb := New()
+	sb.Append(&countingRoutine{name: "todo"}, 1, WithMarquee(Marquee{Width: 5, Step: time.Second, Gap: " | "}))
+	r := sb.routines[0]
+	r.setOutput(textOutput("buy milk"))
+
+	for step, want := range map[int]string{0: "[buy m]", 4: "[milk ]", 9: "[| buy]", 11: "[buy m]"} {
+		r.marqueeStart = time.Now().Add(-time.Duration(step)*time.Second - time.Millisecond)
+		if s, _ := sb.compose(); s != want {
+			t.Errorf("Step %d: expected %q, got %q", step, want, s)
+		}
+	}
+	if next := sb.nextFrame(); time.Until(next) <= 0 || time.Until(next) > time.Second {
+		t.Errorf("Bad next frame: %v", next)
+	}
+
+	// Output that fits doesn't scroll.
+	r.setOutput(textOutput("milk"))
+	if s, _ := sb.compose(); s != "[milk]" || !sb.nextFrame().IsZero() {
+		t.Errorf("Expected short output to stay still, got %q", s)
+	}
+}
+
+// clickingRoutine is a routine that records the buttons that it was clicked with.
+type clickingRoutine struct {
+	countingRoutine
+	clicks []int
+}
+
+func (c *clickingRoutine) Click(button int) error { c.clicks = append(c.clicks, button); return nil }
+
+func TestStatusCmd(t *testing.T) {
+	sb := New()
+	sb.EnableStatusCmd()
+	clicker := &clickingRoutine{countingRoutine: countingRoutine{name: "volume"}}
+	sb.Append(&countingRoutine{name: "time"}, 1)
+	sb.Append(clicker, 1)
+	for _, r := range sb.routines {
+		r.setOutput(textOutput(r.handler.Name()))
+		r.setActive(true)
+	}
+
+	if s, _ := sb.compose(); s != "\x01[time] \x02[volume]" {
+		t.Errorf("Expected markers before each routine, got %q", s)
+	}
+
+	// Only the routine that implements Clicker receives clicks, and unknown markers are ignored.
+	sb.handleStatusCmd(2, 3)
+	sb.handleStatusCmd(1, 1)
+	sb.handleStatusCmd(3, 1)
+
+	// Clicks are queued for the routine's own goroutine, so handle them here the way it would.
+	r := sb.routines[1]
+	for len(r.clickChan) > 0 {
+		r.runClick(<-r.clickChan)
+	}
+	if len(clicker.clicks) != 1 || clicker.clicks[0] != 3 {
+		t.Errorf("Expected one right click, got %v", clicker.clicks)
+	}
+
+	// Markers follow the routines' positions, and the newline byte is never used as a marker.
+	for i := 0; i < 30; i++ {
+		sb.Append(&countingRoutine{name: strconv.Itoa(i)}, 1)
+	}
+	if err := sb.Move(1, 9); err != nil {
+		t.Fatal(err)
+	}
+	if marker := sb.statusCmdMarker(sb.routines[9]); marker != "\x0b" || sb.routines[9] != r {
+		t.Errorf("Expected the tenth routine to be marked with 0x0B, got %q", marker)
+	}
+	if marker := sb.statusCmdMarker(sb.routines[28]); marker != "\x1e" {
+		t.Errorf("Expected the 29th routine to be marked with 0x1E, got %q", marker)
+	}
+	if marker := sb.statusCmdMarker(sb.routines[29]); marker != "" {
+		t.Errorf("Expected no marker after the 29th routine, got %q", marker)
+	}
+	for i := range sb.routines {
+		if strings.Contains(sb.statusCmdMarker(sb.routines[i]), "\n") {
+			t.Errorf("Routine %d is marked with a newline", i)
+		}
+	}
+
+	sb.handleStatusCmd(10, 1)
+	sb.handleStatusCmd(11, 2)
+	for len(r.clickChan) > 0 {
+		r.runClick(<-r.clickChan)
+	}
+	if len(clicker.clicks) != 2 || clicker.clicks[1] != 2 {
+		t.Errorf("Expected only marker 11 to click the tenth routine, got %v", clicker.clicks)
+	}
+}
+
+// slowClicker is a routine whose update takes a while and whose clicks change the same state as its update.
+type slowClicker struct {
+	countingRoutine
+	updating chan struct{}
+	clicked  chan struct{}
+	state    int
+}
+
+func (s *slowClicker) Update() (bool, error) {
+	s.state++
+	select {
+	case s.updating <- struct{}{}:
+	default:
+	}
+	time.Sleep(20 * time.Millisecond)
+	s.state++
+	return true, nil
+}
+
+func (s *slowClicker) Click(button int) error {
+	s.state += button
+	s.clicked <- struct{}{}
+	return nil
+}
+
+func TestClickDuringUpdate(t *testing.T) {
+	s := &slowClicker{countingRoutine: countingRoutine{name: "slow"}, updating: make(chan struct{}, 1), clicked: make(chan struct{}, 1)}
+	sb := New()
+	sb.Append(s, 60)
+	r := sb.routines[0]
+
+	sb.mutex.Lock()
+	sb.finished = make(chan *routine, 1)
+	sb.running = true
+	sb.startRoutine(r)
+	sb.mutex.Unlock()
+
+	// Click while the first update is still running. The click has to wait for the update to finish, which the race
+	// detector checks.
+	<-s.updating
+	sb.clickRoutine(r, 1)
+	select {
+	case <-s.clicked:
+	case <-time.After(time.Second):
+		t.Fatal("Click was not handled")
+	}
+
+	// The click is followed by another update.
+	select {
+	case <-s.updating:
+	case <-time.After(time.Second):
+		t.Error("Routine was not updated after the click")
+	}
+
+	if !r.stop(1) {
+		t.Error("Failed to stop routine")
+	}
+	<-sb.finished
+}
+
+func TestHistory(t *testing.T) {
+	h := history{History: History{Depth: 3, Resolution: time.Minute}}
+	start := time.Now()
+	for i, temp := range []float64{50, 55, 60, 65, 70} {
+		values := []value.Value{value.NewNumber("temp", temp, "°C"), value.NewBool("hot", temp > 60)}
+		h.record(start.Add(time.Duration(i)*40*time.Second), values)
+	}
+
+	// The samples at 0s, 80s, and 160s are kept, each with the last reading before the next one.
+	want := []float64{55, 65, 70}
+	samples := h.list()
+	if len(samples) != len(want) {
+		t.Fatalf("Expected %d samples, got %d", len(want), len(samples))
+	}
+	for i, s := range samples {
+		if s.values["temp"] != want[i] || len(s.values) != 1 {
+			t.Errorf("Sample %d: expected temp %v, got %v", i, want[i], s.values)
+		}
+	}
+	if h.units["temp"] != "°C" {
+		t.Errorf("Expected unit °C, got %q", h.units["temp"])
+	}
+
+	// The ring buffer wraps around, and shrinking it keeps the newest samples.
+	h.Resolution = 0
+	h.record(start.Add(time.Hour), []value.Value{value.NewNumber("temp", 75, "°C")})
+	h.resize(History{Depth: 2})
+	if samples := h.list(); len(samples) != 2 || samples[0].values["temp"] != 70 || samples[1].values["temp"] != 75 {
+		t.Errorf("Bad samples after resize: %v", samples)
+	}
+
+	// Values with labels are recorded by their keys.
+	h.record(start.Add(2*time.Hour), []value.Value{
+		value.NewNumber("perc", 40, "%").WithLabel("path", "/"),
+		value.NewNumber("perc", 95, "%").WithLabel("path", "/home"),
+	})
+	samples = h.list()
+	if s := samples[len(samples)-1]; s.values[`perc{path="/"}`] != 40 || s.values[`perc{path="/home"}`] != 95 {
+		t.Errorf("Bad sample with labels: %v", s.values)
+	}
+}
+
+// historianRoutine is a routine that draws its history of "perc" values.
+type historianRoutine struct {
+	valuedRoutine
+	history func(key string) []float64
+	drawn   []float64
+}
+
+func (h *historianRoutine) HistoryDepth() int                             { return 3 }
+func (h *historianRoutine) SetHistory(samples func(key string) []float64) { h.history = samples }
+
+func (h *historianRoutine) Segments() []markup.Segment {
+	h.drawn = h.history("perc")
+	return []markup.Segment{{Text: fmt.Sprint(h.drawn)}}
+}
+
+func TestHistorian(t *testing.T) {
+	sb := New()
+	h := new(historianRoutine)
+	sb.Append(h, 1)
+
+	// The history keeps the samples that the routine draws without being asked to, and each update
+	// draws its own values too.
+	for _, perc := range []float64{10, 20, 30, 40} {
+		h.values = []value.Value{value.NewNumber("perc", perc, "%")}
+		if err := sb.UpdateRoutine(0); err != nil {
+			t.Fatalf("Failed to update routine: %v", err)
+		}
+	}
+	if fmt.Sprint(h.drawn) != "[20 30 40]" {
+		t.Errorf("Expected [20 30 40], got %v", h.drawn)
+	}
+
+	// A deeper history keeps more samples, and a shallower one still keeps what the routine draws.
+	sb.routines[0].setHistory(History{Depth: 5})
+	h.values = []value.Value{value.NewNumber("perc", 50, "%")}
+	if err := sb.UpdateRoutine(0); err != nil || fmt.Sprint(h.drawn) != "[20 30 40 50]" {
+		t.Errorf("Expected [20 30 40 50], got %v (%v)", h.drawn, err)
+	}
+	sb.routines[0].setHistory(History{Depth: 1})
+	if samples, _ := sb.routines[0].historySamples(); len(samples) != 3 {
+		t.Errorf("Expected 3 samples, got %d", len(samples))
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	sb := New()
+	sb.Append(&valuedRoutine{values: []value.Value{
+		value.NewNumber("temp", 61.5, "°C"), value.NewBool("hot", true), value.NewText("status", "ok"),
+	}}, 5)
+	sb.Append(&countingRoutine{name: "time"}, 1)
+	sb.Append(&valuedRoutine{values: []value.Value{
+		value.NewNumber("perc", 40, "%").WithLabel("path", "/"),
+		value.NewNumber("perc", 70, "%").WithLabel("path", "/root").WithLabel("name", "reserved"),
+	}}, 5)
+	sb.Append(&valuedRoutine{values: []value.Value{value.NewNumber("perc", 10, "%").WithLabel("path", "/")}}, 5)
+	sb.routines[0].setModuleName("sbcputemp")
+	sb.routines[1].setModuleName("sb\"time\"")
+	sb.routines[2].setModuleName("sbdisk")
+	sb.routines[3].setModuleName("sbdisk")
+	for _, r := range sb.routines {
+		r.setActive(true)
+		r.countUpdate(250*time.Millisecond, fmt.Errorf("failed"))
+		r.countUpdate(time.Second, nil)
+		r.setOutput(r.collectOutput(nil))
+	}
+
+	recorder := httptest.NewRecorder()
+	metricsHandler{&sb}.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, want := range []string{
+		"# TYPE statusbar_routine_errors_total counter\n",
+		`statusbar_routine_active{routine="sbcputemp",index="0"} 1` + "\n",
+		`statusbar_routine_interval_seconds{routine="sbcputemp",index="0"} 5` + "\n",
+		`statusbar_routine_update_duration_seconds{routine="sb\"time\"",index="1"} 1` + "\n",
+		`statusbar_routine_updates_total{routine="sbcputemp",index="0"} 2` + "\n",
+		`statusbar_routine_errors_total{routine="sbcputemp",index="0"} 1` + "\n",
+		`statusbar_routine_value{routine="sbcputemp",index="0",name="temp",unit="°C"} 61.5` + "\n",
+		`statusbar_routine_value{routine="sbcputemp",index="0",name="hot",unit=""} 1` + "\n",
+
+		// Values with the same name are told apart by their labels, and routines of the same module by their index.
+		// Reserved labels are left out.
+		`statusbar_routine_value{routine="sbdisk",index="2",name="perc",unit="%",path="/"} 40` + "\n",
+		`statusbar_routine_value{routine="sbdisk",index="2",name="perc",unit="%",path="/root"} 70` + "\n",
+		`statusbar_routine_value{routine="sbdisk",index="3",name="perc",unit="%",path="/"} 10` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in metrics:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `name="status"`) {
+		t.Errorf("Expected text values to be left out:\n%s", body)
+	}
+}
+
+func TestRoutineValues(t *testing.T) {
+	sb := New()
+	sb.Append(&valuedRoutine{values: []value.Value{value.NewNumber("perc", 40, "%"), value.NewBool("muted", true)}}, 1)
+	sb.Append(&valuedRoutine{values: []value.Value{value.NewNumber("perc", 95, "%").WithLabel("path", "/home")}}, 1)
+	sb.routines[0].setModuleName("sbvolume")
+	sb.routines[1].setModuleName("sbdisk")
+	for _, r := range sb.routines {
+		r.setOutput(r.collectOutput(nil))
+	}
+
+	params := restapi.Params{"routine": "sbvolume"}
+	code, body := apiHandler{&sb}.HandleGetRoutineValues(restapi.Endpoint{}, params, nil)
+	want := `{"values":[{"name":"perc","kind":"number","value":40,"unit":"%"},{"name":"muted","kind":"bool","value":true}]}`
+	if code != 200 || body != want {
+		t.Errorf("Expected %s, got %d %s", want, code, body)
+	}
+
+	params = restapi.Params{"routine": "sbdisk"}
+	code, body = apiHandler{&sb}.HandleGetRoutineValues(restapi.Endpoint{}, params, nil)
+	want = `{"values":[{"name":"perc","kind":"number","value":95,"unit":"%","labels":{"path":"/home"}}]}`
+	if code != 200 || body != want {
+		t.Errorf("Expected %s, got %d %s", want, code, body)
+	}
+}
+
+func TestRoutineNames(t *testing.T) {
+	sb := New()
+	for i, module := range []string{"sbdisk", "sbtime", "sbdisk"} {
+		sb.Append(&countingRoutine{name: module}, 1)
+		sb.routines[i].setModuleName(module)
+	}
+
+	want := []string{"sbdisk", "sbtime", "sbdisk-2"}
+	if names := routineNames(sb.routines); fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("Expected names %v, got %v", want, names)
+	}
+
+	tests := []struct {
+		name  string
+		index int
+	}{
+		{"sbdisk", 0},
+		{"sbtime", 1},
+		{"sbdisk-2", 2},
+		{"0", 0},
+		{"2", 2},
+		{"3", -1},
+		{"-1", -1},
+		{"sbdisk-3", -1},
+		{"sbtime-1", -1},
+	}
+	for _, test := range tests {
+		index, err := getRoutineIndex(sb.routines, test.name)
+		if index != test.index || (err != nil) != (test.index < 0) {
+			t.Errorf("%s: expected index %d, got %d (%v)", test.name, test.index, index, err)
+		}
+	}
+
+	code, body := apiHandler{&sb}.HandleGetRoutineAll(restapi.Endpoint{}, nil, nil)
+	for _, name := range want {
+		if code != 200 || !strings.Contains(body, `"`+name+`":{`) {
+			t.Errorf("Expected routine %s, got %d %s", name, code, body)
+		}
+	}
+
+	code, body = apiHandler{&sb}.HandleGetRoutine(restapi.Endpoint{}, restapi.Params{"routine": "2"}, nil)
+	if code != 200 || !strings.HasPrefix(body, `{"sbdisk-2":{"name":"sbdisk",`) {
+		t.Errorf("Expected the second disk routine, got %d %s", code, body)
+	}
+}
+
+func TestPostLayoutRoutine(t *testing.T) {
+	// The options are decoded by the registry, so this works without the config package.
+	type options struct {
+		Name  string        `config:"name,required"`
+		Every time.Duration `config:"every"`
+	}
+	registry.Register(registry.Module{
+		Name:    "sbposttest",
+		Options: func() interface{} { return &options{Every: time.Minute} },
+		New: func(o interface{}, t theme.Theme) (registry.Routine, error) {
+			return &countingRoutine{name: o.(*options).Name + " " + o.(*options).Every.String()}, nil
+		},
+	})
+
+	sb := New()
+	post := func(body string) (int, string) {
+		request := httptest.NewRequest("POST", "/layout/routines", strings.NewReader(body))
+		return apiHandler{&sb}.HandlePostLayoutRoutine(restapi.Endpoint{}, nil, request)
+	}
+
+	code, body := post(`{"module": "sbposttest", "interval": 1, "options": {"name": "a", "every": "5s"}}`)
+	if code != 201 {
+		t.Fatalf("Expected routine to be added, got %d %s", code, body)
+	}
+	if routines := sb.routineList(); len(routines) != 1 || routines[0].handler.Name() != "a 5s" {
+		t.Errorf("Expected routine with decoded options, got %d routines", len(routines))
+	}
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"module": "sbposttest", "interval": 1, "options": {"name": "b", "color": "red"}}`, "color: unknown key"},
+		{`{"module": "sbposttest", "interval": 1, "options": {"every": "5s"}}`, "name: missing required key"},
+		{`{"module": "sbposttest", "interval": 1, "options": {"name": 5}}`, "name: expected string, found number"},
+	}
+	for _, test := range tests {
+		code, body := post(test.body)
+		if code != 400 || !strings.Contains(body, test.want) {
+			t.Errorf("%s: expected error %q, got %d %s", test.body, test.want, code, body)
+		}
+	}
+	if n := len(sb.routineList()); n != 1 {
+		t.Errorf("Expected bad requests to leave the layout alone, got %d routines", n)
+	}
+}
+
+type alertCapture chan Alert
+
+func (c alertCapture) Notify(alert Alert) error { c <- alert; return nil }
+
+func TestAlerts(t *testing.T) {
+	sb := New()
+	sb.Append(&countingRoutine{name: "Battery"}, 1, WithAlerts(
+		AlertRule{Name: "low", When: "perc < 10", ClearWhen: "perc >= 15", For: time.Minute, Critical: true},
+		AlertRule{Name: "bad", When: "perc <"},
+	))
+	r := sb.routines[0]
+	r.setModuleName("sbbattery")
+	if len(r.alerts) != 1 {
+		t.Fatalf("Expected invalid rule to be left out, got %d rules", len(r.alerts))
+	}
+	rule := r.alerts[0]
+
+	// The alert is raised once the value has stayed low for a minute, and it stays raised until the
+	// value climbs back past the clear threshold.
+	start := time.Now()
+	tests := []struct {
+		perc    float64
+		after   time.Duration
+		raised  bool
+		cleared bool
+	}{
+		{8, 0, false, false},
+		{12, 30 * time.Second, false, false},
+		{8, 40 * time.Second, false, false},
+		{7, 90 * time.Second, false, false},
+		{6, 100 * time.Second, true, false},
+		{12, 110 * time.Second, false, false},
+		{15, 120 * time.Second, false, true},
+	}
+	for i, test := range tests {
+		values := []value.Value{value.NewNumber("perc", test.perc, "%")}
+		alert := rule.check(r, start.Add(test.after), values)
+		switch {
+		case test.raised && (alert == nil || !alert.Cleared.IsZero()):
+			t.Errorf("Test %d: expected alert to be raised, got %v", i, alert)
+		case test.cleared && (alert == nil || alert.Cleared.IsZero()):
+			t.Errorf("Test %d: expected alert to be cleared, got %v", i, alert)
+		case !test.raised && !test.cleared && alert != nil:
+			t.Errorf("Test %d: expected no alert, got %v", i, alert)
+		}
+	}
+
+	// A raised alert is sent to every notifier and listed until it is cleared.
+	capture := make(alertCapture, 1)
+	sb.AddAlertNotifier(capture)
+	r.sendAlert = sb.sendAlert
+	r.setAlerts([]*alertRule{{rule: AlertRule{Name: "low", When: "perc < 10"}, when: rule.when}})
+	r.checkAlerts(output{values: []value.Value{value.NewNumber("perc", 5, "%")}})
+	select {
+	case alert := <-capture:
+		if alert.Routine != "sbbattery" || alert.Summary != "Battery: low" || alert.Message != "perc < 10" {
+			t.Errorf("Bad alert: %+v", alert)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected alert to be sent")
+	}
+	if alerts := r.raisedAlerts(); len(alerts) != 1 {
+		t.Errorf("Expected 1 raised alert, got %d", len(alerts))
+	}
+
+	r.checkAlerts(output{values: []value.Value{value.NewNumber("perc", 50, "%")}})
+	if alert := <-capture; alert.Cleared.IsZero() {
+		t.Errorf("Expected alert to be cleared: %+v", alert)
+	}
+	if alerts := r.raisedAlerts(); len(alerts) != 0 {
+		t.Errorf("Expected no raised alerts, got %d", len(alerts))
+	}
+}
+
+type logCapture struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+// Write keeps the lines about sbweather, leaving out anything logged by routines from other tests that are still
+// shutting down.
+func (c *logCapture) Write(p []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if bytes.Contains(p, []byte("routine=sbweather")) {
+		c.lines = append(c.lines, string(p))
+	}
+	return len(p), nil
+}
+
+// These are D-Bus messages captured from a session bus run by dbus-daemon, in hex.
+var (
+	// dbus-send calling GetNameOwner("org.freedesktop.DBus") as its second call.
+	dbusGetNameOwner = "6c01000119000000020000007f00000001016f00150000002f6f72672f667265656465736b746f702f44427573000000" +
+		"02017300140000006f72672e667265656465736b746f702e4442757300000000030173000c0000004765744e616d654f" +
+		"776e65720000000006017300140000006f72672e667265656465736b746f702e44427573000000000801670001730000" +
+		"140000006f72672e667265656465736b746f702e4442757300"
+
+	// The bus's replies to a client that called Hello and then GetNameOwner: the reply to Hello, the
+	// NameAcquired signal, and the reply to GetNameOwner.
+	dbusRepliesOK = "6c02010109000000010000003d00000006017300040000003a312e300000000005017500010000000801670001730000" +
+		"07017300140000006f72672e667265656465736b746f702e4442757300000000040000003a312e30006c040101090000" +
+		"00020000008d00000001016f00150000002f6f72672f667265656465736b746f702f4442757300000002017300140000" +
+		"006f72672e667265656465736b746f702e4442757300000000030173000c0000004e616d654163717569726564000000" +
+		"0006017300040000003a312e3000000000080167000173000007017300140000006f72672e667265656465736b746f70" +
+		"2e4442757300000000040000003a312e30006c02010119000000030000003d00000006017300040000003a312e300000" +
+		"00000501750002000000080167000173000007017300140000006f72672e667265656465736b746f702e444275730000" +
+		"0000140000006f72672e667265656465736b746f702e4442757300"
+
+	// The bus's replies to a client that called Hello and then a service that isn't running: the reply
+	// to Hello, the NameAcquired signal, and a ServiceUnknown error.
+	dbusRepliesError = "6c02010109000000010000003d00000006017300040000003a312e310000000005017500010000000801670001730000" +
+		"07017300140000006f72672e667265656465736b746f702e4442757300000000040000003a312e31006c040101090000" +
+		"00020000008d00000001016f00150000002f6f72672f667265656465736b746f702f4442757300000002017300140000" +
+		"006f72672e667265656465736b746f702e4442757300000000030173000c0000004e616d654163717569726564000000" +
+		"0006017300040000003a312e3100000000080167000173000007017300140000006f72672e667265656465736b746f70" +
+		"2e4442757300000000040000003a312e31006c03010152000000030000007500000006017300040000003a312e310000" +
+		"000004017300290000006f72672e667265656465736b746f702e444275732e4572726f722e53657276696365556e6b6e" +
+		"6f776e000000000000000501750002000000080167000173000007017300140000006f72672e667265656465736b746f" +
+		"702e44427573000000004d000000546865206e616d65206f72672e667265656465736b746f702e4e6f74696669636174" +
+		"696f6e7320776173206e6f742070726f766964656420627920616e79202e736572766963652066696c657300"
+
+	// dbusNotifier calling Hello and then Notify for a critical alert, which the bus accepted.
+	dbusNotifyCalls = "6c01000100000000010000006d00000001016f00150000002f6f72672f667265656465736b746f702f44427573000000" +
+		"02017300140000006f72672e667265656465736b746f702e4442757300000000030173000500000048656c6c6f000000" +
+		"06017300140000006f72672e667265656465736b746f702e44427573000000006c0100015c000000020000009b000000" +
+		"01016f001e0000002f6f72672f667265656465736b746f702f4e6f74696669636174696f6e730000020173001d000000" +
+		"6f72672e667265656465736b746f702e4e6f74696669636174696f6e7300000003017300060000004e6f746966790000" +
+		"060173001d0000006f72672e667265656465736b746f702e4e6f74696669636174696f6e73000000080167000d737573" +
+		"73736173617b73767d69000000000000090000007374617475736261720000000000000000000000000000000b000000" +
+		"42617474657279206c6f77000d0000004261747465727920617420392500000000000000100000000700000075726765" +
+		"6e63790001790002ffffffff"
+)
+
+// decodeHex decodes a hex capture.
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDBusMessage(t *testing.T) {
+	// Encoding a method call gives the same bytes as dbus-send.
+	body := new(dbusEncoder)
+	body.string("org.freedesktop.DBus")
+	msg := dbusMessage(2, "/org/freedesktop/DBus", "org.freedesktop.DBus", "GetNameOwner", "org.freedesktop.DBus", "s",
+		body.b)
+	if want := decodeHex(t, dbusGetNameOwner); !bytes.Equal(msg, want) {
+		t.Errorf("Bad method call:\nexpected %x\ngot      %x", want, msg)
+	}
+
+	// Decoding the bus's messages finds their types, the calls that they reply to, and their errors.
+	r := bytes.NewReader(decodeHex(t, dbusRepliesError))
+	for i, want := range []struct {
+		kind    byte
+		reply   uint32
+		errName string
+	}{
+		{dbusMethodReturn, 1, ""},
+		{4, 0, ""}, // The NameAcquired signal.
+		{dbusError, 2, "org.freedesktop.DBus.Error.ServiceUnknown"},
+	} {
+		kind, reply, errName, err := readDBusMessage(r)
+		if err != nil {
+			t.Fatalf("Message %d: %v", i, err)
+		}
+		if kind != want.kind || reply != want.reply || errName != want.errName {
+			t.Errorf("Message %d: expected (%d, %d, %q), got (%d, %d, %q)", i, want.kind, want.reply, want.errName,
+				kind, reply, errName)
+		}
+	}
+	if r.Len() != 0 {
+		t.Errorf("Expected every message to be read, %d bytes left", r.Len())
+	}
+
+	if err := readDBusReply(bytes.NewReader(decodeHex(t, dbusRepliesOK)), 2); err != nil {
+		t.Errorf("Expected successful reply, got %v", err)
+	}
+	err := readDBusReply(bytes.NewReader(decodeHex(t, dbusRepliesError)), 2)
+	if err == nil || !strings.Contains(err.Error(), "ServiceUnknown") {
+		t.Errorf("Expected ServiceUnknown error, got %v", err)
+	}
+
+	// The error is the third message, after the 89 bytes of the reply to Hello and the 169 bytes of the signal.
+	errorMsg := decodeHex(t, dbusRepliesError)[89+169:]
+	fieldsEnd := 16 + int(binary.LittleEndian.Uint32(errorMsg[12:]))
+	reply, errName, err := dbusHeaderFields(errorMsg[:fieldsEnd], binary.LittleEndian)
+	if err != nil || reply != 2 || errName != "org.freedesktop.DBus.Error.ServiceUnknown" {
+		t.Errorf("Bad header fields: (%d, %q, %v)", reply, errName, err)
+	}
+
+	// Decoding a header that is cut off never reads past its end, and a field that is cut off is invalid.
+	for end := 16; end < fieldsEnd; end++ {
+		dbusHeaderFields(errorMsg[:end], binary.LittleEndian)
+	}
+	for _, end := range []int{17, 20, 30, 50, 60, fieldsEnd - 1} {
+		if _, _, err := dbusHeaderFields(errorMsg[:end], binary.LittleEndian); err == nil {
+			t.Errorf("Expected error for header cut off at %d", end)
+		}
+	}
+}
+
+func TestDBusNotifier(t *testing.T) {
+	alert := Alert{Summary: "Battery low", Message: "Battery at 9%", Critical: true}
+	for _, test := range []struct {
+		replies string
+		err     string
+	}{
+		{dbusRepliesOK, ""},
+		{dbusRepliesError, "ServiceUnknown"},
+	} {
+		client, server := net.Pipe()
+		n := &dbusNotifier{dial: func(string) (net.Conn, error) { return client, nil }}
+
+		// Play the bus: check the login and the calls, and then send the replies.
+		calls := make(chan []byte, 1)
+		go func(replies []byte) {
+			defer server.Close()
+			r := bufio.NewReader(server)
+			uid := fmt.Sprintf("%x", strconv.Itoa(os.Getuid()))
+			if line, err := r.ReadString('\n'); err != nil || line != "\x00AUTH EXTERNAL "+uid+"\r\n" {
+				calls <- nil
+				return
+			}
+			io.WriteString(server, "OK 885b5975ce37eedacf67cd506ad21a7a\r\n")
+			if line, err := r.ReadString('\n'); err != nil || line != "BEGIN\r\n" {
+				calls <- nil
+				return
+			}
+
+			b := make([]byte, len(dbusNotifyCalls)/2)
+			if _, err := io.ReadFull(r, b); err != nil {
+				calls <- nil
+				return
+			}
+			calls <- b
+			server.Write(replies)
+		}(decodeHex(t, test.replies))
+
+		err := n.Notify(alert)
+		if got := <-calls; !bytes.Equal(got, decodeHex(t, dbusNotifyCalls)) {
+			t.Errorf("Bad calls:\nexpected %s\ngot      %x", dbusNotifyCalls, got)
+		}
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("Expected no error, got %v", err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("Expected %s error, got %v", test.err, err)
+		}
+	}
+
+	// Cleared alerts aren't shown, so the bus isn't called.
+	n := &dbusNotifier{dial: func(string) (net.Conn, error) { return nil, fmt.Errorf("dialed") }}
+	alert.Cleared = time.Now()
+	if err := n.Notify(alert); err != nil {
+		t.Errorf("Expected cleared alert to be skipped, got %v", err)
+	}
+	if _, err := dialDBus("tcp:host=localhost,port=1234"); err == nil {
+		t.Error("Expected error for unsupported address")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	bodies := make(chan string, 2)
+	status := 200
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			b = []byte("bad request")
+		}
+		bodies <- string(b)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	since := time.Date(2021, time.April, 5, 13, 2, 0, 0, time.UTC)
+	alert := Alert{Routine: "sbbattery", Name: "low", Summary: "Battery: low", Message: "perc < 10", Critical: true,
+		Since: since}
+	n := NewWebhookNotifier(server.URL)
+	if err := n.Notify(alert); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"status":"firing","routine":"sbbattery","name":"low","summary":"Battery: low","message":"perc \u003c 10",` +
+		`"critical":true,"since":"2021-04-05T13:02:00Z"}`
+	if body := <-bodies; body != want {
+		t.Errorf("Expected %s, got %s", want, body)
+	}
+
+	alert.Cleared = since.Add(time.Minute)
+	if err := n.Notify(alert); err != nil {
+		t.Fatal(err)
+	}
+	want = `{"status":"resolved","routine":"sbbattery","name":"low","summary":"Battery: low","message":"perc \u003c 10",` +
+		`"critical":true,"since":"2021-04-05T13:02:00Z","cleared":"2021-04-05T13:03:00Z"}`
+	if body := <-bodies; body != want {
+		t.Errorf("Expected %s, got %s", want, body)
+	}
+
+	status = 500
+	if err := n.Notify(alert); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected error for status 500, got %v", err)
+	}
+	<-bodies
+}
+
+func TestExecNotifier(t *testing.T) {
+	dir := t.TempDir()
+	args := filepath.Join(dir, "args")
+	command := filepath.Join(dir, "notify")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + args + "\n"
+	if err := ioutil.WriteFile(command, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	n := NewExecNotifier(command)
+	if err := n.Notify(Alert{Summary: "Battery: low", Message: "perc < 10", Critical: true}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(args)
+	if want := "-a\nstatusbar\n-u\ncritical\nBattery: low\nperc < 10\n"; err != nil || string(b) != want {
+		t.Errorf("Expected arguments %q, got %q (%v)", want, b, err)
+	}
+
+	// Cleared alerts aren't shown.
+	os.Remove(args)
+	if err := n.Notify(Alert{Summary: "Battery: low", Cleared: time.Now()}); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(args); err == nil {
+		t.Error("Expected command not to run for a cleared alert")
+	}
+
+	// A failing command's output is part of the error.
+	script = "#!/bin/sh\necho no server >&2\nexit 1\n"
+	if err := ioutil.WriteFile(command, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(Alert{Summary: "Battery: low"}); err == nil || !strings.Contains(err.Error(), "no server") {
+		t.Errorf("Expected error with the command's output, got %v", err)
+	}
+}
+
+func TestLogs(t *testing.T) {
+	capture := new(logCapture)
+	SetLogger(NewLogger(capture, LevelWarn))
+	defer SetLogger(nil)
+
+	sb := New()
+	sb.Append(&failingRoutine{}, 1)
+	r := sb.routines[0]
+	r.setModuleName("sbweather")
+	r.log(LevelInfo, "Routine stopped")
+	r.collectOutput(fmt.Errorf("connection refused"))
+	r.log(LevelError, "Odd", "key")
+
+	// Only the messages at the logger's level are written, but every message is kept for the routine.
+	want := []string{
+		` WARN Update failed routine=sbweather error="connection refused"` + "\n",
+		` ERROR Odd routine=sbweather !BADKEY=key` + "\n",
+	}
+	if len(capture.lines) != len(want) {
+		t.Fatalf("Expected %d lines, got %q", len(want), capture.lines)
+	}
+	for i, line := range capture.lines {
+		if !strings.HasSuffix(line, want[i]) {
+			t.Errorf("Line %d: expected %q, got %q", i, want[i], line)
+		}
+	}
+
+	code, body := apiHandler{&sb}.HandleGetRoutineLogs(restapi.Endpoint{}, restapi.Params{"routine": "sbweather"}, nil)
+	for _, s := range []string{`"level":"info","message":"Routine stopped"}`, `"fields":{"error":"connection refused"}`} {
+		if code != 200 || !strings.Contains(body, s) {
+			t.Errorf("Expected %s in %d %s", s, code, body)
+		}
+	}
+
+	// Only the newest entries are kept.
+	for i := 0; i < logDepth+5; i++ {
+		r.log(LevelDebug, "Tick", "n", i)
+	}
+	entries := r.logEntries()
+	if len(entries) != logDepth || entries[0].fields[1] != 5 || entries[logDepth-1].fields[1] != logDepth+4 {
+		t.Errorf("Bad entries after wrapping: %v ... %v", entries[0], entries[len(entries)-1])
+	}
+}
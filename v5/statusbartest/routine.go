@@ -0,0 +1,97 @@
+package statusbartest
+
+import (
+	"sync"
+
+	"github.com/snhilde/statusbar/v5/value"
+)
+
+// Routine is a statusbar.RoutineHandler whose output is set by the test. It reports its values
+// through statusbar.Valuer. Its module name is "statusbartest".
+type Routine struct {
+	mutex   sync.Mutex
+	name    string
+	text    string
+	err     error
+	values  []value.Value
+	updates int
+}
+
+// NewRoutine returns a Routine with the display name and output.
+func NewRoutine(name string, text string) *Routine {
+	return &Routine{name: name, text: text}
+}
+
+// Set sets the output that the routine shows after its next update, and makes its updates succeed
+// again.
+func (r *Routine) Set(text string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.text, r.err = text, nil
+}
+
+// Fail makes the routine's updates fail with err.
+func (r *Routine) Fail(err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.err = err
+}
+
+// SetValues sets the values that the routine reports after its next update.
+func (r *Routine) SetValues(values ...value.Value) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.values = values
+}
+
+// Updates returns the number of times that the routine was updated.
+func (r *Routine) Updates() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.updates
+}
+
+// Update counts the update and returns the error set with Fail, if any.
+func (r *Routine) Update() (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.updates++
+	return true, r.err
+}
+
+// String returns the output set with Set.
+func (r *Routine) String() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.text
+}
+
+// Error returns the message of the error set with Fail.
+func (r *Routine) Error() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.err == nil {
+		return ""
+	}
+	return r.err.Error()
+}
+
+// Name returns the routine's display name.
+func (r *Routine) Name() string {
+	return r.name
+}
+
+// Values returns the values set with SetValues.
+func (r *Routine) Values() []value.Value {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]value.Value(nil), r.values...)
+}
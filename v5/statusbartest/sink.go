@@ -0,0 +1,57 @@
+package statusbartest
+
+import (
+	"sync"
+)
+
+// Sink is a statusbar.Sink that keeps every bar that is written to it.
+type Sink struct {
+	mutex  sync.Mutex
+	bars   []string
+	closed bool
+}
+
+// Write keeps the bar.
+func (s *Sink) Write(bar string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.bars = append(s.bars, bar)
+	return nil
+}
+
+// Close marks the sink as closed.
+func (s *Sink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	return nil
+}
+
+// Bars returns every bar that was written, from first to last.
+func (s *Sink) Bars() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string(nil), s.bars...)
+}
+
+// Last returns the last bar that was written, or "" if no bars were written.
+func (s *Sink) Last() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.bars) == 0 {
+		return ""
+	}
+	return s.bars[len(s.bars)-1]
+}
+
+// Closed returns whether or not the sink was closed.
+func (s *Sink) Closed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.closed
+}
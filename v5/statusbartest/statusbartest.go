@@ -0,0 +1,86 @@
+// Package statusbartest drives a statusbar by hand for fast, repeatable tests of the engine and of
+// modules.
+//
+// A Driver holds a statusbar that never runs on its own. Its routines are only updated when the test
+// calls Update, Step, or UpdateAll, and the bar is only composed when the test calls Draw or Step,
+// so the test can check the exact bar that would be displayed, markers, regions, and shortened
+// output included. The statusbar reads the time from a fake Clock that only moves when the test
+// moves it, which makes slot rotations, marquees, histories, and alerts predictable. Every drawn bar
+// is also written to a Sink that keeps it.
+//
+// This is a test that checks the bar for two routines:
+//
+//	d := statusbartest.New()
+//	d.Statusbar.Append(statusbartest.NewRoutine("Time", "13:02"), 1)
+//	d.Statusbar.Append(sbtodo.New(path), 5)
+//	if bar := d.Step(); bar != "[13:02] [Buy milk]" {
+//		t.Errorf("Unexpected bar %q", bar)
+//	}
+//
+// Nothing here sleeps or waits for a timer, so tests run in milliseconds, even with -race.
+package statusbartest
+
+import (
+	"time"
+
+	"github.com/snhilde/statusbar/v5"
+)
+
+// Start is the time that each Driver's clock starts at.
+var Start = time.Date(2021, time.April, 5, 13, 2, 0, 0, time.UTC)
+
+// Driver drives a statusbar by hand.
+type Driver struct {
+	// Statusbar that is driven. Add routines and change settings on it directly, but don't run it.
+	Statusbar *statusbar.Statusbar
+
+	// Clock that the statusbar reads the time from. It starts at Start.
+	Clock *Clock
+
+	// Sink that keeps every bar that is drawn.
+	Sink *Sink
+}
+
+// New returns a Driver with a new statusbar, which reads the time from a fake clock and writes its
+// bars to a capturing sink. The statusbar is otherwise set up like statusbar.New sets it up.
+func New() *Driver {
+	sb := statusbar.New()
+	d := &Driver{Statusbar: &sb, Clock: NewClock(Start), Sink: new(Sink)}
+	sb.SetClock(d.Clock)
+	sb.AddSink(d.Sink)
+
+	return d
+}
+
+// Update runs one update of the routine at index and returns the error from the update. See
+// statusbar.Statusbar.UpdateRoutine.
+func (d *Driver) Update(index int) error {
+	return d.Statusbar.UpdateRoutine(index)
+}
+
+// UpdateAll runs one update of every routine, in order. It returns the first error from the updates,
+// but it updates every routine either way.
+func (d *Driver) UpdateAll() error {
+	var first error
+	for i := range d.Statusbar.Routines() {
+		if err := d.Update(i); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// Draw composes the bar from the latest output of every routine, writes it to the sink, and returns
+// it.
+func (d *Driver) Draw() string {
+	return d.Statusbar.Draw()
+}
+
+// Step updates every routine and then draws the bar, like one tick of a running statusbar whose
+// routines all run on the same interval. Routines whose updates fail show their error on the bar.
+func (d *Driver) Step() string {
+	// The errors are already on the bar, so there's no need to return them too.
+	_ = d.UpdateAll()
+	return d.Draw()
+}
@@ -0,0 +1,95 @@
+package statusbartest_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/snhilde/statusbar/v5"
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/sbtodo"
+	"github.com/snhilde/statusbar/v5/statusbartest"
+)
+
+func TestDriver(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "todo")
+	if err := ioutil.WriteFile(path, []byte("Buy milk\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d := statusbartest.New()
+	clock := statusbartest.NewRoutine("Time", "13:02")
+	d.Statusbar.Append(clock, 1)
+	d.Statusbar.Append(sbtodo.New(path), 5)
+	d.Statusbar.Split()
+	d.Statusbar.Append(statusbartest.NewRoutine("Long", "abcdefghij"), 1, statusbar.WithWidth(6, markup.EllipsisEnd))
+
+	if bar := d.Step(); bar != "[13:02] [Buy milk];[abc...]" {
+		t.Errorf("Unexpected bar %q", bar)
+	}
+
+	// Nothing changes until the routine is updated again.
+	clock.Set("13:03")
+	if bar := d.Draw(); bar != "[13:02] [Buy milk];[abc...]" {
+		t.Errorf("Unexpected bar %q", bar)
+	}
+	if err := d.Update(0); err != nil {
+		t.Fatal(err)
+	}
+	d.Statusbar.SetMarkers("<", ">")
+	if bar := d.Draw(); bar != "<13:03> <Buy milk>;<abc...>" {
+		t.Errorf("Unexpected bar %q", bar)
+	}
+
+	// Failed updates show the routine's error.
+	clock.Fail(fmt.Errorf("clock stopped"))
+	if err := d.Update(0); err == nil || clock.Updates() != 3 {
+		t.Errorf("Expected the third update to fail, got %v after %d updates", err, clock.Updates())
+	}
+	if bar := d.Draw(); bar != "<clock stopped> <Buy milk>;<abc...>" {
+		t.Errorf("Unexpected bar %q", bar)
+	}
+
+	if bars := d.Sink.Bars(); len(bars) != 4 || bars[3] != d.Sink.Last() {
+		t.Errorf("Expected 4 bars in the sink, got %q", bars)
+	}
+	if err := d.Update(3); err == nil {
+		t.Errorf("Expected error for a missing routine")
+	}
+}
+
+func TestClock(t *testing.T) {
+	t.Parallel()
+
+	d := statusbartest.New()
+	d.Statusbar.Append(statusbartest.NewRoutine("Scroll", "abcdefgh"), 1,
+		statusbar.WithMarquee(statusbar.Marquee{Width: 4, Step: time.Second, Gap: " "}))
+	if err := d.Statusbar.AddSlot(statusbar.Slot{Name: "weather", Period: 10 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	d.Statusbar.Append(statusbartest.NewRoutine("Sun", "sun"), 1, statusbar.WithSlot("weather"))
+	d.Statusbar.Append(statusbartest.NewRoutine("Rain", "rain"), 1, statusbar.WithSlot("weather"))
+	d.Step()
+
+	// The marquee moves one column a second and starts over every 9 seconds, and the slot shows its next
+	// member every 10 seconds.
+	tests := []struct {
+		after time.Duration
+		want  string
+	}{
+		{0, "[abcd] [sun]"},
+		{time.Second, "[bcde] [sun]"},
+		{6 * time.Second, "[h ab] [sun]"},
+		{4 * time.Second, "[cdef] [rain]"},
+	}
+	for i, test := range tests {
+		d.Clock.Advance(test.after)
+		if bar := d.Draw(); bar != test.want {
+			t.Errorf("Test %d: expected %q, got %q", i, test.want, bar)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// This file holds the click markers and signals used by dwm's statuscmd patch.
+
+package statusbar
+
+// maxStatusCmd is the highest marker for statuscmd clicks. Marker n is clicked with the signal
+// SIGRTMIN+n, and there are only 30 real-time signals after SIGRTMIN.
+const maxStatusCmd = 30
+
+// newlineMarker is never used as a marker, because a newline would break up the bar's text in sinks
+// and bars that read it line by line.
+const newlineMarker = '\n'
+
+// EnableStatusCmd turns on clicks through dwm's statuscmd patch (or the statuscmd patch for
+// dwmblocks). The engine places a marker byte before the output of each of the first 29 routines on
+// the bar. Markers follow the routines' current positions on the bar, so they change when routines
+// are inserted, moved, or removed: the first routine is marked with the byte 1, the second with the
+// byte 2, and so on, except that the newline byte 10 is skipped, so the tenth routine is marked with
+// 11 and the 29th with 30. When a routine's output is clicked, dwm finds its marker n and sends
+// SIGRTMIN+n to the statusbar with the mouse button as the signal's value, and the engine passes the
+// click on to the routine if it implements Clicker. dwm's STATUSBAR setting must name the statusbar's
+// program instead of dwmblocks. Because the markers are raw bytes, this should only be turned on for
+// dwm. Without cgo, every click is reported as a left click.
+func (sb *Statusbar) EnableStatusCmd() {
+	sb.statusCmd = true
+}
+
+// statusCmdMarker returns the marker to place before the output of r, or an empty string if r can't
+// be clicked through statuscmd signals. The statusbar's mutex must be held.
+func (sb *Statusbar) statusCmdMarker(r *routine) string {
+	if !sb.statusCmd {
+		return ""
+	}
+
+	for i, candidate := range sb.routines {
+		if candidate == r {
+			if n := statusCmdMarkerAt(i); n > 0 {
+				return string(rune(n))
+			}
+			break
+		}
+	}
+
+	return ""
+}
+
+// handleStatusCmd passes a click signal to the routine with the marker n.
+func (sb *Statusbar) handleStatusCmd(n int, button int) {
+	routines := sb.routineList()
+	i := statusCmdIndex(n)
+	if i < 0 || i >= len(routines) {
+		return
+	}
+
+	sb.clickRoutine(routines[i], button)
+}
+
+// statusCmdMarkerAt returns the marker of the routine at index i on the bar, or 0 if the routine is
+// too far down the bar to have one.
+func statusCmdMarkerAt(i int) int {
+	n := i + 1
+	if n >= newlineMarker {
+		n++
+	}
+	if n > maxStatusCmd {
+		return 0
+	}
+
+	return n
+}
+
+// statusCmdIndex returns the index on the bar of the routine with the marker n, or -1 if n isn't a
+// valid marker.
+func statusCmdIndex(n int) int {
+	switch {
+	case n < 1 || n > maxStatusCmd || n == newlineMarker:
+		return -1
+	case n > newlineMarker:
+		return n - 2
+	}
+
+	return n - 1
+}
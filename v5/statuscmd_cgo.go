@@ -0,0 +1,79 @@
+// +build cgo,linux
+
+// This file listens for the real-time signals that dwm's statuscmd patch sends when the bar is clicked.
+
+package statusbar
+
+// #include <errno.h>
+// #include <signal.h>
+// #include <string.h>
+// #include <unistd.h>
+//
+// static int clickPipe[2] = {-1, -1};
+//
+// // onClick writes the offset of the signal from SIGRTMIN and the button carried by the signal to
+// // the pipe. Go can't read the value of a signal, so this is done in C.
+// static void onClick(int sig, siginfo_t *info, void *context) {
+// 	unsigned char event[2];
+// 	int saved = errno;
+//
+// 	event[0] = sig - SIGRTMIN;
+// 	event[1] = info->si_value.sival_int;
+// 	if (write(clickPipe[1], event, sizeof(event)) < 0) {
+// 		// Nothing can be done about it here.
+// 	}
+// 	errno = saved;
+// }
+//
+// // listenClicks handles SIGRTMIN+1 through SIGRTMIN+count and returns the end of the pipe that
+// // the clicks are read from, or -1 on error.
+// static int listenClicks(int count) {
+// 	struct sigaction sa;
+// 	int i;
+//
+// 	if (pipe(clickPipe) < 0) {
+// 		return -1;
+// 	}
+//
+// 	memset(&sa, 0, sizeof(sa));
+// 	sa.sa_sigaction = onClick;
+// 	sa.sa_flags = SA_SIGINFO | SA_ONSTACK | SA_RESTART;
+// 	sigemptyset(&sa.sa_mask);
+// 	for (i = 1; i <= count && SIGRTMIN + i <= SIGRTMAX; i++) {
+// 		if (sigaction(SIGRTMIN + i, &sa, NULL) < 0) {
+// 			return -1;
+// 		}
+// 	}
+//
+// 	return clickPipe[0];
+// }
+import "C"
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// listenStatusCmd calls handler with the routine's marker and the mouse button every time the
+// program receives one of the first count click signals from dwm.
+func listenStatusCmd(count int, handler func(n int, button int)) error {
+	fd := C.listenClicks(C.int(count))
+	if fd < 0 {
+		return fmt.Errorf("failed to handle click signals")
+	}
+
+	go func() {
+		f := os.NewFile(uintptr(fd), "statuscmd")
+		event := make([]byte, 2)
+		for {
+			if _, err := io.ReadFull(f, event); err != nil {
+				logAt(LevelWarn, "Stopped reading click signals", "error", err)
+				return
+			}
+			handler(int(event[0]), int(event[1]))
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,39 @@
+// +build !cgo !linux
+
+// This file stands in for the statuscmd signal handler when the package is built without cgo or for a
+// system other than Linux.
+
+package statusbar
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+)
+
+// sigrtmin is the first real-time signal available to programs on Linux.
+const sigrtmin = 34
+
+// listenStatusCmd calls handler with the routine's marker every time the program receives one of
+// the first count click signals from dwm. Without cgo, the button carried by the signal can't be
+// read, so every click is reported as a left click.
+func listenStatusCmd(count int, handler func(n int, button int)) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("click signals are only supported on Linux")
+	}
+
+	c := make(chan os.Signal, count)
+	for n := 1; n <= count; n++ {
+		signal.Notify(c, syscall.Signal(sigrtmin+n))
+	}
+
+	go func() {
+		for sig := range c {
+			handler(int(sig.(syscall.Signal))-sigrtmin, 1)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,160 @@
+// This file holds the supervisor, which decides what happens to routines after they stop.
+
+package statusbar
+
+import (
+	"fmt"
+	"time"
+)
+
+// startTimeout is the longest time that start waits for a stopped routine's old run loop to return.
+const startTimeout = 5 * time.Second
+
+// RestartPolicy determines whether or not a routine is started again after it stops on its own.
+// Routines that are stopped on purpose, such as with the REST API, are never restarted
+// automatically.
+type RestartPolicy int
+
+const (
+	// RestartNever leaves a routine stopped. This is the default.
+	RestartNever RestartPolicy = iota
+
+	// RestartOnFailure restarts a routine that stopped because of a critical error or because it
+	// failed too many times in a row (see RetryPolicy).
+	RestartOnFailure
+
+	// RestartAlways restarts a routine whenever it stops on its own, including routines that only
+	// run once.
+	RestartAlways
+)
+
+// String returns the name of the policy, like "on-failure".
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	}
+
+	return fmt.Sprintf("RestartPolicy(%d)", int(p))
+}
+
+// WithRestartPolicy sets whether or not the routine is started again after it stops, and how long
+// to wait before starting it. A restarted routine keeps its place on the bar.
+func WithRestartPolicy(policy RestartPolicy, delay time.Duration) RoutineOption {
+	return func(r *routine) {
+		r.setRestartPolicy(policy, delay)
+	}
+}
+
+// supervise handles a routine that has stopped. If the routine's restart policy says so, the
+// routine is started again after its restart delay.
+func (sb *Statusbar) supervise(r *routine) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	sb.live--
+	if !sb.running || !sb.hasRoutine(r) || !r.shouldRestart() {
+		r.log(LevelInfo, "Routine stopped")
+		return
+	}
+
+	// Count the routine while it's waiting to be restarted so that Run doesn't return in the
+	// meantime.
+	sb.restarting++
+	_, delay := r.restartPolicy()
+	r.log(LevelInfo, "Routine stopped, restarting", "delay", delay)
+	time.AfterFunc(delay, func() {
+		sb.mutex.Lock()
+		defer sb.mutex.Unlock()
+
+		// Don't restart the routine if the statusbar stopped, the routine was removed by a reload,
+		// or the routine was already started again some other way.
+		sb.restarting--
+		if sb.running && sb.hasRoutine(r) && !r.isActive() {
+			r.countRestart()
+			sb.launch(r)
+		}
+
+		// Let Run check whether or not it still has anything to wait for.
+		select {
+		case sb.wake <- struct{}{}:
+		default:
+		}
+	})
+}
+
+// start starts a routine that has stopped, keeping its place on the bar. If the routine was only
+// just stopped, this waits for its old run loop to return first so that two loops never update the
+// same routine.
+func (sb *Statusbar) start(r *routine) error {
+	sb.mutex.RLock()
+	err := sb.checkStart(r)
+	exited := r.exited
+	sb.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	// The old run loop might need the statusbar's mutex to finish, so don't hold it while waiting.
+	if exited != nil {
+		select {
+		case <-exited:
+		case <-time.After(startTimeout):
+			return fmt.Errorf("routine is still stopping")
+		}
+	}
+
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	// Something else might have started the routine while we were waiting.
+	if err := sb.checkStart(r); err != nil {
+		return err
+	}
+	if r.exited != exited {
+		return fmt.Errorf("routine is already running")
+	}
+
+	r.countRestart()
+	sb.launch(r)
+	return nil
+}
+
+// checkStart returns an error if r can't be started. The statusbar's mutex must be held.
+func (sb *Statusbar) checkStart(r *routine) error {
+	switch {
+	case !sb.running:
+		return fmt.Errorf("statusbar is not running")
+	case !sb.hasRoutine(r):
+		return fmt.Errorf("invalid routine")
+	case r.isActive():
+		return fmt.Errorf("routine is already running")
+	}
+
+	return nil
+}
+
+// hasRoutine returns true if r is one of the statusbar's routines. The statusbar's mutex must be
+// held.
+func (sb *Statusbar) hasRoutine(r *routine) bool {
+	for _, candidate := range sb.routines {
+		if candidate == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+// waiting returns true if Run should keep waiting for routines, either because some are still live
+// or waiting to be restarted or because the REST API is running and can start them again.
+func (sb *Statusbar) waiting() bool {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+
+	return sb.live > 0 || sb.restarting > 0 || (sb.running && sb.restPort > 0)
+}
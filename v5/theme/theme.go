@@ -0,0 +1,152 @@
+// Package theme holds the colors that routines use to display their output.
+//
+// Every routine has its own Theme, which maps each output state (normal, warning, and error) to a
+// pair of foreground and background colors. Any colors left unset in a routine's theme are
+// inherited from the theme of the statusbar it is added to. Themes can be built by hand, created
+// from the classic normal/warning/error color triplet with FromColors, or looked up by name from the
+// registered palettes.
+package theme
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/snhilde/statusbar/v5/markup"
+)
+
+// Colors is a pair of foreground and background colors. Each color is a hex code like "#FFFFFF".
+// An empty color means that the color is not set.
+type Colors struct {
+	Foreground string `json:"foreground"`
+	Background string `json:"background"`
+}
+
+// Theme holds the colors to use for each state of a routine's output.
+type Theme struct {
+	// Colors for normal output.
+	Normal Colors `json:"normal"`
+
+	// Colors for output that is approaching a problem.
+	Warning Colors `json:"warning"`
+
+	// Colors for output that is reporting a problem, including error messages.
+	Error Colors `json:"error"`
+}
+
+// FromColors returns a theme with the provided foreground colors for the normal, warning, and error
+// states. This is the same as the color triplets used by earlier versions of the modules.
+func FromColors(normal, warning, error string) Theme {
+	return Theme{
+		Normal:  Colors{Foreground: normal},
+		Warning: Colors{Foreground: warning},
+		Error:   Colors{Foreground: error},
+	}
+}
+
+// Colors returns the colors to use for state.
+func (t Theme) Colors(state markup.State) Colors {
+	switch state {
+	case markup.StateWarning:
+		return t.Warning
+	case markup.StateError:
+		return t.Error
+	}
+
+	return t.Normal
+}
+
+// Segment returns a segment with the provided text and state, colored according to the theme.
+func (t Theme) Segment(text string, state markup.State) markup.Segment {
+	colors := t.Colors(state)
+	return markup.Segment{Text: text, Foreground: colors.Foreground, Background: colors.Background, State: state}
+}
+
+// Apply colors every segment that doesn't have any colors of its own according to its state.
+func (t Theme) Apply(segments []markup.Segment) []markup.Segment {
+	applied := make([]markup.Segment, len(segments))
+	for i, segment := range segments {
+		if segment.Foreground == "" && segment.Background == "" {
+			colors := t.Colors(segment.State)
+			segment.Foreground = colors.Foreground
+			segment.Background = colors.Background
+		}
+		applied[i] = segment
+	}
+
+	return applied
+}
+
+// Inherit returns a copy of the theme with any unset colors filled in from parent.
+func (t Theme) Inherit(parent Theme) Theme {
+	t.Normal = t.Normal.inherit(parent.Normal)
+	t.Warning = t.Warning.inherit(parent.Warning)
+	t.Error = t.Error.inherit(parent.Error)
+
+	return t
+}
+
+// IsZero returns true if none of the theme's colors are set.
+func (t Theme) IsZero() bool {
+	return t == Theme{}
+}
+
+// inherit returns a copy of the colors with any unset colors filled in from parent.
+func (c Colors) inherit(parent Colors) Colors {
+	if c.Foreground == "" {
+		c.Foreground = parent.Foreground
+	}
+	if c.Background == "" {
+		c.Background = parent.Background
+	}
+
+	return c
+}
+
+var (
+	// Palettes that can be looked up by name, as added with RegisterPalette.
+	palettes = map[string]Theme{
+		"dwm":       FromColors("#FFFFFF", "#BB4F2E", "#A1273E"),
+		"gruvbox":   FromColors("#EBDBB2", "#FABD2F", "#FB4934"),
+		"nord":      FromColors("#D8DEE9", "#EBCB8B", "#BF616A"),
+		"solarized": FromColors("#839496", "#B58900", "#DC322F"),
+		"dracula":   FromColors("#F8F8F2", "#F1FA8C", "#FF5555"),
+	}
+	palettesMutex sync.RWMutex
+)
+
+// Palette returns the theme registered under name.
+func Palette(name string) (Theme, error) {
+	palettesMutex.RLock()
+	defer palettesMutex.RUnlock()
+
+	t, ok := palettes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown palette: %s", name)
+	}
+
+	return t, nil
+}
+
+// RegisterPalette adds t to the list of palettes under name, replacing any palette already
+// registered with that name.
+func RegisterPalette(name string, t Theme) {
+	palettesMutex.Lock()
+	defer palettesMutex.Unlock()
+
+	palettes[name] = t
+}
+
+// Palettes returns the names of all registered palettes, sorted alphabetically.
+func Palettes() []string {
+	palettesMutex.RLock()
+	defer palettesMutex.RUnlock()
+
+	names := make([]string, 0, len(palettes))
+	for name := range palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
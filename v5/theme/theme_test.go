@@ -0,0 +1,69 @@
+package theme_test
+
+import (
+	"testing"
+
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/theme"
+)
+
+func TestInherit(t *testing.T) {
+	t.Parallel()
+
+	parent := theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")
+	parent.Error.Background = "#000000"
+
+	child := theme.Theme{Normal: theme.Colors{Foreground: "#17A130"}}
+	got := child.Inherit(parent)
+
+	want := theme.Theme{
+		Normal:  theme.Colors{Foreground: "#17A130"},
+		Warning: theme.Colors{Foreground: "#BB4F2E"},
+		Error:   theme.Colors{Foreground: "#A1273E", Background: "#000000"},
+	}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	th := theme.FromColors("#FFFFFF", "#BB4F2E", "#A1273E")
+	segments := []markup.Segment{
+		{Text: "ok"},
+		{Text: "own color", Foreground: "#123456", State: markup.StateError},
+		{Text: "bad", State: markup.StateError},
+	}
+
+	got := th.Apply(segments)
+	want := []string{"#FFFFFF", "#123456", "#A1273E"}
+	for i, segment := range got {
+		if segment.Foreground != want[i] {
+			t.Errorf("Segment %d: expected %s, got %s", i, want[i], segment.Foreground)
+		}
+	}
+
+	// The original segments should not have been changed.
+	if segments[0].Foreground != "" {
+		t.Errorf("Apply modified the original segments")
+	}
+}
+
+func TestPalette(t *testing.T) {
+	t.Parallel()
+
+	if _, err := theme.Palette("gruvbox"); err != nil {
+		t.Errorf("Failed to find built-in palette: %v", err)
+	}
+
+	if _, err := theme.Palette("does not exist"); err == nil {
+		t.Errorf("Expected error for unknown palette")
+	}
+
+	custom := theme.FromColors("#111111", "#222222", "#333333")
+	theme.RegisterPalette("custom", custom)
+	if got, err := theme.Palette("custom"); err != nil || got != custom {
+		t.Errorf("Expected registered palette %+v, got %+v (%v)", custom, got, err)
+	}
+}
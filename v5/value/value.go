@@ -0,0 +1,170 @@
+// Package value holds the typed values that routines report alongside their output, such as a
+// percentage, a temperature, or a connection status. Unlike the routine's output, values are raw
+// numbers and flags that the engine can compare, record, and export.
+package value
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Kind is the type of a value.
+type Kind int
+
+// These are the possible kinds of values.
+const (
+	// Number is a numeric value, like a percentage or a temperature.
+	Number Kind = iota
+
+	// Bool is a true/false value, like whether or not a battery is charging.
+	Bool
+
+	// Text is a string value, like the name of a connection status.
+	Text
+)
+
+// String returns the name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case Number:
+		return "number"
+	case Bool:
+		return "bool"
+	case Text:
+		return "text"
+	}
+
+	return "unknown"
+}
+
+// Label tells apart values that have the same name, like the path of each disk that a routine
+// reports "perc" for.
+type Label struct {
+	// Name of the label, like "path". Names are made of lowercase letters, digits, and underscores.
+	// The names "routine", "index", "name", and "unit" are used by the statusbar's metrics and can't
+	// be used for labels.
+	Name string
+
+	// Value of the label, like "/home".
+	Value string
+}
+
+// Value is a single named value reported by a routine.
+type Value struct {
+	// Name of the value, like "perc" or "temp". Names are made of lowercase letters, digits, and
+	// underscores.
+	Name string
+
+	// Labels of the value, if the routine reports more than one value with this name.
+	Labels []Label
+
+	// Type of the value, which determines which of the fields below is set.
+	Kind Kind
+
+	// Value of a Number.
+	Number float64
+
+	// Value of a Bool.
+	Bool bool
+
+	// Value of a Text.
+	Text string
+
+	// Unit of a Number, like "%" or "°C". This is empty if the number doesn't have a unit.
+	Unit string
+}
+
+// NewNumber returns a Number value with the unit, like NewNumber("temp", 61, "°C").
+func NewNumber(name string, n float64, unit string) Value {
+	return Value{Name: name, Kind: Number, Number: n, Unit: unit}
+}
+
+// NewBool returns a Bool value.
+func NewBool(name string, b bool) Value {
+	return Value{Name: name, Kind: Bool, Bool: b}
+}
+
+// NewText returns a Text value.
+func NewText(name string, s string) Value {
+	return Value{Name: name, Kind: Text, Text: s}
+}
+
+// WithLabel returns a copy of the value with the label added, like
+// NewNumber("perc", 42, "%").WithLabel("path", "/home").
+func (v Value) WithLabel(name string, val string) Value {
+	labels := make([]Label, len(v.Labels), len(v.Labels)+1)
+	copy(labels, v.Labels)
+	v.Labels = append(labels, Label{Name: name, Value: val})
+
+	return v
+}
+
+// Label returns the value of the label with the name, and whether or not the value has the label.
+func (v Value) Label(name string) (string, bool) {
+	for _, label := range v.Labels {
+		if label.Name == name {
+			return label.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// Key returns the name of the value along with its labels, like `perc{path="/home"}`, which is
+// unique among the values of a routine. A value without labels is keyed by its name alone.
+func (v Value) Key() string {
+	if len(v.Labels) == 0 {
+		return v.Name
+	}
+
+	labels := make([]string, len(v.Labels))
+	for i, label := range v.Labels {
+		labels[i] = label.Name + "=" + strconv.Quote(label.Value)
+	}
+
+	return v.Name + "{" + strings.Join(labels, ",") + "}"
+}
+
+// String formats the value like "perc=42 %", "charging=true", or `perc{path="/home"}=42 %`.
+func (v Value) String() string {
+	return v.Key() + "=" + v.Format()
+}
+
+// Format formats only the value itself, with its unit, like "42 %".
+func (v Value) Format() string {
+	switch v.Kind {
+	case Number:
+		s := strconv.FormatFloat(v.Number, 'f', -1, 64)
+		if v.Unit != "" {
+			s += " " + v.Unit
+		}
+		return s
+	case Bool:
+		return strconv.FormatBool(v.Bool)
+	}
+
+	return v.Text
+}
+
+// Find returns the first value from the list with the name and all of the labels. The value can
+// have other labels too.
+func Find(values []Value, name string, labels ...Label) (Value, bool) {
+	for _, v := range values {
+		if v.Name == name && hasLabels(v, labels) {
+			return v, true
+		}
+	}
+
+	return Value{}, false
+}
+
+// hasLabels returns whether or not v has all of the labels.
+func hasLabels(v Value, labels []Label) bool {
+	for _, label := range labels {
+		if s, ok := v.Label(label.Name); !ok || s != label.Value {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,389 @@
+// This file holds the rules that hide routines from the bar based on their state and values.
+
+package statusbar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/snhilde/statusbar/v5/markup"
+	"github.com/snhilde/statusbar/v5/value"
+)
+
+// VisibilityRule hides a routine from the bar depending on its state and values. Rules are written
+// as expressions like "perc >= 100 && charging == false" or "state == error || rpm > 3000". Each
+// comparison in an expression has the name of a value on the left (see Valuer), one of ==, !=, <,
+// <=, >, or >=, and a number, true or false, or a word (optionally in double quotes) on the right.
+// A name on its own checks that a true/false value is true, and a name with "!" in front checks
+// that it is false. A name can be followed by labels in braces to pick one of several values with
+// that name, like perc{path="/home"} (see value.Label). Comparisons are joined with && and ||,
+// where && is checked first. On top of the values the routine reports, "state" is always available
+// and is one of "normal", "warning", "error", or "failed" (for a failed update). A comparison with
+// a value that the routine doesn't report, or with a value of a different type, is false.
+type VisibilityRule struct {
+	// Name of the rule, which is used to turn the rule on and off through the REST API.
+	Name string
+
+	// Expression that hides the routine when it is true. This can be empty.
+	HideWhen string
+
+	// Expression that hides the routine when it is false. This can be empty.
+	ShowWhen string
+}
+
+// Validate checks that the rule has a name and that its expressions can be parsed.
+func (v VisibilityRule) Validate() error {
+	_, err := compileRule(v)
+	return err
+}
+
+// WithVisibility sets the rules that decide whether or not the routine is shown on the bar. A routine
+// is hidden if any of its rules hides it. Rules that fail to validate (see VisibilityRule.Validate)
+// are logged and left out. Every rule starts out turned on.
+func WithVisibility(rules ...VisibilityRule) RoutineOption {
+	return func(r *routine) {
+		compiled := make([]*visibilityRule, 0, len(rules))
+		for _, rule := range rules {
+			c, err := compileRule(rule)
+			if err != nil {
+				r.log(LevelError, "Invalid visibility rule", "error", err)
+				continue
+			}
+			compiled = append(compiled, c)
+		}
+		r.setVisibility(compiled)
+	}
+}
+
+// visibilityRule is a VisibilityRule with its expressions parsed.
+type visibilityRule struct {
+	rule    VisibilityRule
+	hide    expression
+	show    expression
+	enabled bool
+}
+
+// compileRule checks and parses the rule.
+func compileRule(rule VisibilityRule) (*visibilityRule, error) {
+	if rule.Name == "" {
+		return nil, fmt.Errorf("missing rule name")
+	}
+	if rule.HideWhen == "" && rule.ShowWhen == "" {
+		return nil, fmt.Errorf("rule %s: missing expression", rule.Name)
+	}
+
+	c := visibilityRule{rule: rule, enabled: true}
+	var err error
+	if rule.HideWhen != "" {
+		if c.hide, err = parseExpression(rule.HideWhen); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+	}
+	if rule.ShowWhen != "" {
+		if c.show, err = parseExpression(rule.ShowWhen); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+	}
+
+	return &c, nil
+}
+
+// hides returns whether or not the rule hides a routine with these values.
+func (c *visibilityRule) hides(values []value.Value) bool {
+	if !c.enabled {
+		return false
+	}
+
+	return (c.hide != nil && c.hide.eval(values)) || (c.show != nil && !c.show.eval(values))
+}
+
+// outputValues returns the values that visibility rules are checked against for this output.
+func outputValues(o output) []value.Value {
+	state := markup.WorstState(o.segments).String()
+	if o.failed {
+		state = "failed"
+	}
+
+	return append([]value.Value{value.NewText("state", state)}, o.values...)
+}
+
+// expression is a parsed visibility expression. Each element is a group of comparisons that must
+// all be true, and the expression is true if any group is true.
+type expression [][]comparison
+
+// comparison compares one value with a constant.
+type comparison struct {
+	name     string
+	labels   []value.Label
+	op       string
+	constant value.Value
+}
+
+// parseExpression parses an expression, as described in VisibilityRule.
+func parseExpression(s string) (expression, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	expr := expression{nil}
+	for len(tokens) > 0 {
+		var c comparison
+		if c, tokens, err = parseComparison(tokens); err != nil {
+			return nil, err
+		}
+		expr[len(expr)-1] = append(expr[len(expr)-1], c)
+
+		if len(tokens) == 0 {
+			break
+		}
+		switch tokens[0] {
+		case "&&":
+		case "||":
+			expr = append(expr, nil)
+		default:
+			return nil, fmt.Errorf("expected && or ||, found %q", tokens[0])
+		}
+		tokens = tokens[1:]
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("expression ends with an operator")
+		}
+	}
+
+	if len(expr[0]) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	return expr, nil
+}
+
+// parseComparison parses one comparison from the start of tokens and returns the rest of the tokens.
+func parseComparison(tokens []string) (comparison, []string, error) {
+	negate := tokens[0] == "!"
+	if negate {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return comparison{}, nil, fmt.Errorf("expected a value name")
+	}
+	name, labels, ok := parseSelector(tokens[0])
+	if !ok {
+		return comparison{}, nil, fmt.Errorf("expected a value name, found %q", tokens[0])
+	}
+
+	c := comparison{name: name, labels: labels, op: "==", constant: value.NewBool("", !negate)}
+	tokens = tokens[1:]
+	if negate || len(tokens) == 0 || tokens[0] == "&&" || tokens[0] == "||" {
+		return c, tokens, nil
+	}
+
+	switch tokens[0] {
+	case "==", "!=", "<", "<=", ">", ">=":
+		c.op = tokens[0]
+	default:
+		return comparison{}, nil, fmt.Errorf("expected a comparison after %s, found %q", c.name, tokens[0])
+	}
+	if len(tokens) < 2 || tokens[1] == "&&" || tokens[1] == "||" {
+		return comparison{}, nil, fmt.Errorf("missing value after %s %s", c.name, c.op)
+	}
+
+	c.constant = parseConstant(tokens[1])
+	if c.constant.Kind != value.Number && c.op != "==" && c.op != "!=" {
+		return comparison{}, nil, fmt.Errorf("%s can only be used with numbers", c.op)
+	}
+
+	return c, tokens[2:], nil
+}
+
+// parseConstant parses the right side of a comparison.
+func parseConstant(token string) value.Value {
+	if strings.HasPrefix(token, `"`) {
+		return value.NewText("", token[1:len(token)-1])
+	}
+	if token == "true" || token == "false" {
+		return value.NewBool("", token == "true")
+	}
+	if n, err := strconv.ParseFloat(token, 64); err == nil {
+		return value.NewNumber("", n, "")
+	}
+
+	return value.NewText("", token)
+}
+
+// tokenize splits an expression into names, constants, and operators.
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, s[i:i+end+2])
+			i += end + 2
+		case strings.ContainsRune("=!<>&|", rune(c)):
+			op := s[i : i+1]
+			if i+1 < len(s) {
+				switch two := s[i : i+2]; two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if op == "=" || op == "&" || op == "|" {
+				return nil, fmt.Errorf("unknown operator %q", op)
+			}
+			tokens = append(tokens, op)
+			i += len(op)
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t\"=!<>&|{", rune(s[end])) {
+				end++
+			}
+			// Labels after a name are part of the name's token.
+			if end < len(s) && s[end] == '{' {
+				close := labelsEnd(s, end)
+				if close < 0 {
+					return nil, fmt.Errorf("unterminated labels")
+				}
+				end = close + 1
+			}
+			tokens = append(tokens, s[i:end])
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+// labelsEnd returns the index of the brace that closes the labels that start at the brace at open, or
+// -1 if they aren't closed. Braces inside quotes don't count.
+func labelsEnd(s string, open int) int {
+	quoted := false
+	for i := open + 1; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == '}' && !quoted:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// parseSelector splits a token like perc{path="/home",device="sda1"} into the value's name and its
+// labels. It returns false if the token isn't a valid name with optional labels.
+func parseSelector(token string) (string, []value.Label, bool) {
+	open := strings.IndexByte(token, '{')
+	if open < 0 {
+		return token, nil, isName(token)
+	}
+	if !strings.HasSuffix(token, "}") {
+		return "", nil, false
+	}
+	name, rest := token[:open], token[open+1:len(token)-1]
+	if !isName(name) {
+		return "", nil, false
+	}
+
+	var labels []value.Label
+	for rest != "" {
+		equals := strings.IndexByte(rest, '=')
+		if equals < 0 || !isName(rest[:equals]) || !strings.HasPrefix(rest[equals+1:], `"`) {
+			return "", nil, false
+		}
+		end := strings.IndexByte(rest[equals+2:], '"')
+		if end < 0 {
+			return "", nil, false
+		}
+		labels = append(labels, value.Label{Name: rest[:equals], Value: rest[equals+2 : equals+2+end]})
+
+		// Labels are separated by commas.
+		rest = rest[equals+3+end:]
+		if rest != "" {
+			if rest[0] != ',' {
+				return "", nil, false
+			}
+			if rest = strings.TrimLeft(rest[1:], " \t"); rest == "" {
+				return "", nil, false
+			}
+		}
+	}
+
+	return name, labels, true
+}
+
+// isName returns whether or not s can be the name of a value.
+func isName(s string) bool {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '_' {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// eval returns whether or not the expression is true for these values.
+func (e expression) eval(values []value.Value) bool {
+	for _, group := range e {
+		matched := true
+		for _, c := range group {
+			if !c.eval(values) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// eval returns whether or not the comparison is true for these values.
+func (c comparison) eval(values []value.Value) bool {
+	v, ok := value.Find(values, c.name, c.labels...)
+	if !ok || v.Kind != c.constant.Kind {
+		return false
+	}
+
+	var order int
+	switch v.Kind {
+	case value.Number:
+		switch {
+		case v.Number < c.constant.Number:
+			order = -1
+		case v.Number > c.constant.Number:
+			order = 1
+		}
+	case value.Bool:
+		if v.Bool != c.constant.Bool {
+			order = 1
+		}
+	case value.Text:
+		if v.Text != c.constant.Text {
+			order = 1
+		}
+	}
+
+	switch c.op {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	}
+
+	return order >= 0
+}
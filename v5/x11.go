@@ -0,0 +1,81 @@
+// +build cgo,!nox11
+
+// This file holds the sink that prints the statusbar to the X root window.
+
+package statusbar
+
+// #cgo pkg-config: x11
+// #cgo LDFLAGS: -lX11
+// #include <stdlib.h>
+// #include <X11/Xlib.h>
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+var (
+	// These are used for printing onto dwm's statusbar. The connection to the display is not
+	// opened until it is first needed, so programs that never print to X don't need a display.
+	dpy     *C.Display
+	root    C.Window
+	dpyOnce sync.Once
+	dpyErr  error
+)
+
+// xSink sets the name of the X root window, which is what dwm displays on its statusbar.
+type xSink struct{}
+
+// NewXSink returns a Sink that prints each frame to the name of the X root window. This is the
+// sink used by dwm, and it is the default sink if no others are added to the statusbar. The
+// connection to the X server is opened on the first write.
+func NewXSink() Sink {
+	return xSink{}
+}
+
+// Write prints bar to the statusbar.
+func (s xSink) Write(bar string) error {
+	if err := openDisplay(); err != nil {
+		return err
+	}
+
+	c := C.CString(bar)
+	defer C.free(unsafe.Pointer(c))
+
+	C.XStoreName(dpy, root, c)
+	C.XSync(dpy, 1)
+
+	return nil
+}
+
+// Close does nothing for the X sink. The display connection is shared by every X sink.
+func (s xSink) Close() error {
+	return nil
+}
+
+// openDisplay opens the connection to the X server, if it isn't open already.
+func openDisplay() error {
+	dpyOnce.Do(func() {
+		dpy = C.XOpenDisplay(nil)
+		if dpy == nil {
+			dpyErr = fmt.Errorf("unable to open X display")
+			return
+		}
+		root = C.XDefaultRootWindow(dpy)
+	})
+
+	return dpyErr
+}
+
+// defaultSink returns the sink to use when none were added to the statusbar. This is the X root
+// window if a display is available, or stdout otherwise.
+func defaultSink() Sink {
+	if err := openDisplay(); err != nil {
+		logAt(LevelWarn, "Printing to stdout instead", "error", err)
+		return NewStdoutSink()
+	}
+
+	return NewXSink()
+}
@@ -0,0 +1,37 @@
+// +build !cgo nox11
+
+// This file stands in for the X11 sink when the package is built without cgo or with the nox11
+// build tag.
+
+package statusbar
+
+import (
+	"fmt"
+)
+
+// xSink is a placeholder for the X root window sink. It always fails to write.
+type xSink struct{}
+
+// NewXSink returns a Sink that would print each frame to the name of the X root window. This build
+// does not include X11 support (it was built without cgo or with the nox11 tag), so every write
+// returns an error.
+func NewXSink() Sink {
+	return xSink{}
+}
+
+// Write returns an error, because X11 support is not available.
+func (s xSink) Write(bar string) error {
+	return fmt.Errorf("X11 support not built in")
+}
+
+// Close does nothing.
+func (s xSink) Close() error {
+	return nil
+}
+
+// defaultSink returns the sink to use when none were added to the statusbar. Without X11 support,
+// this is always stdout.
+func defaultSink() Sink {
+	logAt(LevelWarn, "X11 support not built in, printing to stdout instead")
+	return NewStdoutSink()
+}